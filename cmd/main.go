@@ -3,30 +3,54 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"sns-poster/internal/account"
+	"sns-poster/internal/apikey"
+	"sns-poster/internal/callback"
 	"sns-poster/internal/config"
 	"sns-poster/internal/logger"
+	"sns-poster/internal/notify"
+	"sns-poster/internal/sensitive"
 	"sns-poster/internal/server"
+	"sns-poster/internal/templates"
+	"sns-poster/internal/utils"
+	"sns-poster/internal/webhook"
 	"sns-poster/internal/xhs"
 	"syscall"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
 
 	"github.com/sirupsen/logrus"
 )
 
+// defaultConfigFile 默认配置文件，不存在时只使用默认值和环境变量
+const defaultConfigFile = "./config.yaml"
+
+// publishJobsShutdownTimeout 关闭服务时等待后台发布任务完成的时间，超时后取消任务
+const publishJobsShutdownTimeout = 30 * time.Second
+
 func main() {
 	// 首先定义和解析所有命令行参数
 	var (
-		httpPort string
-		logFile  string
+		configFile        string
+		httpPort          string
+		logFile           string
+		generateCookieKey bool
+		rotateCookieKey   bool
+		generateAPIKey    bool
 	)
-	flag.StringVar(&httpPort, "http-port", ":6170", "HTTP服务器端口")
+	flag.StringVar(&configFile, "config", defaultConfigFile, "配置文件路径（也可用 SNS_POSTER_CONFIG 指定）")
+	flag.StringVar(&httpPort, "http-port", ":6170", "HTTP服务器端口（覆盖配置文件的 server.port）")
 	flag.StringVar(&logFile, "log-file", "", "日志文件路径 (留空则输出到控制台)")
+	flag.BoolVar(&generateCookieKey, "generate-cookie-key", false, "生成 cookie 加密密钥后退出")
+	flag.BoolVar(&generateAPIKey, "generate-api-key", false, "生成 API 密钥及其哈希后退出")
+	flag.BoolVar(&rotateCookieKey, "rotate-cookie-key", false, "用当前密钥重新加密所有账号的 cookies（轮换密钥、迁移明文数据）后退出")
 
 	// 立即解析标志，避免与rod的标志冲突
 	flag.Parse()
@@ -36,26 +60,179 @@ func main() {
 		log.Fatalf("初始化日志系统失败: %v", err)
 	}
 
-	// 初始化配置（accountID 由各 HTTP 请求 / 消息携带，不在此指定）
-	cfg := &config.Config{}
+	if generateCookieKey {
+		key, err := utils.GenerateCookieKey()
+		if err != nil {
+			log.Fatalf("生成 cookie 密钥失败: %v", err)
+		}
+		fmt.Println(key)
+		return
+	}
+
+	if generateAPIKey {
+		key, err := apikey.Generate()
+		if err != nil {
+			log.Fatalf("生成 API 密钥失败: %v", err)
+		}
+		fmt.Printf("API 密钥（只显示一次，请交给调用方保存）: %s\n", key)
+		fmt.Printf("写入 API 密钥文件的 key_hash: %s\n", apikey.Hash(key))
+		return
+	}
 
-	// 加载环境变量
-	if err := godotenv.Load(); err != nil {
+	// 加载 .env（可选），其中的变量和其他环境变量一样覆盖配置文件
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
 		log.Fatalf("加载环境变量失败: %v", err)
 	}
 
+	// 加载配置：默认值 -> 配置文件 -> 环境变量，校验失败时列出所有问题后退出
+	// 未显式指定配置文件时，默认的 ./config.yaml 不存在也可以启动
+	explicitFlags := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicitFlags[f.Name] = true })
+	optional := !explicitFlags["config"]
+	if !explicitFlags["config"] && os.Getenv("SNS_POSTER_CONFIG") != "" {
+		configFile = os.Getenv("SNS_POSTER_CONFIG")
+		optional = false
+	}
+	cfg, err := config.Load(configFile, optional)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	// 显式指定的 -http-port 优先于配置文件和环境变量
+	if explicitFlags["http-port"] {
+		cfg.Server.Port = httpPort
+		if err := cfg.Validate(); err != nil {
+			log.Fatalf("加载配置失败: %v", err)
+		}
+	}
+	config.InitConfig(cfg)
+
+	// 配置热更新：配置文件变化、收到 SIGHUP 或调用管理接口时重新加载
+	reloader := config.NewReloader(configFile, optional)
+	if err := reloader.Watch(); err != nil {
+		logrus.Warnf("无法监听配置文件变化，只能通过 SIGHUP 或管理接口重新加载: %v", err)
+	}
+
 	// 初始化Redis客户端
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     os.Getenv("REDIS_ADDRESS"),
-		Password: os.Getenv("REDIS_PASSWORD"),
-		DB:       0,
+		Addr:     cfg.Redis.Address,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
 	})
 
+	// cookie 存储后端
+	cookieStore, err := newCookieStore(&cfg.Cookies, redisClient, cfg.Redis.QueueName)
+	if err != nil {
+		log.Fatalf("初始化 cookie 存储失败: %v", err)
+	}
+	utils.SetCookieStore(cookieStore)
+
+	// cookie 加密
+	cookieCipher, err := loadCookieCipher(&cfg.Cookies)
+	if err != nil {
+		log.Fatalf("加载 cookie 加密密钥失败: %v", err)
+	}
+	if cookieCipher == nil {
+		logrus.Warn("未配置 cookies.key / cookies.key_file，cookies 将以明文保存")
+	} else {
+		utils.SetCookieCipher(cookieCipher)
+		logrus.Infof("cookie 文件加密已启用，密钥: %s", cookieCipher.KeyID())
+	}
+
+	if rotateCookieKey {
+		if cookieCipher == nil {
+			log.Fatal("重新加密 cookies 需要配置 cookies.key 或 cookies.key_file")
+		}
+		count, err := utils.ReencryptCookieFiles()
+		if err != nil {
+			log.Fatalf("重新加密 cookies 失败（已完成 %d 个账号）: %v", count, err)
+		}
+		logrus.Infof("已用密钥 %s 重新加密 %d 个账号的 cookies", cookieCipher.KeyID(), count)
+		return
+	}
+
+	// 通知渠道（二维码、发布失败、账号掉线等）
+	notifier, err := notify.LoadFile(cfg.Files.Notify)
+	if err != nil {
+		log.Fatalf("加载通知配置失败: %v", err)
+	}
+	if notifier.Len() == 0 {
+		logrus.Warnf("未配置通知渠道（%s），登录二维码、掉线告警等只输出到日志", cfg.Files.Notify)
+	}
+	notify.SetDefault(notifier)
+
+	// 加载账号注册表
+	accounts, err := account.NewRegistry(cfg.Files.Accounts)
+	if err != nil {
+		log.Fatalf("加载账号注册表失败: %v", err)
+	}
+	if accounts.Len() == 0 {
+		seedAccountsFromCookies(accounts)
+	}
+
+	// 敏感词表，配置重新加载时一起重新加载（包括词表文件和 Redis 中的词）
+	words, err := sensitive.NewFilter(context.Background(), cfg.Files.SensitiveWords, redisClient)
+	if err != nil {
+		log.Fatalf("加载敏感词配置失败: %v", err)
+	}
+	// 敏感词配置有错误时重新加载失败，继续使用当前词表；修改敏感词配置文件也会触发重新加载
+	reloader.OnReload(func(*config.Config) (func(), error) {
+		return words.PrepareReload(context.Background())
+	})
+	if err := reloader.WatchFile(cfg.Files.SensitiveWords); err != nil {
+		logrus.Warnf("监听敏感词配置失败: %v", err)
+	}
+
 	// 延迟初始化小红书服务，避免rod在flag.Parse()之前注册标志
-	xhsService := initializeServices(cfg)
+	xhsService := initializeServices(cfg, accounts, words)
+
+	// 启动账号登录健康检查
+	xhsService.HealthMonitor().Start(cfg.XHS.HealthCheckInterval)
+
+	// 启动登录 session 自动刷新
+	xhsService.SessionRefresher().Start(xhs.SessionRefresherConfig{
+		Interval:      cfg.XHS.SessionRefreshInterval,
+		RefreshBefore: cfg.XHS.SessionRefreshBefore,
+		WarnBefore:    cfg.XHS.SessionExpiryWarnBefore,
+	})
+
+	// 加载 API 密钥
+	apiKeys, err := apikey.LoadFile(cfg.Files.APIKeys)
+	if err != nil {
+		log.Fatalf("加载 API 密钥失败: %v", err)
+	}
+	if apiKeys.Len() == 0 {
+		logrus.Warnf("未配置 API 密钥（%s），除 /health 外的接口都将拒绝访问，可用 -generate-api-key 生成", cfg.Files.APIKeys)
+	}
+
+	// 加载 webhook 来源，nonce 记录在 Redis 中防止重放
+	webhooks, err := webhook.LoadFile(cfg.Files.Webhooks,
+		webhook.NewRedisNonceStore(redisClient, cfg.Redis.QueueName+":webhook:nonce"))
+	if err != nil {
+		log.Fatalf("加载 webhook 配置失败: %v", err)
+	}
+
+	// 发布结果回调：请求级回调用 callback.secret 为每个地址派生的密钥签名，全局订阅使用各自的密钥
+	callbacks, err := callback.LoadFile(cfg.Files.Callbacks, cfg.Callback.Secret, cfg.Callback.AllowedHosts,
+		callback.NewRedisDeliveryLog(redisClient, cfg.Redis.QueueName+":callbacks:deliveries"))
+	if err != nil {
+		log.Fatalf("加载回调配置失败: %v", err)
+	}
+
+	// 内容模板，配置重新加载时一起重新加载模板文件
+	contentTemplates, err := templates.NewStore(cfg.Files.Templates)
+	if err != nil {
+		log.Fatalf("加载内容模板失败: %v", err)
+	}
+	// 模板文件有错误时重新加载失败，继续使用当前模板；修改模板文件也会触发重新加载
+	reloader.OnReload(func(*config.Config) (func(), error) {
+		return contentTemplates.PrepareReload()
+	})
+	if err := reloader.WatchFile(cfg.Files.Templates); err != nil {
+		logrus.Warnf("监听内容模板文件失败: %v", err)
+	}
 
 	// 创建HTTP服务器
-	httpServer := server.NewHTTPServer(xhsService, redisClient)
+	httpServer := server.NewHTTPServer(reloader, apiKeys, webhooks, callbacks, contentTemplates, xhsService, redisClient)
 
 	// 设置信号处理
 	quit := make(chan os.Signal, 1)
@@ -63,8 +240,8 @@ func main() {
 
 	// 启动HTTP服务器
 	go func() {
-		logrus.Infof("启动HTTP服务器在端口 %s", httpPort)
-		if err := httpServer.StartWithoutSignalHandling(httpPort); err != nil {
+		logrus.Infof("启动HTTP服务器在端口 %s", cfg.Server.Port)
+		if err := httpServer.StartWithoutSignalHandling(cfg.Server.Port); err != nil {
 			logrus.Errorf("HTTP服务器启动失败: %v", err)
 		}
 	}()
@@ -75,25 +252,104 @@ func main() {
 		logServerStartupInfo()
 	}()
 
+	// SIGHUP 重新加载配置，失败时保留当前配置
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logrus.Info("收到 SIGHUP，重新加载配置...")
+			_, _ = reloader.Reload()
+		}
+	}()
+
 	// 等待中断信号
 	<-quit
 	logrus.Info("收到关闭信号，开始优雅关闭...")
 
 	// 开始优雅关闭
-	gracefulShutdown(httpServer, xhsService)
+	gracefulShutdown(httpServer, xhsService, callbacks, reloader)
 }
 
 // initializeServices 初始化所有服务（在flag.Parse()之后调用）
-func initializeServices(cfg *config.Config) *xhs.Service {
+func initializeServices(cfg *config.Config, accounts *account.Registry, words *sensitive.Filter) *xhs.Service {
 	// 初始化小红书服务
-	xhsService := xhs.NewService(cfg)
+	xhsService := xhs.NewService(cfg, accounts, words)
 	return xhsService
 }
 
+// newCookieStore 按 cookies.store 创建 cookie 存储后端
+// - file（默认）：cookies.dir 目录下每个账号一个文件
+// - redis：保存在 Redis 中，多个实例共享登录 session
+func newCookieStore(cfg *config.CookieConfig, redisClient *redis.Client, queueName string) (utils.CookieStore, error) {
+	switch cfg.Store {
+	case "file":
+		logrus.Infof("cookie 存储: 文件 (%s)", cfg.Dir)
+		return utils.NewFileCookieStore(cfg.Dir), nil
+	case "redis":
+		prefix := queueName + ":cookies"
+		logrus.Infof("cookie 存储: Redis (%s:*)", prefix)
+		return utils.NewRedisCookieStore(redisClient, prefix), nil
+	default:
+		return nil, errors.Errorf("不支持的 cookie 存储: %s", cfg.Store)
+	}
+}
+
+// loadCookieCipher 加载 cookie 加密密钥，未配置时返回 nil
+// cookies.key 优先于 cookies.key_file；cookies.previous_keys 为旧密钥，只用于解密轮换前的文件
+func loadCookieCipher(cfg *config.CookieConfig) (*utils.CookieCipher, error) {
+	var (
+		key []byte
+		err error
+	)
+	switch {
+	case cfg.Key != "":
+		key, err = utils.ParseCookieKey(cfg.Key)
+	case cfg.KeyFile != "":
+		key, err = utils.ReadCookieKeyFile(cfg.KeyFile)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var previous [][]byte
+	for _, v := range cfg.PreviousKeys {
+		old, err := utils.ParseCookieKey(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "cookies.previous_keys")
+		}
+		previous = append(previous, old)
+	}
+
+	return utils.NewCookieCipher(key, previous...)
+}
+
+// seedAccountsFromCookies 注册表为空时，把已有 cookie 文件对应的账号注册为启用状态
+// 兼容引入账号注册表之前的部署，避免升级后所有账号都被拒绝
+func seedAccountsFromCookies(accounts *account.Registry) {
+	ids, err := utils.ListCookieAccountIDs()
+	if err != nil {
+		logrus.Warnf("读取已有 cookie 账号失败: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		if _, err := accounts.Create(&account.Account{ID: id, DisplayName: id, Enabled: true}); err != nil {
+			logrus.Warnf("导入账号 %s 失败: %v", id, err)
+			continue
+		}
+		logrus.Infof("从已有 cookie 文件导入账号: %s", id)
+	}
+}
+
 // gracefulShutdown 优雅关闭HTTP服务器
-func gracefulShutdown(httpServer *server.HTTPServer, xhsService *xhs.Service) {
+func gracefulShutdown(httpServer *server.HTTPServer, xhsService *xhs.Service, callbacks *callback.Dispatcher, reloader *config.Reloader) {
 	logrus.Info("开始优雅关闭服务器...")
 
+	// 停止监听配置文件
+	reloader.Close()
+
 	// 设置较短的关闭超时
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -106,6 +362,12 @@ func gracefulShutdown(httpServer *server.HTTPServer, xhsService *xhs.Service) {
 		logrus.Info("HTTP服务器已成功关闭")
 	}
 
+	// 等待后台发布任务结束，超时后取消并推送失败回调
+	httpServer.WaitPublishJobs(publishJobsShutdownTimeout)
+
+	// 停止回调重试，等待正在进行的投递完成
+	callbacks.Close()
+
 	// XHS服务使用远程浏览器实例，无需关闭浏览器，只需清理连接
 	logrus.Info("清理XHS服务连接...")
 	xhsService.Close()
@@ -132,12 +394,32 @@ func logServerStartupInfo() {
 
 API Endpoints:
    - POST   /api/v1/xhs/login          - Login
+   - POST   /api/v1/xhs/login/sms      - SMS code login (send code)
+   - POST   /api/v1/xhs/login/sessions/:id/code - SMS code login (submit code)
    - GET    /api/v1/xhs/login/status   - Check login status
    - POST   /api/v1/xhs/publish        - Publish content (auto-login)
+   - POST   /api/v1/xhs/validate       - Dry-run publish checks (no browser)
+   - GET    /api/v1/xhs/topics?q=      - Topic suggestions (cached)
+   - GET    /api/v1/xhs/templates      - List content templates
+   - PUT    /api/v1/xhs/templates/:name - Create or replace content template
+   - POST   /api/v1/xhs/templates/:name/render - Preview template rendering
+   - POST   /api/v1/xhs/webhook/publish - Publish content (HMAC-signed webhook)
+   - GET    /api/v1/xhs/callbacks/deliveries - Callback delivery log
+   - GET    /api/v1/xhs/callbacks/secret - Signing secret for a callback URL (admin)
    - POST   /api/v1/xhs/logout         - Logout
+   - GET    /api/v1/xhs/accounts       - List registered accounts
+   - POST   /api/v1/xhs/accounts       - Register account
+   - GET    /api/v1/xhs/accounts/:id/cookies - Export cookies (json|netscape|editthiscookie)
+   - POST   /api/v1/xhs/accounts/:id/cookies - Import cookies (verified by login check)
+   - GET    /api/v1/xhs/accounts/health - Login health of all accounts
+   - GET    /api/v1/xhs/accounts/sessions - Session expiry of all accounts
+   - GET    /api/v1/xhs/accounts/attention - Accounts needing manual attention
+   - GET    /api/v1/xhs/remote/:id/screenshot - Captcha remote view
+   - POST   /api/v1/admin/config/reload - Reload config file (also SIGHUP)
    - GET    /health                    - Health check
 
-Multi-account: Use Header X-Account-ID or Query/Body account_id
+Auth: Authorization: Bearer <api key> (scopes: read/login/logout/publish/admin)
+Multi-account: Use Query account_id, Header X-Account-ID or Body account_id
 Press Ctrl+C to shutdown gracefully
 `
 	logrus.Info(banner)
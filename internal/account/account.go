@@ -0,0 +1,117 @@
+package account
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrAccountNotFound 账号未注册
+	ErrAccountNotFound = errors.New("账号未注册")
+	// ErrAccountDisabled 账号已停用
+	ErrAccountDisabled = errors.New("账号已停用")
+	// ErrAccountExists 账号已存在
+	ErrAccountExists = errors.New("账号已存在")
+)
+
+// Account 小红书账号信息
+type Account struct {
+	ID                string     `json:"id"`                            // 账号ID，对应 cookies/<id>.json
+	DisplayName       string     `json:"display_name"`                  // 显示名称
+	XHSUserID         string     `json:"xhs_user_id,omitempty"`         // 小红书用户ID（主页 /user/profile/<id>）
+	OwnerTeam         string     `json:"owner_team,omitempty"`          // 所属团队
+	Proxy             string     `json:"proxy,omitempty"`               // 浏览器代理，如 http://host:port、socks5://host:port
+	DailyPublishLimit int        `json:"daily_publish_limit,omitempty"` // 每日发布上限，0 表示不限制
+	Enabled           bool       `json:"enabled"`                       // 是否启用
+	Attention         *Attention `json:"attention,omitempty"`           // 需要人工处理的问题（如验证码）
+	LastLoginAt       *time.Time `json:"last_login_at,omitempty"`
+	LastPublishAt     *time.Time `json:"last_publish_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// Attention 账号需要人工处理的信息
+type Attention struct {
+	Code         string    `json:"code"`
+	Reason       string    `json:"reason"`
+	Screenshot   string    `json:"screenshot,omitempty"`
+	RemoteViewID string    `json:"remote_view_id,omitempty"`
+	Since        time.Time `json:"since"`
+}
+
+// Update 账号更新请求，nil 字段保持不变
+type Update struct {
+	DisplayName       *string `json:"display_name,omitempty"`
+	XHSUserID         *string `json:"xhs_user_id,omitempty"`
+	OwnerTeam         *string `json:"owner_team,omitempty"`
+	Proxy             *string `json:"proxy,omitempty"`
+	DailyPublishLimit *int    `json:"daily_publish_limit,omitempty"`
+	Enabled           *bool   `json:"enabled,omitempty"`
+}
+
+// apply 把更新应用到账号上
+func (u *Update) apply(a *Account) {
+	if u.DisplayName != nil {
+		a.DisplayName = *u.DisplayName
+	}
+	if u.XHSUserID != nil {
+		a.XHSUserID = *u.XHSUserID
+	}
+	if u.OwnerTeam != nil {
+		a.OwnerTeam = *u.OwnerTeam
+	}
+	if u.Proxy != nil {
+		a.Proxy = *u.Proxy
+	}
+	if u.DailyPublishLimit != nil {
+		a.DailyPublishLimit = *u.DailyPublishLimit
+	}
+	if u.Enabled != nil {
+		a.Enabled = *u.Enabled
+	}
+}
+
+// Validate 校验账号字段
+func (a *Account) Validate() error {
+	if err := ValidateID(a.ID); err != nil {
+		return err
+	}
+	if a.DailyPublishLimit < 0 {
+		return errors.New("daily_publish_limit 不能为负数")
+	}
+	if a.Proxy != "" {
+		u, err := url.Parse(a.Proxy)
+		if err != nil || u.Host == "" {
+			return errors.Errorf("代理地址格式不正确: %s", a.Proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return errors.Errorf("不支持的代理协议: %s", u.Scheme)
+		}
+		// Chrome 的 --proxy-server 不支持内嵌账号密码
+		if u.User != nil {
+			return errors.New("代理暂不支持账号密码认证")
+		}
+	}
+	return nil
+}
+
+// ValidateID 校验账号ID：账号ID会作为 cookie 文件名，只允许字母、数字、下划线和横线
+func ValidateID(id string) error {
+	if id == "" {
+		return errors.New("账号ID不能为空")
+	}
+	if len(id) > 64 {
+		return errors.New("账号ID过长")
+	}
+	if strings.IndexFunc(id, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+	}) >= 0 {
+		return errors.Errorf("账号ID只能包含字母、数字、下划线和横线: %s", id)
+	}
+	return nil
+}
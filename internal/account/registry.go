@@ -0,0 +1,237 @@
+package account
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Registry 账号注册表，持久化到 JSON 文件
+type Registry struct {
+	path     string
+	accounts map[string]*Account
+	mu       sync.RWMutex
+}
+
+// NewRegistry 创建账号注册表并从文件加载，文件不存在时为空注册表
+func NewRegistry(path string) (*Registry, error) {
+	r := &Registry{
+		path:     path,
+		accounts: make(map[string]*Account),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, errors.Wrap(err, "读取账号注册表失败")
+	}
+
+	var list []*Account
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, errors.Wrap(err, "解析账号注册表失败")
+	}
+	for _, a := range list {
+		r.accounts[a.ID] = a
+	}
+
+	logrus.Infof("已加载账号注册表: %s (%d 个账号)", path, len(list))
+	return r, nil
+}
+
+// Len 已注册账号数量
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.accounts)
+}
+
+// List 返回所有账号（按ID排序）
+func (r *Registry) List() []*Account {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*Account, 0, len(r.accounts))
+	for _, a := range r.accounts {
+		copied := *a
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Get 获取账号，未注册返回 ErrAccountNotFound
+func (r *Registry) Get(id string) (*Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, ok := r.accounts[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrAccountNotFound, "账号 %s", id)
+	}
+	copied := *a
+	return &copied, nil
+}
+
+// Resolve 获取可用账号：未注册返回 ErrAccountNotFound，已停用返回 ErrAccountDisabled
+func (r *Registry) Resolve(id string) (*Account, error) {
+	a, err := r.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !a.Enabled {
+		return nil, errors.Wrapf(ErrAccountDisabled, "账号 %s", id)
+	}
+	return a, nil
+}
+
+// Create 注册新账号
+func (r *Registry) Create(a *Account) (*Account, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.accounts[a.ID]; ok {
+		return nil, errors.Wrapf(ErrAccountExists, "账号 %s", a.ID)
+	}
+
+	now := time.Now()
+	created := *a
+	created.CreatedAt = now
+	created.UpdatedAt = now
+	r.accounts[a.ID] = &created
+
+	if err := r.saveLocked(); err != nil {
+		delete(r.accounts, a.ID)
+		return nil, err
+	}
+
+	copied := created
+	return &copied, nil
+}
+
+// Update 更新账号字段
+func (r *Registry) Update(id string, update *Update) (*Account, error) {
+	return r.modify(id, func(a *Account) error {
+		update.apply(a)
+		return a.Validate()
+	})
+}
+
+// Delete 删除账号
+func (r *Registry) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.accounts[id]
+	if !ok {
+		return errors.Wrapf(ErrAccountNotFound, "账号 %s", id)
+	}
+
+	delete(r.accounts, id)
+	if err := r.saveLocked(); err != nil {
+		r.accounts[id] = a
+		return err
+	}
+	return nil
+}
+
+// SetAttention 设置或清除（attention 为 nil）账号的待处理标记
+func (r *Registry) SetAttention(id string, attention *Attention) error {
+	_, err := r.modify(id, func(a *Account) error {
+		a.Attention = attention
+		return nil
+	})
+	return err
+}
+
+// TouchLogin 记录账号最近一次登录时间
+func (r *Registry) TouchLogin(id string) error {
+	_, err := r.modify(id, func(a *Account) error {
+		now := time.Now()
+		a.LastLoginAt = &now
+		return nil
+	})
+	return err
+}
+
+// TouchPublish 记录账号最近一次发布时间
+func (r *Registry) TouchPublish(id string) error {
+	_, err := r.modify(id, func(a *Account) error {
+		now := time.Now()
+		a.LastPublishAt = &now
+		return nil
+	})
+	return err
+}
+
+// modify 在写锁内修改账号并持久化，修改或保存失败时回滚
+func (r *Registry) modify(id string, fn func(a *Account) error) (*Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.accounts[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrAccountNotFound, "账号 %s", id)
+	}
+
+	updated := *a
+	if err := fn(&updated); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = time.Now()
+
+	r.accounts[id] = &updated
+	if err := r.saveLocked(); err != nil {
+		r.accounts[id] = a
+		return nil, err
+	}
+
+	copied := updated
+	return &copied, nil
+}
+
+// saveLocked 写入注册表文件（先写临时文件再重命名，避免写一半）。调用方需持有写锁
+func (r *Registry) saveLocked() error {
+	list := make([]*Account, 0, len(r.accounts))
+	for _, a := range r.accounts {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "序列化账号注册表失败")
+	}
+
+	dir := filepath.Dir(r.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "创建账号注册表目录失败")
+	}
+
+	tmp, err := os.CreateTemp(dir, ".accounts-*.json")
+	if err != nil {
+		return errors.Wrap(err, "创建临时文件失败")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "写入账号注册表失败")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "写入账号注册表失败")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), r.path), "保存账号注册表失败")
+}
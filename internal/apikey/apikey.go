@@ -0,0 +1,179 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// 操作权限
+const (
+	ScopeRead    = "read"    // 查询登录状态、健康检查、session 等
+	ScopeLogin   = "login"   // 扫码/短信登录、处理验证码
+	ScopeLogout  = "logout"  // 登出（删除 cookies）
+	ScopePublish = "publish" // 发布内容
+	ScopeAdmin   = "admin"   // 账号注册表管理、cookies 导入导出
+)
+
+// AllAccounts 账号列表中的通配符，表示所有账号
+const AllAccounts = "*"
+
+// hashPrefix 密钥哈希的格式前缀
+const hashPrefix = "sha256:"
+
+// keyPrefix 生成的密钥前缀，便于在日志和代码中识别泄露的密钥
+const keyPrefix = "snsp_"
+
+var (
+	// ErrInvalidKey 密钥不存在或已停用
+	ErrInvalidKey = errors.New("API 密钥无效")
+
+	validScopes = map[string]bool{
+		ScopeRead:    true,
+		ScopeLogin:   true,
+		ScopeLogout:  true,
+		ScopePublish: true,
+		ScopeAdmin:   true,
+	}
+)
+
+// Key API 密钥配置，只保存密钥的哈希
+type Key struct {
+	Name     string   `json:"name"`
+	Hash     string   `json:"key_hash"` // sha256:<hex>
+	Accounts []string `json:"accounts"` // 允许操作的账号ID，"*" 表示所有账号
+	Scopes   []string `json:"scopes"`   // 允许的操作
+	Disabled bool     `json:"disabled,omitempty"`
+}
+
+// HasScope 是否允许该操作
+func (k *Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAccount 是否允许操作该账号
+func (k *Key) AllowsAccount(accountID string) bool {
+	for _, id := range k.Accounts {
+		if id == AllAccounts || id == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// validate 校验密钥配置
+func (k *Key) validate() error {
+	if k.Name == "" {
+		return errors.New("密钥名称不能为空")
+	}
+	if !strings.HasPrefix(k.Hash, hashPrefix) {
+		return errors.Errorf("密钥 %s 的 key_hash 格式错误，应为 sha256:<hex>", k.Name)
+	}
+	if raw, err := hex.DecodeString(strings.TrimPrefix(k.Hash, hashPrefix)); err != nil || len(raw) != sha256.Size {
+		return errors.Errorf("密钥 %s 的 key_hash 格式错误，应为 sha256:<hex>", k.Name)
+	}
+	if len(k.Accounts) == 0 {
+		return errors.Errorf("密钥 %s 未配置允许的账号", k.Name)
+	}
+	for _, scope := range k.Scopes {
+		if !validScopes[scope] {
+			return errors.Errorf("密钥 %s 的权限不支持: %s", k.Name, scope)
+		}
+	}
+	return nil
+}
+
+// Store API 密钥集合
+type Store struct {
+	keys []*Key
+}
+
+// NewStore 创建密钥集合，校验每个密钥的配置
+func NewStore(keys []*Key) (*Store, error) {
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if err := k.validate(); err != nil {
+			return nil, err
+		}
+		if seen[k.Hash] {
+			return nil, errors.Errorf("密钥 %s 与其他密钥重复", k.Name)
+		}
+		seen[k.Hash] = true
+	}
+	return &Store{keys: keys}, nil
+}
+
+// LoadFile 从 JSON 文件加载密钥，文件不存在时返回空集合
+func LoadFile(path string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Store{}, nil
+		}
+		return nil, errors.Wrap(err, "读取 API 密钥文件失败")
+	}
+
+	var keys []*Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, errors.Wrap(err, "解析 API 密钥文件失败")
+	}
+
+	store, err := NewStore(keys)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("已加载 API 密钥: %s (%d 个)", path, len(keys))
+	return store, nil
+}
+
+// Len 密钥数量
+func (s *Store) Len() int {
+	return len(s.keys)
+}
+
+// Authenticate 校验请求携带的密钥，返回对应的密钥配置
+func (s *Store) Authenticate(token string) (*Key, error) {
+	if token == "" {
+		return nil, ErrInvalidKey
+	}
+
+	hash := []byte(Hash(token))
+	var matched *Key
+	// 逐个比较全部密钥，耗时与匹配位置无关
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare(hash, []byte(k.Hash)) == 1 {
+			matched = k
+		}
+	}
+	if matched == nil || matched.Disabled {
+		return nil, ErrInvalidKey
+	}
+	return matched, nil
+}
+
+// Hash 计算密钥的哈希（sha256:<hex>）。密钥是高熵随机值，无需慢哈希
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// Generate 生成新的随机密钥
+func Generate() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", errors.Wrap(err, "生成密钥失败")
+	}
+	return keyPrefix + hex.EncodeToString(raw), nil
+}
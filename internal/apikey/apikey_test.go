@@ -0,0 +1,57 @@
+package apikey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreAuthenticate(t *testing.T) {
+	token, err := Generate()
+	require.NoError(t, err)
+	disabledToken, err := Generate()
+	require.NoError(t, err)
+
+	store, err := NewStore([]*Key{
+		{Name: "publisher", Hash: Hash(token), Accounts: []string{"acct-1"}, Scopes: []string{ScopePublish, ScopeRead}},
+		{Name: "old", Hash: Hash(disabledToken), Accounts: []string{AllAccounts}, Scopes: []string{ScopeAdmin}, Disabled: true},
+	})
+	require.NoError(t, err)
+
+	key, err := store.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, "publisher", key.Name)
+	assert.True(t, key.HasScope(ScopePublish))
+	assert.False(t, key.HasScope(ScopeLogout))
+	assert.True(t, key.AllowsAccount("acct-1"))
+	assert.False(t, key.AllowsAccount("acct-2"))
+
+	_, err = store.Authenticate(disabledToken)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = store.Authenticate("snsp_unknown")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = store.Authenticate("")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestNewStoreValidate(t *testing.T) {
+	hash := Hash("secret")
+
+	_, err := NewStore([]*Key{{Name: "k", Hash: "secret", Accounts: []string{"*"}, Scopes: []string{ScopeRead}}})
+	assert.Error(t, err, "明文密钥应被拒绝")
+
+	_, err = NewStore([]*Key{{Name: "k", Hash: hash, Scopes: []string{ScopeRead}}})
+	assert.Error(t, err, "未配置账号应被拒绝")
+
+	_, err = NewStore([]*Key{{Name: "k", Hash: hash, Accounts: []string{"*"}, Scopes: []string{"delete"}}})
+	assert.Error(t, err, "未知权限应被拒绝")
+
+	_, err = NewStore([]*Key{
+		{Name: "a", Hash: hash, Accounts: []string{"*"}, Scopes: []string{ScopeRead}},
+		{Name: "b", Hash: hash, Accounts: []string{"*"}, Scopes: []string{ScopeRead}},
+	})
+	assert.Error(t, err, "重复密钥应被拒绝")
+}
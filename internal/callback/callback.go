@@ -0,0 +1,545 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sns-poster/internal/webhook"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// 回调事件
+const (
+	EventPublishSucceeded = "publish.succeeded" // 发布成功
+	EventPublishFailed    = "publish.failed"    // 发布失败
+)
+
+// HeaderEvent 回调事件类型的请求头，其余签名请求头与接收 webhook 相同（见 webhook 包）
+const HeaderEvent = "X-Webhook-Event"
+
+// sourceName 回调请求的 X-Webhook-Source
+const sourceName = "sns-poster"
+
+// minSecretLength 签名密钥最小长度
+const minSecretLength = 32
+
+// maxResponseSnippet 投递记录中保存的响应内容最大长度
+const maxResponseSnippet = 512
+
+// DefaultRetryDelays 投递失败后的重试间隔，共投递 len+1 次
+var DefaultRetryDelays = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute, 30 * time.Minute}
+
+var (
+	// ErrInvalidURL 回调地址格式错误
+	ErrInvalidURL = errors.New("回调地址无效")
+	// ErrNotConfigured 未配置回调签名密钥，不能使用请求级回调
+	ErrNotConfigured = errors.New("未配置回调签名密钥")
+	// ErrForbiddenAddress 请求级回调地址指向本机、内网等不允许访问的地址
+	ErrForbiddenAddress = errors.New("回调地址指向不允许访问的地址")
+)
+
+// resolveTimeout 校验请求级回调地址时解析域名的超时时间
+const resolveTimeout = 5 * time.Second
+
+var validEvents = map[string]bool{
+	EventPublishSucceeded: true,
+	EventPublishFailed:    true,
+}
+
+// Payload 回调请求体
+type Payload struct {
+	Event     string    `json:"event"`
+	JobID     string    `json:"job_id"`
+	AccountID string    `json:"account_id"`
+	Title     string    `json:"title,omitempty"`
+	URL       string    `json:"url,omitempty"`     // 发布请求中的来源链接
+	NoteID    string    `json:"note_id,omitempty"` // 发布成功且获取到笔记ID时返回
+	Error     string    `json:"error,omitempty"`   // 发布失败原因
+	Code      string    `json:"code,omitempty"`    // 发布失败的错误码
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Subscription 全局回调订阅，所有发布任务的结果都会推送
+type Subscription struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret"`             // HMAC 签名密钥，至少 32 个字符
+	Events   []string `json:"events,omitempty"`   // 订阅的事件，为空表示全部
+	Accounts []string `json:"accounts,omitempty"` // 订阅的账号ID，为空或 "*" 表示全部
+	Disabled bool     `json:"disabled,omitempty"`
+}
+
+// wants 订阅是否需要该事件
+func (sub *Subscription) wants(p *Payload) bool {
+	if sub.Disabled {
+		return false
+	}
+	if len(sub.Events) > 0 && !contains(sub.Events, p.Event) {
+		return false
+	}
+	if len(sub.Accounts) > 0 && !contains(sub.Accounts, "*") && !contains(sub.Accounts, p.AccountID) {
+		return false
+	}
+	return true
+}
+
+// validate 校验订阅配置
+func (sub *Subscription) validate() error {
+	if sub.Name == "" {
+		return errors.New("回调订阅名称不能为空")
+	}
+	if err := ValidateURL(sub.URL); err != nil {
+		return errors.Wrapf(err, "回调订阅 %s", sub.Name)
+	}
+	if len(sub.Secret) < minSecretLength {
+		return errors.Errorf("回调订阅 %s 的密钥至少需要 %d 个字符", sub.Name, minSecretLength)
+	}
+	for _, event := range sub.Events {
+		if !validEvents[event] {
+			return errors.Errorf("回调订阅 %s 的事件不支持: %s", sub.Name, event)
+		}
+	}
+	return nil
+}
+
+// Delivery 一次回调投递的记录
+type Delivery struct {
+	ID         string    `json:"id"` // 同一目标的多次重试共用 ID（即 X-Webhook-Nonce）
+	JobID      string    `json:"job_id"`
+	AccountID  string    `json:"account_id"`
+	Event      string    `json:"event"`
+	Target     string    `json:"target"` // 订阅名称，请求级回调为 "request"
+	URL        string    `json:"url"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Response   string    `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Success    bool      `json:"success"`
+	DurationMS int64     `json:"duration_ms"`
+	Time       time.Time `json:"time"`
+}
+
+// DeliveryLog 投递记录存储
+type DeliveryLog interface {
+	Append(ctx context.Context, d *Delivery) error
+	// List 返回最近的投递记录（新的在前），jobID 为空时不过滤
+	List(ctx context.Context, jobID string, limit int) ([]*Delivery, error)
+}
+
+// requestTarget 请求级回调的目标名称
+const requestTarget = "request"
+
+// target 一个投递目标
+type target struct {
+	name   string
+	url    string
+	secret string
+}
+
+// Dispatcher 发布结果回调：签名后异步投递到请求级回调地址和全局订阅，失败按间隔重试
+type Dispatcher struct {
+	subscriptions []*Subscription
+	secret        string
+	log           DeliveryLog
+	// client 投递到全局订阅（管理员配置的地址，可以是内网）
+	client *http.Client
+	// requestClient 投递到请求级回调地址，连接时拒绝本机、内网等地址（见 addressGuard）
+	requestClient *http.Client
+	guard         *addressGuard
+	retryDelays   []time.Duration
+
+	// 关闭后停止等待重试
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher 创建回调投递器。secret 用于为每个请求级回调地址派生签名密钥（见 RequestSecret），
+// 为空时不支持请求级回调；allowedHosts 为请求级回调允许访问的内网主机名、IP 或网段
+func NewDispatcher(subscriptions []*Subscription, secret string, allowedHosts []string, log DeliveryLog) (*Dispatcher, error) {
+	if secret != "" && len(secret) < minSecretLength {
+		return nil, errors.Errorf("回调签名密钥至少需要 %d 个字符", minSecretLength)
+	}
+	seen := make(map[string]bool)
+	for _, sub := range subscriptions {
+		if err := sub.validate(); err != nil {
+			return nil, err
+		}
+		if seen[sub.Name] {
+			return nil, errors.Errorf("回调订阅 %s 重复", sub.Name)
+		}
+		seen[sub.Name] = true
+	}
+	guard, err := newAddressGuard(allowedHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		subscriptions: subscriptions,
+		secret:        secret,
+		log:           log,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		requestClient: &http.Client{Timeout: 10 * time.Second, Transport: guard.transport()},
+		guard:         guard,
+		retryDelays:   DefaultRetryDelays,
+		ctx:           ctx,
+		cancel:        cancel,
+	}, nil
+}
+
+// LoadFile 从 JSON 文件加载全局订阅，文件不存在时没有订阅
+func LoadFile(path, secret string, allowedHosts []string, log DeliveryLog) (*Dispatcher, error) {
+	var subscriptions []*Subscription
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, errors.Wrap(err, "读取回调订阅配置失败")
+	default:
+		if err := json.Unmarshal(data, &subscriptions); err != nil {
+			return nil, errors.Wrap(err, "解析回调订阅配置失败")
+		}
+		logrus.Infof("已加载回调订阅: %s (%d 个)", path, len(subscriptions))
+	}
+	return NewDispatcher(subscriptions, secret, allowedHosts, log)
+}
+
+// Log 返回投递记录存储
+func (d *Dispatcher) Log() DeliveryLog {
+	return d.log
+}
+
+// CheckCallbackURL 校验请求级回调地址可用：格式正确、已配置签名密钥，且域名解析的地址都允许访问。
+// 投递时连接前会再次检查实际连接的地址，防止 DNS 重绑定
+func (d *Dispatcher) CheckCallbackURL(ctx context.Context, raw string) error {
+	if d.secret == "" {
+		return ErrNotConfigured
+	}
+	if err := ValidateURL(raw); err != nil {
+		return err
+	}
+
+	u, _ := url.Parse(raw)
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	return d.guard.checkHost(ctx, u.Hostname())
+}
+
+// RequestSecret 请求级回调地址的签名密钥：由 callback.secret 和回调地址派生，每个地址不同，
+// 接收方持有的密钥不能伪造发往其他地址的回调
+func (d *Dispatcher) RequestSecret(callbackURL string) (string, error) {
+	if d.secret == "" {
+		return "", ErrNotConfigured
+	}
+	if err := ValidateURL(callbackURL); err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(d.secret))
+	mac.Write([]byte("callback-url:" + callbackURL))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Dispatch 异步投递事件到 callbackURL（可为空）和所有匹配的订阅
+func (d *Dispatcher) Dispatch(p *Payload, callbackURL string) {
+	if p.Timestamp.IsZero() {
+		p.Timestamp = time.Now()
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		logrus.Errorf("[Callback] 序列化回调内容失败: %v", err)
+		return
+	}
+
+	var targets []target
+	if callbackURL != "" {
+		if secret, err := d.RequestSecret(callbackURL); err == nil {
+			targets = append(targets, target{name: requestTarget, url: callbackURL, secret: secret})
+		} else {
+			logrus.Warnf("[Callback] 任务 %s 的回调地址不可用: %v", p.JobID, err)
+		}
+	}
+	for _, sub := range d.subscriptions {
+		if sub.wants(p) {
+			targets = append(targets, target{name: sub.Name, url: sub.URL, secret: sub.Secret})
+		}
+	}
+
+	for _, t := range targets {
+		d.wg.Add(1)
+		go func(t target) {
+			defer d.wg.Done()
+			d.deliver(t, p, body)
+		}(t)
+	}
+}
+
+// deliver 投递到单个目标，失败按 retryDelays 重试，Close 后不再重试
+func (d *Dispatcher) deliver(t target, p *Payload, body []byte) {
+	id := NewID()
+	for attempt := 1; ; attempt++ {
+		record := d.send(t, id, p, body)
+		record.Attempt = attempt
+		if err := d.log.Append(context.Background(), record); err != nil {
+			logrus.Warnf("[Callback] 保存投递记录失败: %v", err)
+		}
+		if record.Success {
+			logrus.Infof("[Callback] 任务 %s 的 %s 回调已投递到 %s", p.JobID, p.Event, t.name)
+			return
+		}
+
+		if attempt > len(d.retryDelays) {
+			logrus.Errorf("[Callback] 任务 %s 的 %s 回调投递到 %s 失败，已放弃: %s",
+				p.JobID, p.Event, t.name, record.Error)
+			return
+		}
+		delay := d.retryDelays[attempt-1]
+		logrus.Warnf("[Callback] 任务 %s 的 %s 回调投递到 %s 失败（第 %d 次），%s 后重试: %s",
+			p.JobID, p.Event, t.name, attempt, delay, record.Error)
+
+		select {
+		case <-d.ctx.Done():
+			logrus.Warnf("[Callback] 服务关闭，任务 %s 的回调不再重试", p.JobID)
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// send 发送一次回调请求并返回投递记录
+func (d *Dispatcher) send(t target, id string, p *Payload, body []byte) *Delivery {
+	record := &Delivery{
+		ID:        id,
+		JobID:     p.JobID,
+		AccountID: p.AccountID,
+		Event:     p.Event,
+		Target:    t.name,
+		URL:       t.url,
+		Time:      time.Now(),
+	}
+
+	// 每次重试重新签名，避免时间戳超出接收方的容忍范围；nonce 不变便于接收方去重
+	timestamp := strconv.FormatInt(record.Time.Unix(), 10)
+	// 请求不随 Close 取消，由 client 超时保证 Close 能及时返回
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, p.Event)
+	req.Header.Set(webhook.HeaderSource, sourceName)
+	req.Header.Set(webhook.HeaderTimestamp, timestamp)
+	req.Header.Set(webhook.HeaderNonce, id)
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(t.secret, timestamp, id, body))
+
+	client := d.client
+	if t.name == requestTarget {
+		client = d.requestClient
+	}
+	resp, err := client.Do(req)
+	record.DurationMS = time.Since(record.Time).Milliseconds()
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	defer resp.Body.Close()
+
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSnippet))
+	record.StatusCode = resp.StatusCode
+	record.Response = string(snippet)
+	record.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !record.Success {
+		record.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	return record
+}
+
+// Close 停止重试并等待正在进行的投递结束
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// ValidateURL 校验回调地址为 http(s) 绝对地址
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Wrapf(ErrInvalidURL, "%q", raw)
+	}
+	return nil
+}
+
+// addressGuard 限制请求级回调可以访问的地址：拒绝本机、内网、链路本地和未指定地址，allowedHosts 中的主机除外
+type addressGuard struct {
+	hosts    map[string]bool
+	networks []*net.IPNet
+	dialer   *net.Dialer
+}
+
+func newAddressGuard(allowedHosts []string) (*addressGuard, error) {
+	g := &addressGuard{
+		hosts:  make(map[string]bool),
+		dialer: &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second},
+	}
+	for _, h := range allowedHosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch {
+		case h == "":
+		case strings.Contains(h, "/"):
+			_, network, err := net.ParseCIDR(h)
+			if err != nil {
+				return nil, errors.Wrapf(err, "回调允许访问的网段格式错误: %s", h)
+			}
+			g.networks = append(g.networks, network)
+		case net.ParseIP(h) != nil:
+			ip := net.ParseIP(h)
+			g.networks = append(g.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		default:
+			g.hosts[h] = true
+		}
+	}
+	return g, nil
+}
+
+// specialNetworks 除本机、内网、链路本地外不可公网访问或可转换为内网地址的特殊用途网段（IANA special-purpose registry）
+var specialNetworks = mustParseCIDRs(
+	"0.0.0.0/8",       // 本网络
+	"100.64.0.0/10",   // 运营商级 NAT（云内网、Tailscale 等）
+	"192.0.0.0/24",    // IETF 协议分配
+	"192.0.2.0/24",    // 文档示例
+	"192.88.99.0/24",  // 6to4 中继
+	"198.18.0.0/15",   // 基准测试
+	"198.51.100.0/24", // 文档示例
+	"203.0.113.0/24",  // 文档示例
+	"224.0.0.0/4",     // 组播
+	"240.0.0.0/4",     // 保留（含广播地址）
+	"64:ff9b::/96",    // NAT64，可映射到内网 IPv4
+	"64:ff9b:1::/48",  // 本地 NAT64
+	"100::/64",        // 丢弃
+	"2001::/23",       // IETF 协议分配（含 Teredo）
+	"2001:db8::/32",   // 文档示例
+	"2002::/16",       // 6to4，可嵌入内网 IPv4
+	"ff00::/8",        // 组播
+)
+
+// mustParseCIDRs 解析网段列表，格式错误时 panic
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// allowedIP 地址是否允许访问
+func (g *addressGuard) allowedIP(ip net.IP) bool {
+	for _, network := range g.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range specialNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHost 解析主机名，任一地址不允许访问时返回 ErrForbiddenAddress
+func (g *addressGuard) checkHost(ctx context.Context, host string) error {
+	if g.hosts[strings.ToLower(host)] {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidURL, "解析域名 %s 失败: %v", host, err)
+	}
+	for _, addr := range addrs {
+		if !g.allowedIP(addr.IP) {
+			return errors.Wrapf(ErrForbiddenAddress, "%s (%s)", host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// transport 连接前检查实际连接的地址（包括重定向后的地址），不使用环境变量中的代理
+func (g *addressGuard) transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if g.hosts[strings.ToLower(host)] {
+			return g.dialer.DialContext(ctx, network, addr)
+		}
+
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error = errors.Wrapf(ErrForbiddenAddress, "%s", host)
+		for _, a := range addrs {
+			if !g.allowedIP(a.IP) {
+				lastErr = errors.Wrapf(ErrForbiddenAddress, "%s (%s)", host, a.IP)
+				continue
+			}
+			// 连接解析出的地址，而不是再次解析主机名
+			conn, err := g.dialer.DialContext(ctx, network, net.JoinHostPort(a.IP.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+	return t
+}
+
+// NewID 生成随机ID，用于任务ID和投递ID
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand 不应失败，退化为时间戳
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
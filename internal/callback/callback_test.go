@@ -0,0 +1,182 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sns-poster/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryLog 测试用的内存投递记录
+type memoryLog struct {
+	records []*Delivery
+	mu      sync.Mutex
+}
+
+func (m *memoryLog) Append(_ context.Context, d *Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append([]*Delivery{d}, m.records...)
+	return nil
+}
+
+func (m *memoryLog) List(_ context.Context, jobID string, _ int) ([]*Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []*Delivery
+	for _, d := range m.records {
+		if jobID == "" || d.JobID == jobID {
+			list = append(list, d)
+		}
+	}
+	return list, nil
+}
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestDispatchSignsAndRetries(t *testing.T) {
+	var calls int32
+	var got Payload
+	var secret string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// 接收方用该地址的派生密钥校验签名
+		sig := webhook.Sign(secret, r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderNonce), body)
+		assert.Equal(t, sig, r.Header.Get(webhook.HeaderSignature))
+		assert.Equal(t, EventPublishSucceeded, r.Header.Get(HeaderEvent))
+
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		require.NoError(t, json.Unmarshal(body, &got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	log := &memoryLog{}
+	// 测试服务器在本机，需要显式允许
+	d, err := NewDispatcher(nil, testSecret, []string{"127.0.0.1"}, log)
+	require.NoError(t, err)
+	d.retryDelays = []time.Duration{10 * time.Millisecond}
+	secret, err = d.RequestSecret(server.URL)
+	require.NoError(t, err)
+
+	require.NoError(t, d.CheckCallbackURL(context.Background(), server.URL))
+	d.Dispatch(&Payload{Event: EventPublishSucceeded, JobID: "job1", AccountID: "acct", NoteID: "n1"}, server.URL)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 2 }, time.Second, 5*time.Millisecond)
+	d.Close()
+
+	assert.Equal(t, "n1", got.NoteID)
+
+	records, err := log.List(context.Background(), "job1", 0)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.True(t, records[0].Success)
+	assert.Equal(t, 2, records[0].Attempt)
+	assert.False(t, records[1].Success)
+	assert.Equal(t, http.StatusServiceUnavailable, records[1].StatusCode)
+	assert.Equal(t, records[0].ID, records[1].ID)
+}
+
+func TestDispatchSubscriptionFilter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	d, err := NewDispatcher([]*Subscription{
+		{Name: "failures", URL: server.URL, Secret: testSecret, Events: []string{EventPublishFailed}},
+		{Name: "acct", URL: server.URL, Secret: testSecret, Accounts: []string{"acct"}},
+	}, "", nil, &memoryLog{})
+	require.NoError(t, err)
+
+	d.Dispatch(&Payload{Event: EventPublishSucceeded, JobID: "j", AccountID: "other"}, "")
+	d.Dispatch(&Payload{Event: EventPublishFailed, JobID: "j", AccountID: "acct"}, "")
+	d.Close()
+
+	assert.EqualValues(t, 2, calls)
+}
+
+func TestCheckCallbackURL(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDispatcher(nil, "", nil, &memoryLog{})
+	require.NoError(t, err)
+	assert.ErrorIs(t, d.CheckCallbackURL(ctx, "https://example.com/cb"), ErrNotConfigured)
+
+	d, err = NewDispatcher(nil, testSecret, []string{"10.1.0.0/16", "callback.internal"}, &memoryLog{})
+	require.NoError(t, err)
+	assert.NoError(t, d.CheckCallbackURL(ctx, "https://93.184.215.14/cb"))
+	assert.ErrorIs(t, d.CheckCallbackURL(ctx, "ftp://example.com"), ErrInvalidURL)
+	assert.ErrorIs(t, d.CheckCallbackURL(ctx, "/relative"), ErrInvalidURL)
+
+	for _, raw := range []string{
+		"http://127.0.0.1:6379/",
+		"http://localhost/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://192.168.1.10/cb",
+		"http://10.2.0.1/cb",
+		"http://[::1]/cb",
+		"http://0.0.0.0/cb",
+		"http://100.100.1.1/cb",
+		"http://192.0.0.8/cb",
+		"http://198.18.0.1/cb",
+		"http://[64:ff9b::a00:1]/cb",
+		"http://[::ffff:10.0.0.1]/cb",
+	} {
+		assert.ErrorIs(t, d.CheckCallbackURL(ctx, raw), ErrForbiddenAddress, raw)
+	}
+	// allowed_hosts 中的网段和主机名
+	assert.NoError(t, d.CheckCallbackURL(ctx, "http://10.1.2.3/cb"))
+	assert.NoError(t, d.CheckCallbackURL(ctx, "http://callback.internal/cb"))
+
+	_, err = NewDispatcher([]*Subscription{{Name: "s", URL: "https://example.com", Secret: "short"}}, "", nil, &memoryLog{})
+	assert.Error(t, err)
+}
+
+func TestRequestDeliveryRejectsPrivateAddress(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	// 校验后 DNS 指向本机（重绑定）时，投递在连接前被拒绝
+	log := &memoryLog{}
+	d, err := NewDispatcher(nil, testSecret, nil, log)
+	require.NoError(t, err)
+	d.retryDelays = nil
+	d.Dispatch(&Payload{Event: EventPublishFailed, JobID: "job1", AccountID: "acct"}, server.URL)
+	d.Close()
+
+	assert.Zero(t, atomic.LoadInt32(&calls))
+	records, _ := log.List(context.Background(), "job1", 0)
+	require.Len(t, records, 1)
+	assert.Contains(t, records[0].Error, "不允许访问")
+}
+
+func TestRequestSecretPerURL(t *testing.T) {
+	d, err := NewDispatcher(nil, testSecret, nil, &memoryLog{})
+	require.NoError(t, err)
+
+	a, err := d.RequestSecret("https://a.example.com/cb")
+	require.NoError(t, err)
+	b, err := d.RequestSecret("https://b.example.com/cb")
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b)
+	assert.NotEqual(t, testSecret, a)
+
+	again, _ := d.RequestSecret("https://a.example.com/cb")
+	assert.Equal(t, a, again)
+}
@@ -0,0 +1,61 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// defaultLogSize Redis 中保留的投递记录条数
+const defaultLogSize = 1000
+
+// RedisDeliveryLog 投递记录保存在 Redis 列表中，只保留最近 size 条
+type RedisDeliveryLog struct {
+	client *redis.Client
+	key    string
+	size   int64
+}
+
+// NewRedisDeliveryLog 创建 Redis 投递记录存储，key 为列表名
+func NewRedisDeliveryLog(client *redis.Client, key string) *RedisDeliveryLog {
+	return &RedisDeliveryLog{client: client, key: key, size: defaultLogSize}
+}
+
+// Append 追加投递记录
+func (l *RedisDeliveryLog) Append(ctx context.Context, d *Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return errors.Wrap(err, "序列化投递记录失败")
+	}
+	pipe := l.client.TxPipeline()
+	pipe.LPush(ctx, l.key, data)
+	pipe.LTrim(ctx, l.key, 0, l.size-1)
+	_, err = pipe.Exec(ctx)
+	return errors.Wrap(err, "保存投递记录失败")
+}
+
+// List 返回最近的投递记录，jobID 不为空时只返回该任务的记录
+func (l *RedisDeliveryLog) List(ctx context.Context, jobID string, limit int) ([]*Delivery, error) {
+	items, err := l.client.LRange(ctx, l.key, 0, l.size-1).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "读取投递记录失败")
+	}
+
+	list := make([]*Delivery, 0)
+	for _, item := range items {
+		var d Delivery
+		if err := json.Unmarshal([]byte(item), &d); err != nil {
+			continue
+		}
+		if jobID != "" && d.JobID != jobID {
+			continue
+		}
+		list = append(list, &d)
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+	}
+	return list, nil
+}
@@ -1,24 +1,403 @@
 package config
 
-// Config 应用配置
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Config 应用配置。默认值见 Default，可由 YAML 配置文件覆盖，
+// 再由环境变量（字段的 env 标签，值非空时生效）覆盖。
+// 带 reload:"restart" 标签的配置项（或整个分组）只在启动时生效，热更新时保留当前值；
+// 带 secret:"true" 标签的配置项在变更日志中不显示具体值
 type Config struct {
-	Username string // 登录用户名（可选，用于显示）
+	Server   ServerConfig   `yaml:"server"`
+	Redis    RedisConfig    `yaml:"redis" reload:"restart"`
+	Browser  BrowserConfig  `yaml:"browser" reload:"restart"`
+	XHS      XHSConfig      `yaml:"xhs"`
+	Images   ImageConfig    `yaml:"images"`
+	Cookies  CookieConfig   `yaml:"cookies" reload:"restart"`
+	Callback CallbackConfig `yaml:"callback" reload:"restart"`
+	Files    FilesConfig    `yaml:"files" reload:"restart"`
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	// Port 监听地址，如 ":6170"
+	Port string `yaml:"port" env:"SNS_POSTER_HTTP_PORT" reload:"restart"`
+	// DefaultAccountID 请求未指定账号时使用的账号，为空时请求必须指定账号
+	DefaultAccountID string `yaml:"default_account_id" env:"SNS_POSTER_DEFAULT_ACCOUNT_ID"`
+	// CORSOrigins 允许跨域访问 API 的来源，"*" 表示所有来源，为空时不允许跨域
+	CORSOrigins []string `yaml:"cors_origins" env:"SNS_POSTER_CORS_ORIGINS"`
+}
+
+// RedisConfig Redis 配置
+type RedisConfig struct {
+	Address  string `yaml:"address" env:"REDIS_ADDRESS"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `yaml:"db" env:"REDIS_DB"`
+	// QueueName Redis key 前缀（发布记录、cookies、回调记录等）
+	QueueName string `yaml:"queue_name" env:"SNS_POSTER_QUEUE_NAME"`
+}
+
+// BrowserConfig 远程浏览器配置
+type BrowserConfig struct {
+	// ManagerURL rod 浏览器管理器地址，为空时使用 rod 默认地址（ws://127.0.0.1:7317）
+	ManagerURL string `yaml:"manager_url" env:"SNS_POSTER_BROWSER_MANAGER_URL"`
+	// ContainerName 连接失败时重启的 Docker 容器，为空时不重启
+	ContainerName string `yaml:"container_name" env:"SNS_POSTER_BROWSER_CONTAINER"`
+	// ConnectTimeout 连接浏览器的超时时间
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"SNS_POSTER_BROWSER_CONNECT_TIMEOUT"`
+}
+
+// XHSConfig 小红书发布和账号维护配置
+type XHSConfig struct {
+	// PublishURL 创作中心图文发布页面
+	PublishURL string `yaml:"publish_url" env:"SNS_POSTER_XHS_PUBLISH_URL"`
+	// PublishTimeout 单次发布（打开页面到提交完成）的超时时间
+	PublishTimeout time.Duration `yaml:"publish_timeout" env:"SNS_POSTER_XHS_PUBLISH_TIMEOUT"`
+	// MaxImageCount 单篇笔记最多图片数，超出的图片被忽略
+	MaxImageCount int `yaml:"max_image_count" env:"SNS_POSTER_XHS_MAX_IMAGE_COUNT"`
+	// MaxImageSizeMB 单张图片最大大小
+	MaxImageSizeMB int `yaml:"max_image_size_mb" env:"SNS_POSTER_XHS_MAX_IMAGE_SIZE_MB"`
+	// MaxTitleWidth 标题最大长度，按小红书编辑器规则计数（英文1，中文、emoji、换行2），超出自动截取
+	MaxTitleWidth int `yaml:"max_title_width" env:"SNS_POSTER_XHS_MAX_TITLE_WIDTH"`
+	// MaxContentWidth 正文最大长度（包含末尾输入的标签），计数规则同标题，超出自动截取
+	MaxContentWidth int `yaml:"max_content_width" env:"SNS_POSTER_XHS_MAX_CONTENT_WIDTH"`
+	// TruncateEllipsis 标题、正文被截取时追加的省略号（计入长度），停在句末时不追加；为空表示不追加
+	TruncateEllipsis string `yaml:"truncate_ellipsis" env:"SNS_POSTER_XHS_TRUNCATE_ELLIPSIS"`
+
+	// ExtractHashtags 从正文中提取 #话题 加入标签并从正文中删除，请求的 extract_tags 优先
+	ExtractHashtags bool `yaml:"extract_hashtags" env:"SNS_POSTER_XHS_EXTRACT_HASHTAGS"`
+	// MaxTags 单篇笔记最多标签数，超出的标签被忽略
+	MaxTags int `yaml:"max_tags" env:"SNS_POSTER_XHS_MAX_TAGS"`
+	// TagSynonyms 话题同义词，首选话题名 -> 别名；标签与别名匹配（不区分大小写和全半角）时替换为首选话题名
+	TagSynonyms map[string][]string `yaml:"tag_synonyms"`
+	// UnmatchedTags 标签没有匹配到话题时的处理方式：keep 保留为普通文字，drop 从正文中删除，fail 取消发布
+	UnmatchedTags string `yaml:"unmatched_tags" env:"SNS_POSTER_XHS_UNMATCHED_TAGS"`
+	// TopicCacheTTL 话题联想查询结果在 Redis 中的缓存时间，0 表示不缓存
+	TopicCacheTTL time.Duration `yaml:"topic_cache_ttl" env:"SNS_POSTER_XHS_TOPIC_CACHE_TTL"`
+
+	// CaptchaRemoteView 遇到验证码时保留页面，交给人工通过远程处理接口完成验证
+	CaptchaRemoteView bool `yaml:"captcha_remote_view" env:"SNS_POSTER_CAPTCHA_REMOTE_VIEW"`
+
+	// HealthCheckInterval 账号登录健康检查周期，每轮检查分散在整个周期内，0 表示不启用
+	HealthCheckInterval time.Duration `yaml:"health_check_interval" env:"SNS_POSTER_HEALTH_CHECK_INTERVAL" reload:"restart"`
+
+	// SessionRefreshInterval 登录 session 过期检查周期，0 表示不启用自动刷新
+	SessionRefreshInterval time.Duration `yaml:"session_refresh_interval" env:"SNS_POSTER_SESSION_REFRESH_INTERVAL" reload:"restart"`
+	// SessionRefreshBefore 登录 cookie 距离过期小于该时长时访问小红书刷新
+	SessionRefreshBefore time.Duration `yaml:"session_refresh_before" env:"SNS_POSTER_SESSION_REFRESH_BEFORE" reload:"restart"`
+	// SessionExpiryWarnBefore 刷新后距离过期仍小于该时长时告警
+	SessionExpiryWarnBefore time.Duration `yaml:"session_expiry_warn_before" env:"SNS_POSTER_SESSION_EXPIRY_WARN_BEFORE" reload:"restart"`
+}
+
+// MaxImageSize 单张图片最大字节数
+func (c *XHSConfig) MaxImageSize() int64 {
+	return int64(c.MaxImageSizeMB) * 1024 * 1024
+}
+
+// ImageConfig 图片下载配置
+type ImageConfig struct {
+	// DownloadDir 下载图片的保存目录
+	DownloadDir string `yaml:"download_dir" env:"SNS_POSTER_IMAGE_DOWNLOAD_DIR"`
+	// DownloadTimeout 单张图片下载超时时间
+	DownloadTimeout time.Duration `yaml:"download_timeout" env:"SNS_POSTER_IMAGE_DOWNLOAD_TIMEOUT"`
+	// Normalize 上传前规范化图片：webp、gif 等转为 JPEG/PNG，按 EXIF 方向旋转，删除元数据，
+	// 缩小到 MaxDimension 以内，重新编码到不超过 xhs.max_image_size_mb
+	Normalize bool `yaml:"normalize" env:"SNS_POSTER_IMAGE_NORMALIZE"`
+	// MaxMegapixels 图片像素数上限（百万像素），超出时不解码直接拒绝，避免声明极大尺寸的图片占满内存
+	MaxMegapixels int `yaml:"max_megapixels" env:"SNS_POSTER_IMAGE_MAX_MEGAPIXELS"`
+	// MaxDimension 图片最长边的像素上限，超出时等比缩小
+	MaxDimension int `yaml:"max_dimension" env:"SNS_POSTER_IMAGE_MAX_DIMENSION"`
+	// MaxAspectRatio 长边与短边之比的上限，超出时在短边两侧补白，0 表示不限制
+	MaxAspectRatio float64 `yaml:"max_aspect_ratio" env:"SNS_POSTER_IMAGE_MAX_ASPECT_RATIO"`
+	// JPEGQuality 重新编码 JPEG 的初始质量（1-100），超过大小上限时逐步降低
+	JPEGQuality int `yaml:"jpeg_quality" env:"SNS_POSTER_IMAGE_JPEG_QUALITY"`
 }
 
-// 全局配置变量
-var globalConfig *Config
+// CookieConfig cookie 存储和加密配置
+type CookieConfig struct {
+	// Store 存储后端：file（每个账号一个文件）或 redis（多个实例共享）
+	Store string `yaml:"store" env:"SNS_POSTER_COOKIE_STORE"`
+	// Dir 文件存储的目录
+	Dir string `yaml:"dir" env:"SNS_POSTER_COOKIE_DIR"`
+	// Key 加密密钥（base64 或 hex 编码的 32 字节），优先于 KeyFile；都为空时明文保存
+	Key string `yaml:"key" env:"SNS_POSTER_COOKIE_KEY" secret:"true"`
+	// KeyFile 加密密钥文件
+	KeyFile string `yaml:"key_file" env:"SNS_POSTER_COOKIE_KEY_FILE"`
+	// PreviousKeys 轮换前的旧密钥，只用于解密
+	PreviousKeys []string `yaml:"previous_keys" env:"SNS_POSTER_COOKIE_PREVIOUS_KEYS" secret:"true"`
+}
+
+// CallbackConfig 发布结果回调配置
+type CallbackConfig struct {
+	// Secret 派生请求级 callback_url 签名密钥的主密钥（每个回调地址的密钥不同），为空时不支持 callback_url
+	Secret string `yaml:"secret" env:"SNS_POSTER_CALLBACK_SECRET" secret:"true"`
+	// AllowedHosts 请求级 callback_url 允许访问的内网主机名、IP 或网段（CIDR），默认拒绝本机、内网和链路本地地址
+	AllowedHosts []string `yaml:"allowed_hosts" env:"SNS_POSTER_CALLBACK_ALLOWED_HOSTS"`
+}
+
+// FilesConfig 其他配置文件的路径
+type FilesConfig struct {
+	Accounts  string `yaml:"accounts" env:"SNS_POSTER_ACCOUNTS_FILE"`
+	APIKeys   string `yaml:"api_keys" env:"SNS_POSTER_API_KEYS_FILE"`
+	Webhooks  string `yaml:"webhooks" env:"SNS_POSTER_WEBHOOKS_FILE"`
+	Callbacks string `yaml:"callbacks" env:"SNS_POSTER_CALLBACKS_FILE"`
+	Notify    string `yaml:"notify" env:"SNS_POSTER_NOTIFY_FILE"`
+	// SensitiveWords 敏感词配置，文件内容在配置重新加载时一起重新加载
+	SensitiveWords string `yaml:"sensitive_words" env:"SNS_POSTER_SENSITIVE_WORDS_FILE"`
+	// Templates 内容模板，可通过 API 修改，文件内容在配置重新加载时一起重新加载
+	Templates string `yaml:"templates" env:"SNS_POSTER_TEMPLATES_FILE"`
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port: ":6170",
+		},
+		Redis: RedisConfig{
+			Address:   "localhost:6379",
+			QueueName: "sns-poster",
+		},
+		Browser: BrowserConfig{
+			ContainerName:  "xhs-poster-rod",
+			ConnectTimeout: 10 * time.Second,
+		},
+		XHS: XHSConfig{
+			PublishURL:              "https://creator.xiaohongshu.com/publish/publish?source=official&from=menu&target=image",
+			PublishTimeout:          5 * time.Minute,
+			MaxImageCount:           18,
+			MaxImageSizeMB:          32,
+			MaxTitleWidth:           38,
+			MaxContentWidth:         1200,
+			TruncateEllipsis:        "…",
+			MaxTags:                 10,
+			UnmatchedTags:           "keep",
+			TopicCacheTTL:           6 * time.Hour,
+			HealthCheckInterval:     6 * time.Hour,
+			SessionRefreshInterval:  time.Hour,
+			SessionRefreshBefore:    72 * time.Hour,
+			SessionExpiryWarnBefore: 24 * time.Hour,
+		},
+		Images: ImageConfig{
+			DownloadDir:     "/tmp/xhs-poster",
+			DownloadTimeout: 30 * time.Second,
+			Normalize:       true,
+			MaxMegapixels:   50,
+			MaxDimension:    4096,
+			JPEGQuality:     90,
+		},
+		Cookies: CookieConfig{
+			Store: "file",
+			Dir:   "./cookies",
+		},
+		Files: FilesConfig{
+			Accounts:       "./accounts.json",
+			APIKeys:        "./api_keys.json",
+			Webhooks:       "./webhooks.json",
+			Callbacks:      "./callbacks.json",
+			Notify:         "./notify.json",
+			SensitiveWords: "./sensitive_words.json",
+			Templates:      "./templates.json",
+		},
+	}
+}
+
+// Load 加载配置：默认值 -> 配置文件（path 为空或文件不存在且 optional 时跳过）-> 环境变量，最后校验
+func Load(path string, optional bool) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err) && optional:
+		case err != nil:
+			return nil, errors.Wrap(err, "读取配置文件失败")
+		default:
+			dec := yaml.NewDecoder(bytes.NewReader(data))
+			// 拼错的配置项直接报错，避免静默使用默认值
+			dec.KnownFields(true)
+			if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+				return nil, errors.Wrapf(err, "解析配置文件 %s 失败", path)
+			}
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv 用环境变量覆盖带 env 标签的字段，空值不覆盖
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := t.Field(i).Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		raw := strings.TrimSpace(os.Getenv(name))
+		if raw == "" {
+			continue
+		}
+		if err := setField(field, raw); err != nil {
+			return errors.Wrapf(err, "环境变量 %s 格式错误", name)
+		}
+	}
+	return nil
+}
+
+// setField 按字段类型解析环境变量的值
+func setField(field reflect.Value, raw string) error {
+	switch field.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case string:
+		field.SetString(raw)
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case []string:
+		// 逗号分隔
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return errors.Errorf("不支持的类型 %s", field.Type())
+	}
+	return nil
+}
+
+// ValidationError 配置校验错误，包含所有不合法的配置项
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "配置不合法:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate 校验配置，返回所有不合法的配置项
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.Server.Port)
+	check(err == nil, "server.port 应为 host:port 或 :port 格式: %q", c.Server.Port)
+
+	check(c.Redis.Address != "", "redis.address 不能为空")
+	check(c.Redis.DB >= 0, "redis.db 不能为负数: %d", c.Redis.DB)
+	check(c.Redis.QueueName != "", "redis.queue_name 不能为空")
+
+	check(c.Browser.ConnectTimeout > 0, "browser.connect_timeout 必须大于 0")
+
+	u, err := url.Parse(c.XHS.PublishURL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		"xhs.publish_url 应为 http(s) 地址: %q", c.XHS.PublishURL)
+	check(c.XHS.PublishTimeout > 0, "xhs.publish_timeout 必须大于 0")
+	check(c.XHS.MaxImageCount >= 1 && c.XHS.MaxImageCount <= 18, "xhs.max_image_count 应在 1-18 之间: %d", c.XHS.MaxImageCount)
+	check(c.XHS.MaxImageSizeMB > 0, "xhs.max_image_size_mb 必须大于 0")
+	check(c.XHS.MaxTitleWidth > 0, "xhs.max_title_width 必须大于 0")
+	check(c.XHS.MaxContentWidth > 0, "xhs.max_content_width 必须大于 0")
+	check(c.XHS.MaxTags > 0, "xhs.max_tags 必须大于 0")
+	check(c.XHS.UnmatchedTags == "keep" || c.XHS.UnmatchedTags == "drop" || c.XHS.UnmatchedTags == "fail",
+		"xhs.unmatched_tags 只能是 keep、drop 或 fail")
+	check(c.XHS.TopicCacheTTL >= 0, "xhs.topic_cache_ttl 不能为负数")
+	check(c.XHS.HealthCheckInterval >= 0, "xhs.health_check_interval 不能为负数")
+	check(c.XHS.SessionRefreshInterval >= 0, "xhs.session_refresh_interval 不能为负数")
+	if c.XHS.SessionRefreshInterval > 0 {
+		check(c.XHS.SessionRefreshBefore > 0, "启用 session 刷新时 xhs.session_refresh_before 必须大于 0")
+		check(c.XHS.SessionExpiryWarnBefore >= 0, "xhs.session_expiry_warn_before 不能为负数")
+	}
+
+	check(c.Images.DownloadDir != "", "images.download_dir 不能为空")
+	check(c.Images.DownloadTimeout > 0, "images.download_timeout 必须大于 0")
+	check(c.Images.MaxMegapixels > 0, "images.max_megapixels 必须大于 0")
+	check(c.Images.MaxDimension > 0, "images.max_dimension 必须大于 0")
+	check(c.Images.MaxAspectRatio == 0 || c.Images.MaxAspectRatio >= 1, "images.max_aspect_ratio 必须为 0 或不小于 1")
+	check(c.Images.JPEGQuality >= 1 && c.Images.JPEGQuality <= 100, "images.jpeg_quality 必须在 1-100 之间")
+
+	switch c.Cookies.Store {
+	case "file":
+		check(c.Cookies.Dir != "", "cookies.store 为 file 时 cookies.dir 不能为空")
+	case "redis":
+	default:
+		problems = append(problems, fmt.Sprintf("cookies.store 应为 file 或 redis: %q", c.Cookies.Store))
+	}
+
+	check(c.Files.Accounts != "", "files.accounts 不能为空")
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// 全局配置变量，热更新时整体替换，已取得的配置不会被修改
+var globalConfig atomic.Pointer[Config]
 
 // InitConfig 初始化配置
 func InitConfig(config *Config) {
-	globalConfig = config
+	globalConfig.Store(config)
 }
 
-// GetConfig 获取配置
+// GetConfig 获取当前配置，未初始化时返回默认配置。
+// 一次操作中需要多个配置项时应只调用一次，保证使用同一份配置
 func GetConfig() *Config {
-	if globalConfig == nil {
-		return &Config{
-			Username: "",
-		}
+	if cfg := globalConfig.Load(); cfg != nil {
+		return cfg
 	}
-	return globalConfig
+	return Default()
 }
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig 在临时目录写入配置文件
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), true)
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, int64(32*1024*1024), cfg.XHS.MaxImageSize())
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"), false)
+	assert.Error(t, err)
+}
+
+func TestLoadFileAndEnvOverride(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: ":8080"
+  cors_origins: ["https://a.example.com"]
+redis:
+  queue_name: from-file
+xhs:
+  max_image_count: 9
+  publish_timeout: 2m
+`)
+	t.Setenv("SNS_POSTER_QUEUE_NAME", "from-env")
+	t.Setenv("SNS_POSTER_CORS_ORIGINS", "https://b.example.com, https://c.example.com")
+	t.Setenv("SNS_POSTER_HEALTH_CHECK_INTERVAL", "0")
+	t.Setenv("SNS_POSTER_CAPTCHA_REMOTE_VIEW", "true")
+	t.Setenv("REDIS_PASSWORD", "")
+
+	cfg, err := Load(path, false)
+	require.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Server.Port)
+	assert.Equal(t, "from-env", cfg.Redis.QueueName)
+	assert.Equal(t, []string{"https://b.example.com", "https://c.example.com"}, cfg.Server.CORSOrigins)
+	assert.Equal(t, 9, cfg.XHS.MaxImageCount)
+	assert.Equal(t, 2*time.Minute, cfg.XHS.PublishTimeout)
+	assert.Equal(t, time.Duration(0), cfg.XHS.HealthCheckInterval)
+	assert.True(t, cfg.XHS.CaptchaRemoteView)
+	// 未出现在配置文件中的配置项保留默认值
+	assert.Equal(t, Default().Images, cfg.Images)
+}
+
+func TestLoadRejectsUnknownFieldAndBadEnv(t *testing.T) {
+	_, err := Load(writeConfig(t, "xhs:\n  max_images: 9\n"), false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "max_images")
+
+	t.Setenv("SNS_POSTER_XHS_PUBLISH_TIMEOUT", "five minutes")
+	_, err = Load("", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SNS_POSTER_XHS_PUBLISH_TIMEOUT")
+}
+
+func TestValidateCollectsAllProblems(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: "6170"
+xhs:
+  max_image_count: 20
+cookies:
+  store: s3
+`)
+	_, err := Load(path, false)
+	require.Error(t, err)
+
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Len(t, verr.Problems, 3)
+	assert.Contains(t, err.Error(), "server.port")
+	assert.Contains(t, err.Error(), "xhs.max_image_count")
+	assert.Contains(t, err.Error(), "cookies.store")
+}
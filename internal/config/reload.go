@@ -0,0 +1,294 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultReloadDebounce 配置文件连续变化时等待写入完成的时间
+const defaultReloadDebounce = 500 * time.Millisecond
+
+// Change 一项配置的变化
+type Change struct {
+	Path string `json:"path"` // 配置项路径，如 xhs.max_image_count
+	Old  string `json:"old"`
+	New  string `json:"new"`
+	// Restart 只在启动时生效的配置项，本次热更新未应用
+	Restart bool `json:"restart,omitempty"`
+}
+
+// String 变更日志
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Path, c.Old, c.New)
+}
+
+// Diff 比较两份配置，返回所有变化的配置项
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	diffValue(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", false, &changes)
+	return changes
+}
+
+// diffValue 递归比较结构体字段
+func diffValue(old, new reflect.Value, prefix string, restart bool, changes *[]Change) {
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		path := fieldPath(prefix, field)
+		fieldRestart := restart || field.Tag.Get("reload") == "restart"
+
+		if field.Type.Kind() == reflect.Struct {
+			diffValue(old.Field(i), new.Field(i), path, fieldRestart, changes)
+			continue
+		}
+
+		o, n := old.Field(i).Interface(), new.Field(i).Interface()
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+		change := Change{Path: path, Old: fmt.Sprint(o), New: fmt.Sprint(n), Restart: fieldRestart}
+		if field.Tag.Get("secret") == "true" {
+			change.Old, change.New = "***", "***"
+		}
+		*changes = append(*changes, change)
+	}
+}
+
+// fieldPath 使用 yaml 标签拼接配置项路径
+func fieldPath(prefix string, field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// keepRestartFields 把只在启动时生效的配置项恢复为当前值
+func keepRestartFields(next, current reflect.Value, restart bool) {
+	t := next.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldRestart := restart || field.Tag.Get("reload") == "restart"
+		switch {
+		case fieldRestart:
+			next.Field(i).Set(current.Field(i))
+		case field.Type.Kind() == reflect.Struct:
+			keepRestartFields(next.Field(i), current.Field(i), false)
+		}
+	}
+}
+
+// ReloadHook 配置重新加载时执行的操作（如重新加载敏感词表）。
+// 先用新配置准备（读取、校验），所有准备都成功后才替换全局配置并调用返回的 commit 生效
+type ReloadHook func(cfg *Config) (commit func(), err error)
+
+// Reloader 重新加载配置文件并替换全局配置。
+// 新配置或任一 ReloadHook 校验失败时保留当前配置；进行中的操作继续使用已取得的旧配置，不受影响
+type Reloader struct {
+	path     string
+	optional bool
+	debounce time.Duration
+
+	mu sync.Mutex
+	// lastData 上次加载时配置文件和 WatchFile 添加的文件的内容，用于忽略内容未变化的文件事件
+	lastData map[string][]byte
+	hooks    []ReloadHook
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewReloader 创建配置重新加载器，path、optional 与启动时调用 Load 的参数相同
+func NewReloader(path string, optional bool) *Reloader {
+	r := &Reloader{
+		path:     path,
+		optional: optional,
+		debounce: defaultReloadDebounce,
+		lastData: make(map[string][]byte),
+	}
+	r.lastData[path], _ = os.ReadFile(path)
+	return r
+}
+
+// OnReload 注册配置重新加载时执行的操作，准备失败时重新加载失败并返回该错误
+func (r *Reloader) OnReload(hook ReloadHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// WatchFile 同时监听配置文件以外的文件（如敏感词配置），内容变化时重新加载配置（和所有 ReloadHook）。
+// 可以在 Watch 之前或之后调用
+func (r *Reloader) WatchFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.lastData[path]; ok {
+		return nil
+	}
+	r.lastData[path], _ = os.ReadFile(path)
+
+	if r.watcher != nil {
+		if err := r.watcher.Add(filepath.Dir(path)); err != nil {
+			return errors.Wrapf(err, "监听文件目录失败: %s", filepath.Dir(path))
+		}
+		logrus.Infof("监听文件变化: %s", path)
+	}
+	return nil
+}
+
+// Reload 重新加载配置文件（和环境变量），校验通过后替换全局配置，返回变化的配置项。
+// 只在启动时生效的配置项会记录在返回值中（Restart 为 true），但保留当前值
+func (r *Reloader) Reload() ([]Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data := r.readWatched()
+	next, err := Load(r.path, r.optional)
+	if err != nil {
+		logrus.Errorf("重新加载配置失败，继续使用当前配置: %v", err)
+		return nil, err
+	}
+
+	current := GetConfig()
+	changes := Diff(current, next)
+	keepRestartFields(reflect.ValueOf(next).Elem(), reflect.ValueOf(current).Elem(), false)
+
+	commits := make([]func(), 0, len(r.hooks))
+	for _, hook := range r.hooks {
+		commit, err := hook(next)
+		if err != nil {
+			logrus.Errorf("重新加载配置失败，继续使用当前配置: %v", err)
+			return nil, err
+		}
+		commits = append(commits, commit)
+	}
+
+	r.lastData = data
+	InitConfig(next)
+	for _, commit := range commits {
+		commit()
+	}
+
+	if len(changes) == 0 {
+		logrus.Info("配置已重新加载，没有变化")
+		return changes, nil
+	}
+	for _, change := range changes {
+		if change.Restart {
+			logrus.Warnf("配置变更需要重启才能生效: %s", change)
+		} else {
+			logrus.Infof("配置已更新: %s", change)
+		}
+	}
+	return changes, nil
+}
+
+// Watch 监听配置文件所在目录，文件内容变化时自动重新加载，直到调用 Close。
+// 监听目录而不是文件本身，编辑器保存时替换文件、Kubernetes ConfigMap 更新软链接都能感知
+func (r *Reloader) Watch() error {
+	if r.path == "" {
+		return errors.New("未指定配置文件")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "创建文件监听失败")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for path := range r.lastData {
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			watcher.Close()
+			return errors.Wrapf(err, "监听配置文件目录失败: %s", filepath.Dir(path))
+		}
+	}
+
+	r.watcher = watcher
+	r.done = make(chan struct{})
+	r.wg.Add(1)
+	go r.watchLoop()
+
+	logrus.Infof("监听配置文件变化: %s", r.path)
+	return nil
+}
+
+// watchLoop 合并短时间内的多个文件事件，等写入完成后再检查内容是否变化
+func (r *Reloader) watchLoop() {
+	defer r.wg.Done()
+
+	timer := time.NewTimer(r.debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case _, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			timer.Reset(r.debounce)
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			logrus.Warnf("监听配置文件出错: %v", err)
+		case <-timer.C:
+			if !r.fileChanged() {
+				continue
+			}
+			logrus.Info("配置文件已变化，重新加载")
+			_, _ = r.Reload()
+		}
+	}
+}
+
+// fileChanged 监听的文件内容是否与上次加载时不同（目录中其他文件的变化也会触发事件）
+func (r *Reloader) fileChanged() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for path, last := range r.readWatched() {
+		if !bytes.Equal(last, r.lastData[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// readWatched 读取所有监听的文件，调用方持有 r.mu
+func (r *Reloader) readWatched() map[string][]byte {
+	data := make(map[string][]byte, len(r.lastData))
+	for path := range r.lastData {
+		data[path], _ = os.ReadFile(path)
+	}
+	return data
+}
+
+// Close 停止监听配置文件
+func (r *Reloader) Close() {
+	if r.watcher == nil {
+		return
+	}
+	close(r.done)
+	r.watcher.Close()
+	r.wg.Wait()
+	r.watcher = nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadAppliesReloadableFields(t *testing.T) {
+	path := writeConfig(t, "xhs:\n  max_image_count: 9\n")
+	cfg, err := Load(path, false)
+	require.NoError(t, err)
+	InitConfig(cfg)
+	t.Cleanup(func() { InitConfig(Default()) })
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  port: ":9000"
+xhs:
+  max_image_count: 6
+  captcha_remote_view: true
+callback:
+  secret: "0123456789abcdef0123456789abcdef"
+`), 0600))
+
+	changes, err := NewReloader(path, false).Reload()
+	require.NoError(t, err)
+
+	current := GetConfig()
+	assert.NotSame(t, cfg, current)
+	assert.Equal(t, 6, current.XHS.MaxImageCount)
+	assert.True(t, current.XHS.CaptchaRemoteView)
+	// 只在启动时生效的配置项保留当前值
+	assert.Equal(t, ":6170", current.Server.Port)
+	assert.Empty(t, current.Callback.Secret)
+	// 已取得的旧配置不被修改
+	assert.Equal(t, 9, cfg.XHS.MaxImageCount)
+
+	byPath := map[string]Change{}
+	for _, change := range changes {
+		byPath[change.Path] = change
+	}
+	assert.Equal(t, Change{Path: "xhs.max_image_count", Old: "9", New: "6"}, byPath["xhs.max_image_count"])
+	assert.True(t, byPath["server.port"].Restart)
+	assert.Equal(t, Change{Path: "callback.secret", Old: "***", New: "***", Restart: true}, byPath["callback.secret"])
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	path := writeConfig(t, "xhs:\n  max_image_count: 9\n")
+	cfg, err := Load(path, false)
+	require.NoError(t, err)
+	InitConfig(cfg)
+	t.Cleanup(func() { InitConfig(Default()) })
+
+	require.NoError(t, os.WriteFile(path, []byte("xhs:\n  max_image_count: 0\n"), 0600))
+	_, err = NewReloader(path, false).Reload()
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Same(t, cfg, GetConfig())
+}
+
+func TestReloaderWatch(t *testing.T) {
+	path := writeConfig(t, "xhs:\n  max_title_width: 30\n")
+	cfg, err := Load(path, false)
+	require.NoError(t, err)
+	InitConfig(cfg)
+	t.Cleanup(func() { InitConfig(Default()) })
+
+	r := NewReloader(path, false)
+	r.debounce = 10 * time.Millisecond
+	require.NoError(t, r.Watch())
+	defer r.Close()
+
+	require.NoError(t, os.WriteFile(path, []byte("xhs:\n  max_title_width: 20\n"), 0600))
+	require.Eventually(t, func() bool {
+		return GetConfig().XHS.MaxTitleWidth == 20
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestReloadFailsWhenHookFails(t *testing.T) {
+	path := writeConfig(t, "xhs:\n  max_image_count: 9\n")
+	cfg, err := Load(path, false)
+	require.NoError(t, err)
+	InitConfig(cfg)
+	t.Cleanup(func() { InitConfig(Default()) })
+
+	r := NewReloader(path, false)
+	committed := false
+	r.OnReload(func(*Config) (func(), error) {
+		return func() { committed = true }, nil
+	})
+	r.OnReload(func(*Config) (func(), error) {
+		return nil, errors.New("敏感词配置错误")
+	})
+
+	require.NoError(t, os.WriteFile(path, []byte("xhs:\n  max_image_count: 6\n"), 0600))
+	_, err = r.Reload()
+	assert.EqualError(t, err, "敏感词配置错误")
+	// 任一操作失败时不替换配置，也不生效其他操作
+	assert.Same(t, cfg, GetConfig())
+	assert.False(t, committed)
+}
+
+func TestReloaderWatchFile(t *testing.T) {
+	path := writeConfig(t, "xhs:\n  max_title_width: 30\n")
+	cfg, err := Load(path, false)
+	require.NoError(t, err)
+	InitConfig(cfg)
+	t.Cleanup(func() { InitConfig(Default()) })
+
+	words := filepath.Join(t.TempDir(), "sensitive_words.json")
+	require.NoError(t, os.WriteFile(words, []byte("{}"), 0600))
+
+	r := NewReloader(path, false)
+	r.debounce = 10 * time.Millisecond
+	var reloads atomic.Int32
+	r.OnReload(func(*Config) (func(), error) {
+		return func() { reloads.Add(1) }, nil
+	})
+	require.NoError(t, r.Watch())
+	defer r.Close()
+	require.NoError(t, r.WatchFile(words))
+
+	require.NoError(t, os.WriteFile(words, []byte(`{"lists": []}`), 0600))
+	require.Eventually(t, func() bool {
+		return reloads.Load() == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,322 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"sns-poster/internal/webhook"
+
+	"github.com/pkg/errors"
+)
+
+// 渠道类型
+const (
+	TypeWecom    = "wecom"    // 企业微信群机器人
+	TypeDingTalk = "dingtalk" // 钉钉群机器人
+	TypeFeishu   = "feishu"   // 飞书/Lark 群机器人
+	TypeSlack    = "slack"    // Slack Incoming Webhook
+	TypeWebhook  = "webhook"  // 通用 webhook，POST JSON 格式的 Message
+	TypeEmail    = "email"    // SMTP 邮件
+)
+
+// wecomMaxImageSize 企业微信机器人图片最大 2MB（base64 编码前）
+const wecomMaxImageSize = 2 * 1024 * 1024
+
+// maxResponseSize 读取渠道响应的最大长度
+const maxResponseSize = 64 * 1024
+
+// ChannelConfig 渠道配置，不同类型使用不同字段
+type ChannelConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// 机器人 / webhook 地址
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// 钉钉、飞书的加签密钥；通用 webhook 的 HMAC 签名密钥（签名方式同 webhook 发布接口）
+	Secret string `json:"secret,omitempty"`
+	// 通用 webhook 附加的请求头
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// 邮件
+	SMTPHost string   `json:"smtp_host,omitempty"`
+	SMTPPort int      `json:"smtp_port,omitempty"` // 默认 587（STARTTLS），465 使用 TLS 直连
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+// Build 根据配置创建渠道
+func (c *ChannelConfig) Build() (Channel, error) {
+	if c.Name == "" {
+		return nil, errors.New("通知渠道名称不能为空")
+	}
+
+	if c.Type == TypeEmail {
+		return newEmailChannel(c)
+	}
+
+	if u, err := url.Parse(c.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.Errorf("通知渠道 %s 的 webhook_url 无效", c.Name)
+	}
+	base := httpChannel{name: c.Name, url: c.WebhookURL, client: &http.Client{Timeout: sendTimeout}}
+
+	switch c.Type {
+	case TypeWecom:
+		return &WecomChannel{httpChannel: base}, nil
+	case TypeDingTalk:
+		return &DingTalkChannel{httpChannel: base, secret: c.Secret}, nil
+	case TypeFeishu:
+		return &FeishuChannel{httpChannel: base, secret: c.Secret}, nil
+	case TypeSlack:
+		return &SlackChannel{httpChannel: base}, nil
+	case TypeWebhook:
+		return &WebhookChannel{httpChannel: base, secret: c.Secret, headers: c.Headers}, nil
+	default:
+		return nil, errors.Errorf("通知渠道 %s 的类型不支持: %s", c.Name, c.Type)
+	}
+}
+
+// httpChannel 基于 HTTP POST 的渠道
+type httpChannel struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// Name 渠道名称
+func (h *httpChannel) Name() string {
+	return h.name
+}
+
+// post 发送请求，非 2xx 响应返回错误，返回响应内容
+func (h *httpChannel) post(ctx context.Context, rawURL string, body []byte, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errors.Errorf("HTTP %d: %s", resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// postJSON 序列化 payload 后发送
+func (h *httpChannel) postJSON(ctx context.Context, rawURL string, payload any) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "序列化通知内容失败")
+	}
+	return h.post(ctx, rawURL, body, nil)
+}
+
+// WecomChannel 企业微信群机器人，支持发送图片
+// 也可以指向兼容企业微信消息格式的转发服务
+type WecomChannel struct {
+	httpChannel
+}
+
+// Send 发送文本，有图片时再发送一条图片消息
+func (w *WecomChannel) Send(ctx context.Context, msg *Message) error {
+	if err := w.send(ctx, map[string]any{
+		"msgtype": "text",
+		"text":    map[string]string{"content": msg.content()},
+	}); err != nil {
+		return err
+	}
+
+	if len(msg.Image) == 0 {
+		return nil
+	}
+	if len(msg.Image) > wecomMaxImageSize {
+		return errors.Errorf("图片超过企业微信限制: %d bytes", len(msg.Image))
+	}
+	sum := md5.Sum(msg.Image)
+	return w.send(ctx, map[string]any{
+		"msgtype": "image",
+		"image": map[string]string{
+			"base64": base64.StdEncoding.EncodeToString(msg.Image),
+			"md5":    hex.EncodeToString(sum[:]),
+		},
+	})
+}
+
+func (w *WecomChannel) send(ctx context.Context, payload any) error {
+	data, err := w.postJSON(ctx, w.url, payload)
+	if err != nil {
+		return err
+	}
+	return checkErrcode(data)
+}
+
+// DingTalkChannel 钉钉群机器人，配置加签密钥时对请求签名；不支持直接发送图片
+type DingTalkChannel struct {
+	httpChannel
+	secret string
+}
+
+// Send 发送文本消息
+func (d *DingTalkChannel) Send(ctx context.Context, msg *Message) error {
+	target := d.url
+	if d.secret != "" {
+		// 加签：HMAC-SHA256(secret, timestamp + "\n" + secret)，时间戳为毫秒
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(d.secret))
+		mac.Write([]byte(timestamp + "\n" + d.secret))
+		sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		u, err := url.Parse(d.url)
+		if err != nil {
+			return err
+		}
+		q := u.Query()
+		q.Set("timestamp", timestamp)
+		q.Set("sign", sign)
+		u.RawQuery = q.Encode()
+		target = u.String()
+	}
+
+	data, err := d.postJSON(ctx, target, map[string]any{
+		"msgtype": "text",
+		"text":    map[string]string{"content": withImageNote(msg)},
+	})
+	if err != nil {
+		return err
+	}
+	return checkErrcode(data)
+}
+
+// FeishuChannel 飞书/Lark 群机器人，配置签名校验密钥时对请求签名；不支持直接发送图片
+type FeishuChannel struct {
+	httpChannel
+	secret string
+}
+
+// Send 发送文本消息
+func (f *FeishuChannel) Send(ctx context.Context, msg *Message) error {
+	payload := map[string]any{
+		"msg_type": "text",
+		"content":  map[string]string{"text": withImageNote(msg)},
+	}
+	if f.secret != "" {
+		// 签名：以 timestamp + "\n" + secret 为密钥对空串做 HMAC-SHA256，时间戳为秒
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(timestamp+"\n"+f.secret))
+		payload["timestamp"] = timestamp
+		payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	data, err := f.postJSON(ctx, f.url, payload)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(data, &resp); err == nil && resp.Code != 0 {
+		return errors.Errorf("飞书返回错误 %d: %s", resp.Code, resp.Msg)
+	}
+	return nil
+}
+
+// SlackChannel Slack Incoming Webhook；不支持直接发送图片
+type SlackChannel struct {
+	httpChannel
+}
+
+// Send 发送文本消息
+func (s *SlackChannel) Send(ctx context.Context, msg *Message) error {
+	_, err := s.postJSON(ctx, s.url, map[string]string{"text": withImageNote(msg)})
+	return err
+}
+
+// WebhookChannel 通用 webhook：POST JSON 格式的 Message（图片为 base64），
+// 配置密钥时按 webhook 发布接口相同的方式签名
+type WebhookChannel struct {
+	httpChannel
+	secret  string
+	headers map[string]string
+}
+
+// Send 发送消息
+func (w *WebhookChannel) Send(ctx context.Context, msg *Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "序列化通知内容失败")
+	}
+
+	header := http.Header{}
+	for k, v := range w.headers {
+		header.Set(k, v)
+	}
+	header.Set("X-Notify-Event", msg.Event)
+	if w.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce := newNonce()
+		header.Set(webhook.HeaderSource, "sns-poster")
+		header.Set(webhook.HeaderTimestamp, timestamp)
+		header.Set(webhook.HeaderNonce, nonce)
+		header.Set(webhook.HeaderSignature, webhook.Sign(w.secret, timestamp, nonce, body))
+	}
+
+	_, err = w.post(ctx, w.url, body, header)
+	return err
+}
+
+// checkErrcode 检查企业微信、钉钉机器人响应中的 errcode；响应不是 JSON 时（如转发服务）以 HTTP 状态为准
+func checkErrcode(data []byte) error {
+	var resp struct {
+		Errcode *int   `json:"errcode"`
+		Errmsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Errcode == nil {
+		return nil
+	}
+	if *resp.Errcode != 0 {
+		return errors.Errorf("机器人返回错误 %d: %s", *resp.Errcode, resp.Errmsg)
+	}
+	return nil
+}
+
+// withImageNote 不支持图片的渠道在文字中提示图片未发送
+func withImageNote(msg *Message) string {
+	if len(msg.Image) == 0 {
+		return msg.content()
+	}
+	return fmt.Sprintf("%s\n（图片未随消息发送，请查看服务日志或其他渠道）", msg.content())
+}
+
+// newNonce 生成随机 nonce
+func newNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 邮件端口
+const (
+	defaultSMTPPort = 587 // STARTTLS
+	smtpsPort       = 465 // TLS 直连
+)
+
+// EmailChannel SMTP 邮件，图片作为附件发送
+type EmailChannel struct {
+	name     string
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// newEmailChannel 校验邮件配置并创建渠道
+func newEmailChannel(c *ChannelConfig) (*EmailChannel, error) {
+	if c.SMTPHost == "" {
+		return nil, errors.Errorf("通知渠道 %s 未配置 smtp_host", c.Name)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return nil, errors.Errorf("通知渠道 %s 的 from 无效: %q", c.Name, c.From)
+	}
+	if len(c.To) == 0 {
+		return nil, errors.Errorf("通知渠道 %s 未配置收件人", c.Name)
+	}
+	for _, to := range c.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return nil, errors.Errorf("通知渠道 %s 的收件人无效: %q", c.Name, to)
+		}
+	}
+
+	port := c.SMTPPort
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	return &EmailChannel{
+		name:     c.Name,
+		host:     c.SMTPHost,
+		port:     port,
+		username: c.Username,
+		password: c.Password,
+		from:     c.From,
+		to:       c.To,
+	}, nil
+}
+
+// Name 渠道名称
+func (e *EmailChannel) Name() string {
+	return e.name
+}
+
+// Send 发送邮件。非 465 端口在服务器支持时使用 STARTTLS；
+// 认证使用 PLAIN，net/smtp 只允许在 TLS 连接或本机上发送密码
+func (e *EmailChannel) Send(ctx context.Context, msg *Message) error {
+	data, err := e.buildMessage(msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(e.host, strconv.Itoa(e.port))
+	tlsConfig := &tls.Config{ServerName: e.host}
+	dialer := &net.Dialer{}
+
+	var conn net.Conn
+	if e.port == smtpsPort {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return errors.Wrap(err, "连接 SMTP 服务器失败")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return errors.Wrap(err, "连接 SMTP 服务器失败")
+	}
+	defer client.Close()
+
+	if e.port != smtpsPort {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return errors.Wrap(err, "SMTP STARTTLS 失败")
+			}
+		}
+	}
+	if e.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return errors.Wrap(err, "SMTP 认证失败")
+		}
+	}
+
+	from, _ := mail.ParseAddress(e.from)
+	if err := client.Mail(from.Address); err != nil {
+		return errors.Wrap(err, "SMTP 发件人被拒绝")
+	}
+	for _, to := range e.to {
+		addr, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(addr.Address); err != nil {
+			return errors.Wrapf(err, "SMTP 收件人 %s 被拒绝", addr.Address)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return errors.Wrap(err, "SMTP 发送邮件失败")
+	}
+	if _, err := w.Write(data); err != nil {
+		return errors.Wrap(err, "SMTP 发送邮件失败")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "SMTP 发送邮件失败")
+	}
+	return client.Quit()
+}
+
+// buildMessage 生成 MIME 邮件：正文为纯文本，图片作为附件
+func (e *EmailChannel) buildMessage(msg *Message, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	subject := msg.Title
+	if subject == "" {
+		subject = msg.Event
+	}
+
+	fmt.Fprintf(&buf, "From: %s\r\n", e.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64Lines(text, []byte(msg.content()))
+
+	if len(msg.Image) > 0 {
+		filename := "image" + imageExtension(msg.imageType())
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {fmt.Sprintf("%s; name=%q", msg.imageType(), filename)},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", filename)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64Lines(part, msg.Image)
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64Lines 按 76 字符换行写入 base64 内容
+func writeBase64Lines(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+// imageExtension 图片 MIME 类型对应的扩展名
+func imageExtension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".png"
+	}
+}
@@ -0,0 +1,224 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// 通知事件
+const (
+	EventQRCodeNeeded     = "qrcode_needed"      // 需要扫码登录
+	EventPublishFailed    = "publish_failed"     // 发布失败
+	EventAccountLoggedOut = "account_logged_out" // 账号掉线
+	EventSessionExpiring  = "session_expiring"   // 登录 session 即将过期
+)
+
+// AllEvents 路由中的通配符，表示所有事件
+const AllEvents = "*"
+
+// sendTimeout 单个渠道发送超时时间
+const sendTimeout = 15 * time.Second
+
+var validEvents = map[string]bool{
+	EventQRCodeNeeded:     true,
+	EventPublishFailed:    true,
+	EventAccountLoggedOut: true,
+	EventSessionExpiring:  true,
+}
+
+// Message 通知内容
+type Message struct {
+	Event     string `json:"event"`
+	AccountID string `json:"account_id,omitempty"`
+	Title     string `json:"title"`
+	Text      string `json:"text"`
+	// Image 附带的图片（如登录二维码），不支持图片的渠道只发送文字
+	Image     []byte `json:"image,omitempty"`
+	ImageType string `json:"image_type,omitempty"` // 图片 MIME 类型，默认 image/png
+}
+
+// content 标题和正文合并为纯文本
+func (m *Message) content() string {
+	if m.Title == "" {
+		return m.Text
+	}
+	if m.Text == "" {
+		return m.Title
+	}
+	return m.Title + "\n" + m.Text
+}
+
+// imageType 图片 MIME 类型
+func (m *Message) imageType() string {
+	if m.ImageType == "" {
+		return "image/png"
+	}
+	return m.ImageType
+}
+
+// Channel 通知渠道
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Config 通知配置：渠道列表和事件到渠道的路由
+type Config struct {
+	Channels []*ChannelConfig `json:"channels"`
+	// Routes 事件 -> 渠道名称列表，事件为 "*" 的路由适用于所有事件
+	Routes map[string][]string `json:"routes"`
+}
+
+// Notifier 按事件把通知发送到配置的渠道
+type Notifier struct {
+	channels map[string]Channel
+	routes   map[string][]string
+}
+
+// NewNotifier 创建通知器，校验路由中的事件和渠道
+func NewNotifier(channels []Channel, routes map[string][]string) (*Notifier, error) {
+	n := &Notifier{
+		channels: make(map[string]Channel),
+		routes:   routes,
+	}
+	for _, ch := range channels {
+		if _, ok := n.channels[ch.Name()]; ok {
+			return nil, errors.Errorf("通知渠道 %s 重复", ch.Name())
+		}
+		n.channels[ch.Name()] = ch
+	}
+	for event, names := range routes {
+		if event != AllEvents && !validEvents[event] {
+			return nil, errors.Errorf("通知事件不支持: %s", event)
+		}
+		for _, name := range names {
+			if _, ok := n.channels[name]; !ok {
+				return nil, errors.Errorf("通知事件 %s 的渠道 %s 未配置", event, name)
+			}
+		}
+	}
+	return n, nil
+}
+
+// LoadFile 从 JSON 文件加载通知配置，文件不存在时不发送任何通知
+func LoadFile(path string) (*Notifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewNotifier(nil, nil)
+		}
+		return nil, errors.Wrap(err, "读取通知配置失败")
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrap(err, "解析通知配置失败")
+	}
+
+	channels := make([]Channel, 0, len(cfg.Channels))
+	for _, c := range cfg.Channels {
+		ch, err := c.Build()
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, ch)
+	}
+
+	n, err := NewNotifier(channels, cfg.Routes)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("已加载通知配置: %s (%d 个渠道)", path, len(channels))
+	return n, nil
+}
+
+// Len 渠道数量
+func (n *Notifier) Len() int {
+	return len(n.channels)
+}
+
+// channelsFor 返回事件路由到的渠道（去重）
+func (n *Notifier) channelsFor(event string) []Channel {
+	seen := make(map[string]bool)
+	var list []Channel
+	for _, key := range []string{event, AllEvents} {
+		for _, name := range n.routes[key] {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			list = append(list, n.channels[name])
+		}
+	}
+	return list
+}
+
+// Send 同步发送到事件路由的所有渠道，返回所有失败渠道的错误
+func (n *Notifier) Send(ctx context.Context, msg *Message) error {
+	channels := n.channelsFor(msg.Event)
+	if len(channels) == 0 {
+		logrus.Debugf("通知事件 %s 未配置渠道，跳过: %s", msg.Event, msg.Title)
+		return nil
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []string
+	)
+	for _, ch := range channels {
+		wg.Add(1)
+		go func(ch Channel) {
+			defer wg.Done()
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			defer cancel()
+			if err := ch.Send(sendCtx, msg); err != nil {
+				mu.Lock()
+				failed = append(failed, ch.Name()+": "+err.Error())
+				mu.Unlock()
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return errors.Errorf("发送通知失败: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Notify 异步发送通知，失败只记录日志
+func (n *Notifier) Notify(msg *Message) {
+	go func() {
+		if err := n.Send(context.Background(), msg); err != nil {
+			logrus.Warnf("[Notify] %s: %v", msg.Event, err)
+		}
+	}()
+}
+
+var (
+	defaultNotifier = &Notifier{channels: map[string]Channel{}}
+	defaultMu       sync.RWMutex
+)
+
+// SetDefault 设置全局通知器
+func SetDefault(n *Notifier) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultNotifier = n
+}
+
+// Notify 通过全局通知器异步发送通知
+func Notify(msg *Message) {
+	defaultMu.RLock()
+	n := defaultNotifier
+	defaultMu.RUnlock()
+	n.Notify(msg)
+}
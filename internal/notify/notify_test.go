@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sns-poster/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordChannel 测试用渠道，记录收到的消息
+type recordChannel struct {
+	name string
+	got  []*Message
+	mu   sync.Mutex
+}
+
+func (r *recordChannel) Name() string { return r.name }
+
+func (r *recordChannel) Send(_ context.Context, msg *Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, msg)
+	return nil
+}
+
+func TestNotifierRoutes(t *testing.T) {
+	ops := &recordChannel{name: "ops"}
+	all := &recordChannel{name: "all"}
+	n, err := NewNotifier([]Channel{ops, all}, map[string][]string{
+		EventPublishFailed: {"ops", "all"},
+		AllEvents:          {"all"},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, n.Send(ctx, &Message{Event: EventPublishFailed, Title: "t"}))
+	require.NoError(t, n.Send(ctx, &Message{Event: EventQRCodeNeeded, Title: "q"}))
+
+	assert.Len(t, ops.got, 1)
+	// 同一渠道同时匹配具体事件和通配符时只发送一次
+	assert.Len(t, all.got, 2)
+
+	_, err = NewNotifier([]Channel{ops}, map[string][]string{EventPublishFailed: {"missing"}})
+	assert.Error(t, err)
+	_, err = NewNotifier([]Channel{ops}, map[string][]string{"unknown": {"ops"}})
+	assert.Error(t, err)
+}
+
+func TestWecomChannel(t *testing.T) {
+	var msgtypes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		msgtypes = append(msgtypes, body["msgtype"].(string))
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer server.Close()
+
+	ch, err := (&ChannelConfig{Name: "wecom", Type: TypeWecom, WebhookURL: server.URL}).Build()
+	require.NoError(t, err)
+	require.NoError(t, ch.Send(context.Background(), &Message{Title: "扫码", Image: []byte{1, 2, 3}}))
+	assert.Equal(t, []string{"text", "image"}, msgtypes)
+}
+
+func TestDingTalkChannelSignsAndChecksErrcode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.URL.Query().Get("sign"))
+		assert.NotEmpty(t, r.URL.Query().Get("timestamp"))
+		assert.Equal(t, "abc", r.URL.Query().Get("access_token"))
+		w.Write([]byte(`{"errcode":310000,"errmsg":"sign not match"}`))
+	}))
+	defer server.Close()
+
+	ch, err := (&ChannelConfig{Name: "ding", Type: TypeDingTalk, WebhookURL: server.URL + "?access_token=abc", Secret: "SEC"}).Build()
+	require.NoError(t, err)
+	err = ch.Send(context.Background(), &Message{Text: "hi"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "310000")
+}
+
+func TestWebhookChannelSigned(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	var got Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sig := webhook.Sign(secret, r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderNonce), body)
+		assert.Equal(t, sig, r.Header.Get(webhook.HeaderSignature))
+		assert.Equal(t, "v", r.Header.Get("X-Extra"))
+		require.NoError(t, json.Unmarshal(body, &got))
+	}))
+	defer server.Close()
+
+	ch, err := (&ChannelConfig{Name: "hook", Type: TypeWebhook, WebhookURL: server.URL, Secret: secret,
+		Headers: map[string]string{"X-Extra": "v"}}).Build()
+	require.NoError(t, err)
+	require.NoError(t, ch.Send(context.Background(), &Message{Event: EventAccountLoggedOut, AccountID: "a", Image: []byte("png")}))
+	assert.Equal(t, "a", got.AccountID)
+	assert.Equal(t, []byte("png"), got.Image)
+}
+
+func TestEmailBuildMessage(t *testing.T) {
+	ch, err := newEmailChannel(&ChannelConfig{Name: "mail", Type: TypeEmail, SMTPHost: "smtp.example.com",
+		From: "Poster <poster@example.com>", To: []string{"ops@example.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, defaultSMTPPort, ch.port)
+
+	data, err := ch.buildMessage(&Message{Title: "小红书登录二维码", Text: "请扫码", Image: []byte("png")}, time.Unix(0, 0))
+	require.NoError(t, err)
+	text := string(data)
+	assert.Contains(t, text, "Subject: =?UTF-8?b?")
+	assert.Contains(t, text, "To: ops@example.com")
+	assert.Contains(t, text, `filename="image.png"`)
+
+	_, err = newEmailChannel(&ChannelConfig{Name: "mail", SMTPHost: "h", From: "bad", To: []string{"a@b.c"}})
+	assert.Error(t, err)
+}
+
+func TestChannelConfigInvalid(t *testing.T) {
+	_, err := (&ChannelConfig{Name: "x", Type: "pager", WebhookURL: "https://example.com"}).Build()
+	assert.Error(t, err)
+	_, err = (&ChannelConfig{Name: "x", Type: TypeSlack, WebhookURL: "not a url"}).Build()
+	assert.True(t, err != nil && strings.Contains(err.Error(), "webhook_url"))
+}
@@ -0,0 +1,103 @@
+package sensitive
+
+import "unicode"
+
+// matcher Aho-Corasick 多模式匹配，一次扫描找出文本中所有词的出现位置，
+// 词表规模达到数千条时也只与文本长度线性相关。匹配不区分大小写
+type matcher struct {
+	nodes []acNode
+	words [][]rune
+}
+
+// acNode 字典树节点
+type acNode struct {
+	next map[rune]int
+	fail int
+	// out 在该节点结束的词（包括沿失败链可达的较短后缀词）
+	out []int
+}
+
+// match 一次匹配，start/end 为文本中的字符（rune）下标，左闭右开
+type match struct {
+	start, end int
+	word       int
+}
+
+// fold 匹配时统一为小写
+func fold(r rune) rune {
+	return unicode.ToLower(r)
+}
+
+// newMatcher 构建匹配器，空词被忽略
+func newMatcher(words []string) *matcher {
+	m := &matcher{nodes: []acNode{{next: map[rune]int{}}}}
+	for i, w := range words {
+		runes := []rune(w)
+		m.words = append(m.words, runes)
+		if len(runes) == 0 {
+			continue
+		}
+
+		cur := 0
+		for _, r := range runes {
+			r = fold(r)
+			nxt, ok := m.nodes[cur].next[r]
+			if !ok {
+				m.nodes = append(m.nodes, acNode{next: map[rune]int{}})
+				nxt = len(m.nodes) - 1
+				m.nodes[cur].next[r] = nxt
+			}
+			cur = nxt
+		}
+		m.nodes[cur].out = append(m.nodes[cur].out, i)
+	}
+
+	// BFS 计算失败指针，并把失败节点的输出合并到当前节点
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			f := m.nodes[cur].fail
+			for {
+				if nxt, ok := m.nodes[f].next[r]; ok {
+					m.nodes[child].fail = nxt
+					break
+				}
+				if f == 0 {
+					break
+				}
+				f = m.nodes[f].fail
+			}
+			m.nodes[child].out = append(m.nodes[child].out, m.nodes[m.nodes[child].fail].out...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// findAll 返回文本中所有词的出现位置（可能重叠）
+func (m *matcher) findAll(text []rune) []match {
+	var matches []match
+	cur := 0
+	for i, r := range text {
+		r = fold(r)
+		for {
+			if nxt, ok := m.nodes[cur].next[r]; ok {
+				cur = nxt
+				break
+			}
+			if cur == 0 {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+		for _, w := range m.nodes[cur].out {
+			matches = append(matches, match{start: i + 1 - len(m.words[w]), end: i + 1, word: w})
+		}
+	}
+	return matches
+}
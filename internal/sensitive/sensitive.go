@@ -0,0 +1,499 @@
+package sensitive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// 命中敏感词后的处理方式
+const (
+	ActionReject  = "reject"  // 拒绝发布
+	ActionMask    = "mask"    // 替换为 ***
+	ActionReplace = "replace" // 替换为同义词，未配置同义词时按 mask 处理
+)
+
+// 检查的字段
+const (
+	FieldTitle   = "title"
+	FieldContent = "content"
+	FieldTags    = "tags"
+)
+
+// maskText mask 方式的替换文本
+const maskText = "***"
+
+// actionSeverity 命中位置重叠时，处理更严格的词表优先
+var actionSeverity = map[string]int{
+	ActionMask:    1,
+	ActionReplace: 2,
+	ActionReject:  3,
+}
+
+var validFields = map[string]bool{
+	FieldTitle:   true,
+	FieldContent: true,
+	FieldTags:    true,
+}
+
+// ListConfig 词表配置
+type ListConfig struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	// Fields 检查的字段，为空时检查标题、正文和标签
+	Fields []string `json:"fields,omitempty"`
+	// Words 敏感词，replace 词表可写成 "词=替换词"
+	Words []string `json:"words,omitempty"`
+	// Replacements 敏感词 -> 替换词（replace 词表）
+	Replacements map[string]string `json:"replacements,omitempty"`
+	// Patterns 正则表达式，适合链接、联系方式等无法枚举的内容
+	Patterns []string `json:"patterns,omitempty"`
+	// Files 词表文件，每行一个词（格式同 Words），# 开头的行为注释
+	Files []string `json:"files,omitempty"`
+	// RedisKey 保存敏感词的 Redis set（格式同 Words），多个实例共享并可在线维护
+	RedisKey string `json:"redis_key,omitempty"`
+}
+
+// AccountOverride 账号级覆盖
+type AccountOverride struct {
+	// Disabled 不对该账号生效的词表
+	Disabled []string `json:"disabled,omitempty"`
+	// Actions 词表名 -> 该账号使用的处理方式
+	Actions map[string]string `json:"actions,omitempty"`
+	// Lists 只对该账号生效的额外词表
+	Lists []*ListConfig `json:"lists,omitempty"`
+}
+
+// Config 敏感词配置
+type Config struct {
+	Lists    []*ListConfig               `json:"lists"`
+	Accounts map[string]*AccountOverride `json:"accounts,omitempty"`
+}
+
+// DefaultConfig 未配置词表文件时使用，与原来内置的规则一致：
+// 标题包含"我的英雄学院"时拒绝发布，正文中的该词和链接替换为 ***
+func DefaultConfig() *Config {
+	return &Config{
+		Lists: []*ListConfig{
+			{Name: "builtin-title", Action: ActionReject, Fields: []string{FieldTitle}, Words: []string{"我的英雄学院"}},
+			{Name: "builtin-content", Action: ActionMask, Fields: []string{FieldContent},
+				Words: []string{"我的英雄学院"}, Patterns: []string{`https?://[^\s]+`}},
+		},
+	}
+}
+
+// Hit 一次命中，Start/End 为命中内容在原始字段中的字符（rune）下标，左闭右开
+type Hit struct {
+	List        string `json:"list"`
+	Action      string `json:"action"`
+	Field       string `json:"field"` // title、content 或 tags[i]
+	Word        string `json:"word"`  // 命中的原文
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// Input 待检查的内容
+type Input struct {
+	Title   string
+	Content string
+	Tags    []string
+}
+
+// Result 检查结果：处理后的内容和所有命中
+type Result struct {
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags,omitempty"`
+	Hits    []Hit    `json:"hits"`
+}
+
+// RejectedError 命中 reject 词表，拒绝发布
+type RejectedError struct {
+	Hits []Hit `json:"hits"`
+}
+
+func (e *RejectedError) Error() string {
+	words := make([]string, 0, len(e.Hits))
+	for _, h := range e.Hits {
+		words = append(words, fmt.Sprintf("%s(%s)", h.Word, h.Field))
+	}
+	return "内容包含禁止发布的敏感词: " + strings.Join(words, ", ")
+}
+
+// list 编译后的词表
+type list struct {
+	name         string
+	action       string
+	fields       map[string]bool
+	matcher      *matcher
+	replacements []string // 与 matcher 中的词一一对应
+	pattern      *regexp.Regexp
+}
+
+// ruleSet 一次加载的全部词表
+type ruleSet struct {
+	lists    []*list
+	accounts map[string]*accountRules
+}
+
+// accountRules 编译后的账号级覆盖
+type accountRules struct {
+	disabled map[string]bool
+	actions  map[string]string
+	lists    []*list
+}
+
+// Filter 敏感词过滤器，词表来自配置文件、词表文件和 Redis，重新加载时整体替换
+type Filter struct {
+	path  string
+	redis *redis.Client
+	rules atomic.Pointer[ruleSet]
+}
+
+// NewFilter 从配置文件加载词表，文件不存在时使用 DefaultConfig。redisClient 为空时不支持 redis_key
+func NewFilter(ctx context.Context, path string, redisClient *redis.Client) (*Filter, error) {
+	f := &Filter{path: path, redis: redisClient}
+	if err := f.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload 重新读取配置文件、词表文件和 Redis 中的词表，失败时继续使用当前词表
+func (f *Filter) Reload(ctx context.Context) error {
+	commit, err := f.PrepareReload(ctx)
+	if err != nil {
+		return err
+	}
+	commit()
+	return nil
+}
+
+// PrepareReload 读取并校验配置文件、词表文件和 Redis 中的词表，调用返回的 commit 后生效
+func (f *Filter) PrepareReload(ctx context.Context) (func(), error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(f.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, errors.Wrap(err, "读取敏感词配置失败")
+	default:
+		cfg = &Config{}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, errors.Wrap(err, "解析敏感词配置失败")
+		}
+	}
+
+	rules, err := f.compile(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		f.rules.Store(rules)
+
+		words := 0
+		for _, l := range rules.lists {
+			words += len(l.replacements)
+		}
+		logrus.Infof("已加载敏感词: %d 个词表，%d 个词", len(rules.lists), words)
+	}, nil
+}
+
+// compile 校验并编译配置
+func (f *Filter) compile(ctx context.Context, cfg *Config) (*ruleSet, error) {
+	rules := &ruleSet{accounts: make(map[string]*accountRules)}
+	names := make(map[string]bool)
+	for _, lc := range cfg.Lists {
+		l, err := f.compileList(ctx, lc)
+		if err != nil {
+			return nil, err
+		}
+		if names[l.name] {
+			return nil, errors.Errorf("敏感词表 %s 重复", l.name)
+		}
+		names[l.name] = true
+		rules.lists = append(rules.lists, l)
+	}
+
+	for accountID, o := range cfg.Accounts {
+		ar := &accountRules{disabled: make(map[string]bool), actions: make(map[string]string)}
+		for _, name := range o.Disabled {
+			if !names[name] {
+				return nil, errors.Errorf("账号 %s 停用的敏感词表 %s 不存在", accountID, name)
+			}
+			ar.disabled[name] = true
+		}
+		for name, action := range o.Actions {
+			if !names[name] {
+				return nil, errors.Errorf("账号 %s 覆盖的敏感词表 %s 不存在", accountID, name)
+			}
+			if _, ok := actionSeverity[action]; !ok {
+				return nil, errors.Errorf("账号 %s 的敏感词表 %s 处理方式不支持: %s", accountID, name, action)
+			}
+			ar.actions[name] = action
+		}
+		for _, lc := range o.Lists {
+			l, err := f.compileList(ctx, lc)
+			if err != nil {
+				return nil, errors.Wrapf(err, "账号 %s", accountID)
+			}
+			if names[l.name] {
+				return nil, errors.Errorf("账号 %s 的敏感词表 %s 与全局词表重名", accountID, l.name)
+			}
+			ar.lists = append(ar.lists, l)
+		}
+		rules.accounts[accountID] = ar
+	}
+	return rules, nil
+}
+
+// compileList 收集词表的所有词并构建匹配器
+func (f *Filter) compileList(ctx context.Context, lc *ListConfig) (*list, error) {
+	if lc.Name == "" {
+		return nil, errors.New("敏感词表名称不能为空")
+	}
+	if _, ok := actionSeverity[lc.Action]; !ok {
+		return nil, errors.Errorf("敏感词表 %s 的处理方式不支持: %q", lc.Name, lc.Action)
+	}
+
+	l := &list{name: lc.Name, action: lc.Action, fields: make(map[string]bool)}
+	for _, field := range lc.Fields {
+		if !validFields[field] {
+			return nil, errors.Errorf("敏感词表 %s 的字段不支持: %s", lc.Name, field)
+		}
+		l.fields[field] = true
+	}
+	if len(l.fields) == 0 {
+		for field := range validFields {
+			l.fields[field] = true
+		}
+	}
+
+	entries := append([]string{}, lc.Words...)
+	for _, path := range lc.Files {
+		lines, err := readWordFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "敏感词表 %s", lc.Name)
+		}
+		entries = append(entries, lines...)
+	}
+	if lc.RedisKey != "" {
+		if f.redis == nil {
+			return nil, errors.Errorf("敏感词表 %s 配置了 redis_key，但未连接 Redis", lc.Name)
+		}
+		members, err := f.redis.SMembers(ctx, lc.RedisKey).Result()
+		if err != nil {
+			return nil, errors.Wrapf(err, "读取敏感词表 %s 的 Redis 词表失败", lc.Name)
+		}
+		sort.Strings(members)
+		entries = append(entries, members...)
+	}
+
+	// 去重，保留第一次出现的替换词
+	index := make(map[string]int)
+	var words, replacements []string
+	add := func(word, replacement string) {
+		word = strings.TrimSpace(word)
+		if word == "" {
+			return
+		}
+		key := strings.ToLower(word)
+		if i, ok := index[key]; ok {
+			if replacements[i] == "" {
+				replacements[i] = replacement
+			}
+			return
+		}
+		index[key] = len(words)
+		words = append(words, word)
+		replacements = append(replacements, replacement)
+	}
+	for _, entry := range entries {
+		word, replacement, _ := strings.Cut(entry, "=")
+		add(word, strings.TrimSpace(replacement))
+	}
+	for word, replacement := range lc.Replacements {
+		add(word, replacement)
+	}
+	l.matcher = newMatcher(words)
+	l.replacements = replacements
+
+	if len(lc.Patterns) > 0 {
+		parts := make([]string, 0, len(lc.Patterns))
+		for _, p := range lc.Patterns {
+			if _, err := regexp.Compile(p); err != nil {
+				return nil, errors.Wrapf(err, "敏感词表 %s 的正则表达式无效: %s", lc.Name, p)
+			}
+			parts = append(parts, "(?:"+p+")")
+		}
+		l.pattern = regexp.MustCompile(strings.Join(parts, "|"))
+	}
+	return l, nil
+}
+
+// readWordFile 读取词表文件，忽略空行和 # 开头的注释
+func readWordFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取词表文件失败")
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "读取词表文件失败: %s", path)
+	}
+	return lines, nil
+}
+
+// activeList 对某个账号生效的词表及其处理方式
+type activeList struct {
+	*list
+	action string
+}
+
+// listsFor 返回对账号生效的词表
+func (r *ruleSet) listsFor(accountID string) []activeList {
+	ar := r.accounts[accountID]
+	var active []activeList
+	for _, l := range r.lists {
+		action := l.action
+		if ar != nil {
+			if ar.disabled[l.name] {
+				continue
+			}
+			if a, ok := ar.actions[l.name]; ok {
+				action = a
+			}
+		}
+		active = append(active, activeList{list: l, action: action})
+	}
+	if ar != nil {
+		for _, l := range ar.lists {
+			active = append(active, activeList{list: l, action: l.action})
+		}
+	}
+	return active
+}
+
+// Check 按账号生效的词表检查内容：命中 reject 词表时返回 *RejectedError（结果中仍包含所有命中），
+// 否则返回 mask/replace 处理后的内容
+func (f *Filter) Check(accountID string, in *Input) (*Result, error) {
+	lists := f.rules.Load().listsFor(accountID)
+
+	result := &Result{Hits: []Hit{}}
+	result.Title = checkField(lists, FieldTitle, FieldTitle, in.Title, &result.Hits)
+	result.Content = checkField(lists, FieldContent, FieldContent, in.Content, &result.Hits)
+	for i, tag := range in.Tags {
+		result.Tags = append(result.Tags, checkField(lists, FieldTags, fmt.Sprintf("tags[%d]", i), tag, &result.Hits))
+	}
+
+	var rejected []Hit
+	for _, h := range result.Hits {
+		if h.Action == ActionReject {
+			rejected = append(rejected, h)
+		}
+	}
+	if len(rejected) > 0 {
+		return result, &RejectedError{Hits: rejected}
+	}
+	return result, nil
+}
+
+// candidate 字段中的一次命中
+type candidate struct {
+	Hit
+	severity int
+}
+
+// checkField 检查一个字段：所有 reject 命中都会记录；mask/replace 命中重叠时
+// 取最靠前、最长、处理最严格的一个，然后替换
+func checkField(lists []activeList, field, label, text string, hits *[]Hit) string {
+	if text == "" {
+		return text
+	}
+	runes := []rune(text)
+
+	var candidates []candidate
+	for _, l := range lists {
+		if !l.fields[field] {
+			continue
+		}
+		add := func(start, end int, replacement string) {
+			if l.action == ActionMask || replacement == "" {
+				replacement = maskText
+			}
+			if l.action == ActionReject {
+				replacement = ""
+			}
+			candidates = append(candidates, candidate{
+				Hit: Hit{List: l.name, Action: l.action, Field: label, Word: string(runes[start:end]),
+					Start: start, End: end, Replacement: replacement},
+				severity: actionSeverity[l.action],
+			})
+		}
+		for _, m := range l.matcher.findAll(runes) {
+			add(m.start, m.end, l.replacements[m.word])
+		}
+		if l.pattern != nil {
+			for _, loc := range l.pattern.FindAllStringIndex(text, -1) {
+				if loc[0] == loc[1] {
+					continue
+				}
+				add(utf8.RuneCountInString(text[:loc[0]]), utf8.RuneCountInString(text[:loc[1]]), "")
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return text
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		if a.End != b.End {
+			return a.End > b.End
+		}
+		return a.severity > b.severity
+	})
+
+	var out strings.Builder
+	pos := 0
+	for _, c := range candidates {
+		if c.Action == ActionReject {
+			*hits = append(*hits, c.Hit)
+			continue
+		}
+		if c.Start < pos {
+			continue
+		}
+		out.WriteString(string(runes[pos:c.Start]))
+		out.WriteString(c.Replacement)
+		pos = c.End
+		*hits = append(*hits, c.Hit)
+	}
+	out.WriteString(string(runes[pos:]))
+	return out.String()
+}
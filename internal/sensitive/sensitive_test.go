@@ -0,0 +1,118 @@
+package sensitive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestFilter 把配置写入临时文件后加载
+func newTestFilter(t *testing.T, cfg *Config) *Filter {
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "sensitive_words.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	f, err := NewFilter(context.Background(), path, nil)
+	require.NoError(t, err)
+	return f
+}
+
+func TestMatcherFindsOverlappingWords(t *testing.T) {
+	m := newMatcher([]string{"he", "she", "his", "hers", "微信"})
+	var got []string
+	text := []rune("uShers 加微信")
+	for _, match := range m.findAll(text) {
+		got = append(got, fmt.Sprintf("%s@%d", string(text[match.start:match.end]), match.start))
+	}
+	assert.ElementsMatch(t, []string{"She@1", "he@2", "hers@2", "微信@8"}, got)
+}
+
+func TestCheckActions(t *testing.T) {
+	f := newTestFilter(t, &Config{Lists: []*ListConfig{
+		{Name: "links", Action: ActionMask, Fields: []string{FieldContent}, Patterns: []string{`https?://[^\s]+`}},
+		{Name: "brand", Action: ActionReplace, Words: []string{"微信=VX", "淘宝"}},
+		{Name: "ban", Action: ActionReject, Fields: []string{FieldTitle}, Words: []string{"违禁"}},
+	}})
+
+	result, err := f.Check("acct", &Input{Title: "标题", Content: "加微信或看 https://a.cn/x 淘宝", Tags: []string{"微信"}})
+	require.NoError(t, err)
+	assert.Equal(t, "加VX或看 *** ***", result.Content)
+	assert.Equal(t, []string{"VX"}, result.Tags)
+	require.Len(t, result.Hits, 4)
+	assert.Equal(t, Hit{List: "brand", Action: ActionReplace, Field: FieldContent, Word: "微信", Start: 1, End: 3, Replacement: "VX"}, result.Hits[0])
+	assert.Equal(t, "tags[0]", result.Hits[3].Field)
+
+	// 只检查配置的字段
+	result, err = f.Check("acct", &Input{Title: "违禁标题", Content: "违禁"})
+	var rejected *RejectedError
+	require.ErrorAs(t, err, &rejected)
+	require.Len(t, rejected.Hits, 1)
+	assert.Equal(t, FieldTitle, rejected.Hits[0].Field)
+	assert.Equal(t, "违禁", result.Content)
+}
+
+func TestCheckAccountOverrides(t *testing.T) {
+	dir := t.TempDir()
+	wordFile := filepath.Join(dir, "extra.txt")
+	require.NoError(t, os.WriteFile(wordFile, []byte("# 注释\n竞品\n\n"), 0600))
+
+	f := newTestFilter(t, &Config{
+		Lists: []*ListConfig{
+			{Name: "brand", Action: ActionMask, Words: []string{"微信"}},
+			{Name: "links", Action: ActionMask, Patterns: []string{`https?://\S+`}},
+		},
+		Accounts: map[string]*AccountOverride{
+			"vip": {
+				Disabled: []string{"links"},
+				Actions:  map[string]string{"brand": ActionReject},
+				Lists:    []*ListConfig{{Name: "extra", Action: ActionMask, Files: []string{wordFile}}},
+			},
+		},
+	})
+
+	result, err := f.Check("other", &Input{Content: "竞品 https://a.cn"})
+	require.NoError(t, err)
+	assert.Equal(t, "竞品 ***", result.Content)
+
+	result, err = f.Check("vip", &Input{Content: "竞品 https://a.cn"})
+	require.NoError(t, err)
+	assert.Equal(t, "*** https://a.cn", result.Content)
+
+	_, err = f.Check("vip", &Input{Content: "微信"})
+	assert.Error(t, err)
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	for _, cfg := range []*Config{
+		{Lists: []*ListConfig{{Name: "a", Action: "drop"}}},
+		{Lists: []*ListConfig{{Name: "a", Action: ActionMask, Patterns: []string{"("}}}},
+		{Lists: []*ListConfig{{Name: "a", Action: ActionMask, Fields: []string{"body"}}}},
+		{Lists: []*ListConfig{{Name: "a", Action: ActionMask, RedisKey: "k"}}},
+		{Lists: []*ListConfig{{Name: "a", Action: ActionMask}}, Accounts: map[string]*AccountOverride{"x": {Disabled: []string{"b"}}}},
+	} {
+		data, _ := json.Marshal(cfg)
+		path := filepath.Join(t.TempDir(), "sensitive_words.json")
+		require.NoError(t, os.WriteFile(path, data, 0600))
+		_, err := NewFilter(context.Background(), path, nil)
+		assert.Error(t, err, string(data))
+	}
+}
+
+func TestDefaultConfigKeepsBuiltinRules(t *testing.T) {
+	f, err := NewFilter(context.Background(), filepath.Join(t.TempDir(), "missing.json"), nil)
+	require.NoError(t, err)
+
+	result, err := f.Check("acct", &Input{Title: "标题", Content: "我的英雄学院 http://x.cn"})
+	require.NoError(t, err)
+	assert.Equal(t, "*** ***", result.Content)
+
+	_, err = f.Check("acct", &Input{Title: "我的英雄学院", Content: "正文"})
+	assert.Error(t, err)
+}
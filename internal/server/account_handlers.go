@@ -0,0 +1,246 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"sns-poster/internal/account"
+	"sns-poster/internal/config"
+	"sns-poster/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sirupsen/logrus"
+)
+
+// resolveAccount 校验 API 密钥有该账号的权限，且账号已注册并已启用，失败时写入错误响应并返回 false
+func (s *HTTPServer) resolveAccount(c *gin.Context, accountID string) (*account.Account, bool) {
+	if !s.authorizeAccount(c, accountID) {
+		return nil, false
+	}
+
+	a, err := s.xhsService.Accounts().Resolve(accountID)
+	if err == nil {
+		return a, true
+	}
+
+	switch {
+	case errors.Is(err, account.ErrAccountNotFound):
+		s.respondError(c, http.StatusNotFound, "ACCOUNT_NOT_FOUND",
+			"账号未注册", err.Error())
+	case errors.Is(err, account.ErrAccountDisabled):
+		s.respondError(c, http.StatusForbidden, "ACCOUNT_DISABLED",
+			"账号已停用", err.Error())
+	default:
+		s.respondError(c, http.StatusInternalServerError, "ACCOUNT_LOOKUP_FAILED",
+			"查询账号失败", err.Error())
+	}
+	return nil, false
+}
+
+// respondAccountError 返回账号注册表操作的错误响应
+func (s *HTTPServer) respondAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, account.ErrAccountNotFound):
+		s.respondError(c, http.StatusNotFound, "ACCOUNT_NOT_FOUND",
+			"账号未注册", err.Error())
+	case errors.Is(err, account.ErrAccountExists):
+		s.respondError(c, http.StatusConflict, "ACCOUNT_EXISTS",
+			"账号已存在", err.Error())
+	default:
+		s.respondError(c, http.StatusBadRequest, "INVALID_ACCOUNT",
+			"账号信息不正确", err.Error())
+	}
+}
+
+// listAccountsHandler 列出所有已注册账号
+func (s *HTTPServer) listAccountsHandler(c *gin.Context) {
+	s.respondSuccess(c, filterAllowedAccounts(c, s.xhsService.Accounts().List()), "获取账号列表成功")
+}
+
+// filterAllowedAccounts 只保留当前请求的密钥有权限的账号
+func filterAllowedAccounts(c *gin.Context, accounts []*account.Account) []*account.Account {
+	list := make([]*account.Account, 0, len(accounts))
+	for _, a := range accounts {
+		if accountAllowed(c, a.ID) {
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+// getAccountHandler 获取账号详情
+func (s *HTTPServer) getAccountHandler(c *gin.Context) {
+	if !s.authorizeAccount(c, c.Param("id")) {
+		return
+	}
+	a, err := s.xhsService.Accounts().Get(c.Param("id"))
+	if err != nil {
+		s.respondAccountError(c, err)
+		return
+	}
+	s.respondSuccess(c, a, "获取账号成功")
+}
+
+// CreateAccountRequest 注册账号请求
+type CreateAccountRequest struct {
+	ID                string `json:"id" binding:"required"`
+	DisplayName       string `json:"display_name"`
+	XHSUserID         string `json:"xhs_user_id"`
+	OwnerTeam         string `json:"owner_team"`
+	Proxy             string `json:"proxy"`
+	DailyPublishLimit int    `json:"daily_publish_limit"`
+	Enabled           *bool  `json:"enabled"` // 默认启用
+}
+
+// createAccountHandler 注册账号
+func (s *HTTPServer) createAccountHandler(c *gin.Context) {
+	var req CreateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"请求参数错误", err.Error())
+		return
+	}
+
+	if !s.authorizeAccount(c, req.ID) {
+		return
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	a, err := s.xhsService.Accounts().Create(&account.Account{
+		ID:                req.ID,
+		DisplayName:       req.DisplayName,
+		XHSUserID:         req.XHSUserID,
+		OwnerTeam:         req.OwnerTeam,
+		Proxy:             req.Proxy,
+		DailyPublishLimit: req.DailyPublishLimit,
+		Enabled:           enabled,
+	})
+	if err != nil {
+		s.respondAccountError(c, err)
+		return
+	}
+
+	logrus.Infof("注册账号: %s (%s)", a.ID, a.DisplayName)
+	s.respondSuccess(c, a, "注册账号成功")
+}
+
+// updateAccountHandler 更新账号信息，只修改请求中出现的字段
+func (s *HTTPServer) updateAccountHandler(c *gin.Context) {
+	if !s.authorizeAccount(c, c.Param("id")) {
+		return
+	}
+
+	var req account.Update
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"请求参数错误", err.Error())
+		return
+	}
+
+	a, err := s.xhsService.Accounts().Update(c.Param("id"), &req)
+	if err != nil {
+		s.respondAccountError(c, err)
+		return
+	}
+
+	logrus.Infof("更新账号: %s", a.ID)
+	s.respondSuccess(c, a, "更新账号成功")
+}
+
+// deleteAccountHandler 删除账号，同时删除该账号保存的 cookies
+func (s *HTTPServer) deleteAccountHandler(c *gin.Context) {
+	accountID := c.Param("id")
+	if !s.authorizeAccount(c, accountID) {
+		return
+	}
+	if err := s.xhsService.Accounts().Delete(accountID); err != nil {
+		s.respondAccountError(c, err)
+		return
+	}
+
+	if result, err := s.xhsService.Logout(c.Request.Context(), accountID); err != nil {
+		logrus.Warnf("删除账号 %s 的 cookies 失败: %v", accountID, err)
+	} else if !result.Success {
+		logrus.Warnf("删除账号 %s 的 cookies 失败: %s", accountID, result.Message)
+	}
+
+	logrus.Infof("删除账号: %s", accountID)
+	s.respondSuccess(c, nil, "删除账号成功")
+}
+
+// accountIDSource 请求中的一个账号ID来源
+type accountIDSource struct {
+	name  string
+	value string
+}
+
+// accountIDFromRequest 解析请求的账号ID，失败时写入错误响应并返回 false
+// 按 Query account_id > Header X-Account-ID > Body account_id > 配置的默认账号 的顺序取值；
+// 请求中出现多个来源时必须一致，否则拒绝（ACCOUNT_ID_MISMATCH）；都没有且未配置默认账号时返回 MISSING_ACCOUNT_ID
+// 读取 body 使用 ShouldBindBodyWith，后续 handler 需要用 ShouldBindBodyWith 再次解析 body。
+// webhook 请求的签名只覆盖 body，改由 webhookAccountID 解析
+func (s *HTTPServer) accountIDFromRequest(c *gin.Context) (string, bool) {
+	if v, ok := c.Get(webhookSourceContextKey); ok {
+		if source, ok := v.(*webhook.Source); ok {
+			return s.webhookAccountID(c, source)
+		}
+	}
+
+	sources := []accountIDSource{
+		{name: "query", value: c.Query("account_id")},
+		{name: "header", value: c.GetHeader("X-Account-ID")},
+		{name: "body", value: bodyAccountID(c)},
+	}
+
+	var (
+		accountID string
+		from      string
+	)
+	for _, source := range sources {
+		if source.value == "" {
+			continue
+		}
+		if accountID == "" {
+			accountID, from = source.value, source.name
+			continue
+		}
+		if source.value != accountID {
+			s.respondError(c, http.StatusBadRequest, "ACCOUNT_ID_MISMATCH",
+				fmt.Sprintf("%s 与 %s 中的账号ID不一致", from, source.name),
+				map[string]string{from: accountID, source.name: source.value})
+			return "", false
+		}
+	}
+
+	if accountID == "" {
+		accountID = config.GetConfig().Server.DefaultAccountID
+	}
+	if accountID == "" {
+		s.respondError(c, http.StatusBadRequest, "MISSING_ACCOUNT_ID",
+			"缺少账号ID，请通过 Query account_id、Header X-Account-ID 或 Body account_id 指定", nil)
+		return "", false
+	}
+	return accountID, true
+}
+
+// bodyAccountID 读取 body 中的 account_id，body 为空或不能按 JSON 解析时返回空字符串。
+// 不检查 Content-Type：handler 用 ShouldBindBodyWith 按 JSON 解析 body 时也不检查，
+// 这里跳过的 body 中的账号ID 会绕过一致性校验
+func bodyAccountID(c *gin.Context) string {
+	if c.Request.Body == nil || c.Request.ContentLength == 0 {
+		return ""
+	}
+
+	var body struct {
+		AccountID string `json:"account_id"`
+	}
+	// 解析失败由 handler 绑定请求时报告
+	_ = c.ShouldBindBodyWith(&body, binding.JSON)
+	return body.AccountID
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"sns-poster/internal/apikey"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// apiKeyContextKey gin context 中保存已认证密钥的 key
+const apiKeyContextKey = "api_key"
+
+// requireScope API 密钥认证中间件：校验 Authorization: Bearer <key>，并要求密钥具有 scope 权限
+// 账号级别的权限在解析出账号后由 authorizeAccount 校验
+func (s *HTTPServer) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="sns-poster"`)
+			s.respondError(c, http.StatusUnauthorized, "UNAUTHORIZED",
+				"缺少 API 密钥，请通过 Authorization: Bearer <key> 传递", nil)
+			c.Abort()
+			return
+		}
+
+		key, err := s.apiKeys.Authenticate(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="sns-poster", error="invalid_token"`)
+			s.respondError(c, http.StatusUnauthorized, "UNAUTHORIZED", err.Error(), nil)
+			c.Abort()
+			return
+		}
+
+		if !key.HasScope(scope) {
+			s.respondError(c, http.StatusForbidden, "SCOPE_FORBIDDEN",
+				"API 密钥没有该操作的权限", map[string]string{"key": key.Name, "required_scope": scope})
+			c.Abort()
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		logrus.Debugf("[Auth] 密钥 %s 通过认证（%s）", key.Name, scope)
+		c.Next()
+	}
+}
+
+// bearerToken 解析 Authorization: Bearer <token>
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// requestKey 返回当前请求已认证的密钥，未经过 requireScope 时返回 nil
+func requestKey(c *gin.Context) *apikey.Key {
+	if v, ok := c.Get(apiKeyContextKey); ok {
+		if key, ok := v.(*apikey.Key); ok {
+			return key
+		}
+	}
+	return nil
+}
+
+// accountAllowed 当前请求的密钥是否允许操作该账号
+func accountAllowed(c *gin.Context, accountID string) bool {
+	key := requestKey(c)
+	return key != nil && key.AllowsAccount(accountID)
+}
+
+// authorizeAccount 校验当前请求的密钥允许操作该账号，失败时写入错误响应并返回 false
+func (s *HTTPServer) authorizeAccount(c *gin.Context, accountID string) bool {
+	if accountAllowed(c, accountID) {
+		return true
+	}
+
+	name := ""
+	if key := requestKey(c); key != nil {
+		name = key.Name
+	}
+	s.respondError(c, http.StatusForbidden, "ACCOUNT_FORBIDDEN",
+		"API 密钥没有该账号的权限", map[string]string{"key": name, "account_id": accountID})
+	return false
+}
+
+// authorizeRemoteView 校验远程处理会话存在且所属账号在密钥权限内，失败时写入错误响应并返回 false
+func (s *HTTPServer) authorizeRemoteView(c *gin.Context) bool {
+	session, err := s.xhsService.GetRemoteView(c.Param("id"))
+	if err != nil {
+		s.respondError(c, http.StatusNotFound, "REMOTE_VIEW_NOT_FOUND", err.Error(), nil)
+		return false
+	}
+	return s.authorizeAccount(c, session.AccountID)
+}
+
+// authorizeSMSSession 校验短信登录会话存在且所属账号在密钥权限内，失败时写入错误响应并返回 false
+func (s *HTTPServer) authorizeSMSSession(c *gin.Context) bool {
+	accountID, err := s.xhsService.SMSSessionAccountID(c.Param("id"))
+	if err != nil {
+		s.respondError(c, http.StatusNotFound, "XHS_LOGIN_SESSION_NOT_FOUND", err.Error(), nil)
+		return false
+	}
+	return s.authorizeAccount(c, accountID)
+}
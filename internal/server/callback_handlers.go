@@ -0,0 +1,354 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sns-poster/internal/apikey"
+	"sns-poster/internal/callback"
+	"sns-poster/internal/notify"
+	"sns-poster/internal/sensitive"
+	"sns-poster/internal/xhs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// asyncPublishTimeout 后台发布任务的超时时间
+const asyncPublishTimeout = 10 * time.Minute
+
+// maxDeliveriesLimit 查询投递记录的最大条数
+const maxDeliveriesLimit = 200
+
+// jobCancelGrace 关闭服务时取消后台发布任务后，等待任务推送失败回调的时间
+const jobCancelGrace = 10 * time.Second
+
+// ErrCodePublishCanceled 发布任务被取消（服务关闭或请求中断）的错误码
+const ErrCodePublishCanceled = "PUBLISH_CANCELED"
+
+// publishJobs 跟踪后台发布任务：关闭服务时等待任务完成，超时后取消任务，
+// 取消后仍未结束的任务直接推送失败回调，任务之后结束时不再推送
+type publishJobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// running 运行中的任务及其失败回调内容
+	running map[string]*pendingJob
+	// abandoned 关闭服务时已推送失败回调的任务
+	abandoned map[string]bool
+}
+
+type pendingJob struct {
+	payload     *callback.Payload
+	callbackURL string
+}
+
+func newPublishJobs() *publishJobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &publishJobs{
+		ctx:       ctx,
+		cancel:    cancel,
+		running:   make(map[string]*pendingJob),
+		abandoned: make(map[string]bool),
+	}
+}
+
+// add 登记后台任务，任务结束时调用 wg.Done
+func (j *publishJobs) add(jobID string, payload *callback.Payload, callbackURL string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.running[jobID] = &pendingJob{payload: payload, callbackURL: callbackURL}
+	j.wg.Add(1)
+}
+
+// claim 任务结束，返回 false 表示关闭服务时已推送过失败回调，不再推送结果。未登记的任务（同步发布）返回 true
+func (j *publishJobs) claim(jobID string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.running, jobID)
+	return !j.abandoned[jobID]
+}
+
+// abandon 取出所有运行中的任务并标记为已推送
+func (j *publishJobs) abandon() []*pendingJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	list := make([]*pendingJob, 0, len(j.running))
+	for id, job := range j.running {
+		list = append(list, job)
+		j.abandoned[id] = true
+		delete(j.running, id)
+	}
+	return list
+}
+
+// wait 等待所有任务结束，超时返回 false
+func (j *publishJobs) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// WaitPublishJobs 关闭服务时等待后台发布任务结束：超过 timeout 时取消任务（取消的任务推送失败回调），
+// 再等待 jobCancelGrace 后仍未结束的任务直接推送失败回调。应在关闭 HTTP 服务之后、关闭回调投递之前调用
+func (s *HTTPServer) WaitPublishJobs(timeout time.Duration) {
+	if s.jobs.wait(timeout) {
+		return
+	}
+
+	logrus.Warnf("[Publish] 后台发布任务 %s 内未完成，取消任务", timeout)
+	s.jobs.cancel()
+	if s.jobs.wait(jobCancelGrace) {
+		return
+	}
+
+	for _, job := range s.jobs.abandon() {
+		payload := *job.payload
+		payload.Event = callback.EventPublishFailed
+		payload.Error = "服务关闭，发布任务被中断"
+		payload.Code = ErrCodePublishCanceled
+		logrus.Errorf("[Publish] 任务 %s 未能在服务关闭前结束", payload.JobID)
+		s.callbacks.Dispatch(&payload, job.callbackURL)
+		notifyPublishFailed(&payload)
+	}
+}
+
+// reservePublishSlotScript 递增今日发布次数（保留两天，跨天后自动过期），
+// 超过上限（ARGV[1] 大于 0 时）则撤销并返回 -1
+var reservePublishSlotScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+local limit = tonumber(ARGV[1])
+if limit > 0 and count > limit then
+	redis.call('DECR', KEYS[1])
+	return -1
+end
+return count
+`)
+
+// publishSlot 预占的一次今日发布次数，发布成功后保留，失败时释放，只处理一次
+type publishSlot struct {
+	client   *redis.Client
+	countKey string
+	done     atomic.Bool
+}
+
+// reservePublishSlot 原子地预占一次今日发布次数，limit 不大于 0 时不限制；超过上限返回 nil
+func (s *HTTPServer) reservePublishSlot(ctx context.Context, countKey string, limit int) (*publishSlot, error) {
+	count, err := reservePublishSlotScript.Run(ctx, s.redisClient, []string{countKey},
+		limit, int((48 * time.Hour).Seconds())).Int()
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, nil
+	}
+	return &publishSlot{client: s.redisClient, countKey: countKey}, nil
+}
+
+// keep 发布成功，保留预占的次数
+func (p *publishSlot) keep() {
+	p.done.Store(true)
+}
+
+// release 发布失败，释放预占的次数
+func (p *publishSlot) release() {
+	if !p.done.CompareAndSwap(false, true) {
+		return
+	}
+	if err := p.client.Decr(context.Background(), p.countKey).Err(); err != nil {
+		logrus.Warnf("Redis释放发布次数失败: %v", err)
+	}
+}
+
+// runPublishJob 执行发布并记录发布结果，完成后向回调地址和全局订阅推送结果
+func (s *HTTPServer) runPublishJob(ctx context.Context, jobID string, req *xhs.PublishContent, redisKey string, slot *publishSlot) (*xhs.PublishResponse, error) {
+	payload := &callback.Payload{
+		JobID:     jobID,
+		AccountID: req.AccountID,
+		Title:     req.Title,
+		URL:       req.URL,
+	}
+	// 发布失败（包括 panic）时释放预占的发布次数，成功时已调用 keep
+	defer slot.release()
+
+	result, err := s.xhsService.PublishContent(ctx, req)
+	if err != nil {
+		logrus.Errorf("[Publish] 任务 %s 发布失败 - AccountID: %s, Title: %s: %v", jobID, req.AccountID, req.Title, err)
+		payload.Event = callback.EventPublishFailed
+		payload.Error = err.Error()
+		payload.Code = publishErrorCode(err)
+		if s.jobs.claim(jobID) {
+			s.callbacks.Dispatch(payload, req.CallbackURL)
+			notifyPublishFailed(payload)
+		}
+		return nil, err
+	}
+	result.JobID = jobID
+	slot.keep()
+
+	logrus.Infof("[Publish] 任务 %s 发布成功 - AccountID: %s, Title: %s, NoteID: %s", jobID, req.AccountID, req.Title, result.NoteID)
+
+	// 请求可能已结束，记录发布结果不使用请求的 context
+	recordCtx := context.Background()
+
+	// 将发布记录添加到set中
+	if _, err := s.redisClient.SAdd(recordCtx, redisKey, req.URL).Result(); err != nil {
+		logrus.Warnf("Redis添加发布记录失败: %v", err)
+	}
+
+	payload.Event = callback.EventPublishSucceeded
+	payload.NoteID = result.NoteID
+	if s.jobs.claim(jobID) {
+		s.callbacks.Dispatch(payload, req.CallbackURL)
+	}
+	return result, nil
+}
+
+// startPublishJob 在后台执行发布任务，任务登记在 s.jobs 中，关闭服务时等待或取消
+func (s *HTTPServer) startPublishJob(jobID string, req xhs.PublishContent, redisKey string, slot *publishSlot) {
+	s.jobs.add(jobID, &callback.Payload{
+		JobID:     jobID,
+		AccountID: req.AccountID,
+		Title:     req.Title,
+		URL:       req.URL,
+	}, req.CallbackURL)
+	go s.runPublishJobAsync(jobID, req, redisKey, slot)
+}
+
+// runPublishJobAsync 执行后台发布任务，发布过程中的 panic 作为发布失败回调，不影响服务
+func (s *HTTPServer) runPublishJobAsync(jobID string, req xhs.PublishContent, redisKey string, slot *publishSlot) {
+	defer s.jobs.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.Errorf("[Publish] 任务 %s 发布异常: %v", jobID, r)
+			payload := &callback.Payload{
+				Event:     callback.EventPublishFailed,
+				JobID:     jobID,
+				AccountID: req.AccountID,
+				Title:     req.Title,
+				URL:       req.URL,
+				Error:     fmt.Sprintf("发布异常: %v", r),
+				Code:      "XHS_PUBLISH_FAILED",
+			}
+			if s.jobs.ctx.Err() != nil {
+				payload.Code = ErrCodePublishCanceled
+			}
+			if s.jobs.claim(jobID) {
+				s.callbacks.Dispatch(payload, req.CallbackURL)
+				notifyPublishFailed(payload)
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(s.jobs.ctx, asyncPublishTimeout)
+	defer cancel()
+	s.runPublishJob(ctx, jobID, &req, redisKey, slot)
+}
+
+// notifyPublishFailed 发送发布失败通知
+func notifyPublishFailed(p *callback.Payload) {
+	notify.Notify(&notify.Message{
+		Event:     notify.EventPublishFailed,
+		AccountID: p.AccountID,
+		Title:     "小红书发布失败",
+		Text:      fmt.Sprintf("账号: %s\n标题: %s\n任务: %s\n原因: %s", p.AccountID, p.Title, p.JobID, p.Error),
+	})
+}
+
+// publishErrorCode 发布失败的错误码，与同步接口返回的错误码一致
+func publishErrorCode(err error) string {
+	var challenge *xhs.ChallengeError
+	if errors.As(err, &challenge) {
+		return xhs.ErrCodeCaptchaRequired
+	}
+	var mismatch *xhs.AccountMismatchError
+	if errors.As(err, &mismatch) {
+		return xhs.ErrCodeAccountMismatch
+	}
+	var rejected *sensitive.RejectedError
+	if errors.As(err, &rejected) {
+		return ErrCodeSensitiveRejected
+	}
+	var unmatched *xhs.UnmatchedTagsError
+	if errors.As(err, &unmatched) {
+		return xhs.ErrCodeTagsUnmatched
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrCodePublishCanceled
+	}
+	return "XHS_PUBLISH_FAILED"
+}
+
+// callbackDeliveriesHandler 查询回调投递记录，可按 job_id 过滤，只返回有权限账号的记录
+func (s *HTTPServer) callbackDeliveriesHandler(c *gin.Context) {
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDeliveriesLimit {
+			s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+				"limit 应为 1-"+strconv.Itoa(maxDeliveriesLimit)+" 的整数", nil)
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := s.callbacks.Log().List(c.Request.Context(), c.Query("job_id"), 0)
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, "CALLBACK_LOG_FAILED", "读取投递记录失败", err.Error())
+		return
+	}
+
+	list := make([]*callback.Delivery, 0, limit)
+	for _, d := range deliveries {
+		if !accountAllowed(c, d.AccountID) {
+			continue
+		}
+		list = append(list, d)
+		if len(list) >= limit {
+			break
+		}
+	}
+	s.respondSuccess(c, list, "")
+}
+
+// CallbackSecretResponse 请求级回调地址的签名密钥
+type CallbackSecretResponse struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// callbackSecretHandler 返回请求级回调地址的签名密钥。密钥由 callback.secret 派生，
+// 只允许能操作所有账号的管理密钥查询
+func (s *HTTPServer) callbackSecretHandler(c *gin.Context) {
+	if !accountAllowed(c, apikey.AllAccounts) {
+		s.respondError(c, http.StatusForbidden, "ACCOUNT_FORBIDDEN",
+			"查询回调密钥需要能操作所有账号的 API 密钥", nil)
+		return
+	}
+
+	callbackURL := c.Query("url")
+	secret, err := s.callbacks.RequestSecret(callbackURL)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_CALLBACK_URL", err.Error(), nil)
+		return
+	}
+	s.respondSuccess(c, &CallbackSecretResponse{URL: callbackURL, Secret: secret}, "")
+}
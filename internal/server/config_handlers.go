@@ -0,0 +1,37 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"sns-poster/internal/apikey"
+	"sns-poster/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reloadConfigHandler 重新加载配置文件，校验失败时保留当前配置并返回所有问题。
+// 配置是全局的，只允许能操作所有账号的管理密钥调用
+func (s *HTTPServer) reloadConfigHandler(c *gin.Context) {
+	if !accountAllowed(c, apikey.AllAccounts) {
+		s.respondError(c, http.StatusForbidden, "ACCOUNT_FORBIDDEN",
+			"重新加载配置需要能操作所有账号的 API 密钥", nil)
+		return
+	}
+
+	changes, err := s.reloader.Reload()
+	if err != nil {
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			s.respondError(c, http.StatusUnprocessableEntity, "INVALID_CONFIG", "配置不合法，继续使用当前配置", invalid.Problems)
+			return
+		}
+		s.respondError(c, http.StatusUnprocessableEntity, "INVALID_CONFIG", "重新加载配置失败，继续使用当前配置", err.Error())
+		return
+	}
+
+	if changes == nil {
+		changes = []config.Change{}
+	}
+	s.respondSuccess(c, gin.H{"changes": changes}, "配置已重新加载")
+}
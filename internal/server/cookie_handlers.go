@@ -0,0 +1,105 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"sns-poster/internal/utils"
+	"sns-poster/internal/xhs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxCookieImportSize 导入 cookies 请求体的最大长度
+const maxCookieImportSize = 1 << 20
+
+// exportCookiesHandler 导出账号的 cookies，format 支持 json（默认）、netscape、editthiscookie
+// 以附件形式返回，可直接作为导入接口的请求体
+func (s *HTTPServer) exportCookiesHandler(c *gin.Context) {
+	accountID := c.Param("id")
+	if !s.authorizeAccount(c, accountID) {
+		return
+	}
+	if _, err := s.xhsService.Accounts().Get(accountID); err != nil {
+		s.respondAccountError(c, err)
+		return
+	}
+
+	format := c.DefaultQuery("format", utils.CookieFormatJSON)
+	cookies, err := s.xhsService.ExportCookies(accountID)
+	if err != nil {
+		if errors.Is(err, xhs.ErrNoCookies) {
+			s.respondError(c, http.StatusNotFound, "COOKIES_NOT_FOUND", err.Error(), nil)
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "COOKIES_EXPORT_FAILED",
+			"导出 cookies 失败", err.Error())
+		return
+	}
+
+	data, err := utils.FormatCookies(cookies, format)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_COOKIE_FORMAT", err.Error(), nil)
+		return
+	}
+
+	contentType, ext := "application/json", "json"
+	if format == utils.CookieFormatNetscape {
+		contentType, ext = "text/plain; charset=utf-8", "txt"
+	}
+	filename := fmt.Sprintf("%s-cookies-%s.%s", accountID, time.Now().Format("20060102"), ext)
+
+	logrus.Infof("导出账号 %s 的 cookies（%s，%d 个）", accountID, format, len(cookies))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// importCookiesHandler 导入账号的 cookies，请求体为导出格式之一，format 为空时自动识别
+// 只保留小红书域名下未过期的 cookie，登录状态检查通过后才保存
+func (s *HTTPServer) importCookiesHandler(c *gin.Context) {
+	accountID := c.Param("id")
+	if !s.authorizeAccount(c, accountID) {
+		return
+	}
+	if _, err := s.xhsService.Accounts().Get(accountID); err != nil {
+		s.respondAccountError(c, err)
+		return
+	}
+
+	data, err := c.GetRawData()
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST", "读取请求体失败", err.Error())
+		return
+	}
+	if len(data) == 0 || len(data) > maxCookieImportSize {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			fmt.Sprintf("请求体不能为空且不能超过 %d 字节", maxCookieImportSize), nil)
+		return
+	}
+
+	cookies, err := utils.ParseCookies(data, c.Query("format"))
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_COOKIE_FORMAT", "解析 cookies 失败", err.Error())
+		return
+	}
+
+	result, err := s.xhsService.ImportCookies(c.Request.Context(), accountID, cookies)
+	if err != nil {
+		if s.respondInterventionError(c, err) {
+			return
+		}
+		if errors.Is(err, xhs.ErrInvalidCookies) {
+			s.respondError(c, http.StatusUnprocessableEntity, "INVALID_COOKIES", err.Error(), nil)
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "COOKIES_IMPORT_FAILED",
+			"导入 cookies 失败", err.Error())
+		return
+	}
+
+	s.respondSuccess(c, result, "导入 cookies 成功")
+}
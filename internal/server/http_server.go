@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,26 +10,47 @@ import (
 	"syscall"
 	"time"
 
+	"sns-poster/internal/apikey"
+	"sns-poster/internal/callback"
+	"sns-poster/internal/config"
+	"sns-poster/internal/sensitive"
+	"sns-poster/internal/templates"
+	"sns-poster/internal/webhook"
 	"sns-poster/internal/xhs"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 )
 
 // HTTPServer HTTP服务器
 type HTTPServer struct {
+	reloader    *config.Reloader
+	apiKeys     *apikey.Store
+	webhooks    *webhook.Verifier
+	callbacks   *callback.Dispatcher
+	templates   *templates.Store
 	xhsService  *xhs.Service
 	redisClient *redis.Client
 	router      *gin.Engine
 	server      *http.Server
+	// jobs 带 callback_url 的后台发布任务
+	jobs *publishJobs
 }
 
 // NewHTTPServer 创建HTTP服务器
-func NewHTTPServer(xhsService *xhs.Service, redisClient *redis.Client) *HTTPServer {
+func NewHTTPServer(reloader *config.Reloader, apiKeys *apikey.Store, webhooks *webhook.Verifier, callbacks *callback.Dispatcher,
+	templates *templates.Store, xhsService *xhs.Service, redisClient *redis.Client) *HTTPServer {
 	return &HTTPServer{
+		reloader:    reloader,
+		apiKeys:     apiKeys,
+		webhooks:    webhooks,
+		callbacks:   callbacks,
+		templates:   templates,
 		xhsService:  xhsService,
 		redisClient: redisClient,
+		jobs:        newPublishJobs(),
 	}
 }
 
@@ -113,26 +135,86 @@ func (s *HTTPServer) setupRoutes() *gin.Engine {
 	router.GET("/health", s.healthHandler)
 
 	// 长任务
-	router.GET("/test/long-running-task", s.longRunningTaskHandler)
-	router.GET("/test/error-response", s.errorResponseTestHandler)
+	test := router.Group("/test", s.requireScope(apikey.ScopeAdmin))
+	{
+		test.GET("/long-running-task", s.longRunningTaskHandler)
+		test.GET("/error-response", s.errorResponseTestHandler)
+	}
 
-	// API 路由组
+	// API 路由组，除 webhook 外均需要 API 密钥（Authorization: Bearer <key>），按操作区分权限
 	api := router.Group("/api/v1")
 	{
 		// XHS (小红书) 相关路由
 		xhs := api.Group("/xhs")
 		{
-			// 公开路由 - 不需要认证
-			xhs.GET("/login/status", s.checkXHSLoginStatusHandler)
-			xhs.POST("/login", s.xhsLoginHandler)
-
-			// 受保护的路由 - 自动触发登录
-			protected := xhs.Group("/")
-			protected.Use(s.xhsAuthMiddleware())
-			{
-				protected.POST("/publish", s.xhsPublishHandler)
-				protected.POST("/logout", s.xhsLogoutHandler)
-			}
+			read := s.requireScope(apikey.ScopeRead)
+			login := s.requireScope(apikey.ScopeLogin)
+			admin := s.requireScope(apikey.ScopeAdmin)
+
+			// 登录
+			xhs.GET("/login/status", read, s.checkXHSLoginStatusHandler)
+			xhs.POST("/login", login, s.xhsLoginHandler)
+			xhs.POST("/login/sms", login, s.xhsSMSLoginHandler)
+			xhs.POST("/login/sessions/:id/code", login, s.xhsSMSCodeHandler)
+
+			// 账号注册表
+			xhs.GET("/accounts", read, s.listAccountsHandler)
+			xhs.POST("/accounts", admin, s.createAccountHandler)
+			xhs.GET("/accounts/:id", read, s.getAccountHandler)
+			xhs.PATCH("/accounts/:id", admin, s.updateAccountHandler)
+			xhs.DELETE("/accounts/:id", admin, s.deleteAccountHandler)
+
+			// 账号 cookies 导入导出
+			xhs.GET("/accounts/:id/cookies", admin, s.exportCookiesHandler)
+			xhs.POST("/accounts/:id/cookies", admin, s.importCookiesHandler)
+
+			// 账号登录健康检查结果
+			xhs.GET("/accounts/health", read, s.xhsAccountsHealthHandler)
+
+			// 账号登录 session 过期时间
+			xhs.GET("/accounts/sessions", read, s.xhsAccountsSessionsHandler)
+
+			// 需要人工处理的账号（验证码等）
+			xhs.GET("/accounts/attention", read, s.xhsAttentionHandler)
+			xhs.DELETE("/accounts/:id/attention", login, s.xhsClearAttentionHandler)
+
+			// 验证码远程处理：查看截图、点击/拖动/输入、结束处理
+			xhs.GET("/remote/:id", login, s.xhsRemoteViewHandler)
+			xhs.GET("/remote/:id/screenshot", login, s.xhsRemoteViewScreenshotHandler)
+			xhs.POST("/remote/:id/actions", login, s.xhsRemoteViewActionHandler)
+			xhs.DELETE("/remote/:id", login, s.xhsRemoteViewCloseHandler)
+
+			// 发布、登出 - 中间件检查登录状态，发布时自动触发登录
+			xhs.POST("/publish", s.requireScope(apikey.ScopePublish), s.xhsAuthMiddleware(), s.xhsPublishHandler)
+			xhs.POST("/logout", s.requireScope(apikey.ScopeLogout), s.xhsAuthMiddleware(), s.xhsLogoutHandler)
+
+			// 试运行发布前的处理（敏感词、截取、图片下载和检查），不使用浏览器
+			xhs.POST("/validate", s.requireScope(apikey.ScopePublish), s.xhsValidateHandler)
+
+			// 话题联想查询，结果缓存在 Redis 中；查询需要打开账号的发布编辑器，要求发布权限
+			xhs.GET("/topics", s.requireScope(apikey.ScopePublish), s.xhsTopicsHandler)
+
+			// 内容模板：发布请求指定 template 和 fields 时渲染标题、正文和标签
+			xhs.GET("/templates", read, s.listTemplatesHandler)
+			xhs.GET("/templates/:name", read, s.getTemplateHandler)
+			xhs.PUT("/templates/:name", admin, s.putTemplateHandler)
+			xhs.DELETE("/templates/:name", admin, s.deleteTemplateHandler)
+			xhs.POST("/templates/:name/render", read, s.renderTemplateHandler)
+
+			// 发布结果回调的投递记录
+			xhs.GET("/callbacks/deliveries", read, s.callbackDeliveriesHandler)
+			// 请求级回调地址的签名密钥，提供给回调接收方
+			xhs.GET("/callbacks/secret", admin, s.callbackSecretHandler)
+
+			// 上游系统推送发布请求：HMAC 签名认证代替 API 密钥，其余流程与 /publish 相同
+			xhs.POST("/webhook/publish", s.verifyWebhook(), s.xhsAuthMiddleware(), s.xhsPublishHandler)
+		}
+
+		// 服务管理
+		admin := api.Group("/admin", s.requireScope(apikey.ScopeAdmin))
+		{
+			// 重新加载配置文件（也可以发送 SIGHUP）
+			admin.POST("/config/reload", s.reloadConfigHandler)
 		}
 	}
 
@@ -160,9 +242,23 @@ func (s *HTTPServer) ginLogrusMiddleware() gin.HandlerFunc {
 // corsMiddleware CORS中间件
 func (s *HTTPServer) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Account-ID")
+		// 只允许配置的来源跨域访问，每次请求读取当前配置，支持热更新
+		allowed := make(map[string]bool)
+		for _, origin := range config.GetConfig().Server.CORSOrigins {
+			allowed[origin] = true
+		}
+
+		origin := c.GetHeader("Origin")
+		switch {
+		case allowed["*"]:
+			c.Header("Access-Control-Allow-Origin", "*")
+		case origin != "" && allowed[origin]:
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Vary", "Origin")
+		}
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Account-ID, "+
+			"X-Webhook-Source, X-Webhook-Timestamp, X-Webhook-Nonce, X-Webhook-Signature")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -173,22 +269,8 @@ func (s *HTTPServer) corsMiddleware() gin.HandlerFunc {
 	}
 }
 
-// getAccountID 从请求中读取 accountID：优先 Query account_id，其次 Header X-Account-ID
-// 如果都没有，返回空字符串（使用默认账号 cookies.json）
-func getAccountID(c *gin.Context) string {
-	// 优先级 1: Query String (最高优先级)
-	if v := c.Query("account_id"); v != "" {
-		return v
-	}
-	// 优先级 2: Header
-	if v := c.GetHeader("X-Account-ID"); v != "" {
-		return v
-	}
-
-	// FIXME: remove this after testing
-	// hardcode accountID for testing
-	return "65b09837000000000e025e61"
-}
+// ErrCodeSensitiveRejected 内容命中 reject 敏感词表，拒绝发布
+const ErrCodeSensitiveRejected = "SENSITIVE_WORDS_REJECTED"
 
 // ErrorResponse 错误响应
 type ErrorResponse struct {
@@ -222,33 +304,6 @@ func (s *HTTPServer) respondError(c *gin.Context, statusCode int, code, message
 		"details":     details,
 	}).Errorf("API请求失败: %s", message)
 
-	// TODO: delete
-	// // send notify to wecom regardless of sucess for failure, make sure it executes before exiting the function
-	// go func() {
-	// 	defer func() {
-	// 		if r := recover(); r != nil {
-	// 			logrus.Errorf("发送通知失败: %v", r)
-	// 		}
-	// 	}()
-	// 	payload := map[string]string{
-	// 		"content": fmt.Sprintf("XHS发布失败: %s\n %s", message, details),
-	// 	}
-	// 	jsonData, err := json.Marshal(payload)
-	// 	if err != nil {
-	// 		logrus.Errorf("JSON编码失败: %v", err)
-	// 		return
-	// 	}
-
-	// 	resp, err := http.Post("http://localhost:6181/api/v1/notify/wecom", "application/json", bytes.NewReader(jsonData))
-	// 	if err != nil {
-	// 		logrus.Errorf("发送通知失败: %v", err)
-	// 	}
-	// 	if resp.StatusCode != http.StatusOK {
-	// 		logrus.Errorf("发送通知失败: %v", resp.StatusCode)
-	// 	}
-	// 	defer resp.Body.Close()
-	// }()
-
 	c.JSON(statusCode, response)
 }
 
@@ -264,17 +319,49 @@ func (s *HTTPServer) respondSuccess(c *gin.Context, data any, message string) {
 	c.JSON(http.StatusOK, response)
 }
 
+// respondInterventionError 错误需要人工处理时返回 409：验证码挑战返回 CAPTCHA_REQUIRED，
+// 登录用户与账号不一致返回 ACCOUNT_MISMATCH。已处理返回 true
+func (s *HTTPServer) respondInterventionError(c *gin.Context, err error) bool {
+	var challenge *xhs.ChallengeError
+	if errors.As(err, &challenge) {
+		s.respondError(c, http.StatusConflict, xhs.ErrCodeCaptchaRequired,
+			"遇到验证码，需要人工处理", challenge)
+		return true
+	}
+
+	var mismatch *xhs.AccountMismatchError
+	if errors.As(err, &mismatch) {
+		s.respondError(c, http.StatusConflict, xhs.ErrCodeAccountMismatch,
+			mismatch.Error(), mismatch)
+		return true
+	}
+	return false
+}
+
 // xhsAuthMiddleware XHS认证中间件 - 按请求中的 accountID 检查登录状态
 // 注意：不在中间件强制登录，让 Publisher 在发布时自动处理登录（同一浏览器会话，cookie 一致）
 func (s *HTTPServer) xhsAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 从 Header/Query 读取 accountID（body 还未解析）
-		accountID := getAccountID(c)
+		accountID, ok := s.accountIDFromRequest(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		if _, ok := s.resolveAccount(c, accountID); !ok {
+			c.Abort()
+			return
+		}
 
 		logrus.Infof("[Middleware] 检查账号登录状态: %s", accountID)
 
 		status, err := s.xhsService.CheckLoginStatus(c.Request.Context(), accountID)
 		if err != nil {
+			// 遇到验证码时后续流程必然失败，直接返回
+			if s.respondInterventionError(c, err) {
+				c.Abort()
+				return
+			}
 			// 检查失败只记录日志，不阻止请求（Publisher 会自动处理登录）
 			logrus.Warnf("[Middleware] 登录状态检查失败: %v，发布器将自动处理", err)
 			c.Set("xhs_is_logged_in", false)
@@ -321,11 +408,20 @@ func (s *HTTPServer) errorResponseTestHandler(c *gin.Context) {
 		"错误测试", "错误测试详情")
 }
 
-// checkXHSLoginStatusHandler 检查XHS登录状态，accountID 通过 Header X-Account-ID 或 Query account_id 传递
+// checkXHSLoginStatusHandler 检查XHS登录状态，accountID 通过 Query account_id 或 Header X-Account-ID 传递
 func (s *HTTPServer) checkXHSLoginStatusHandler(c *gin.Context) {
-	accountID := getAccountID(c)
+	accountID, ok := s.accountIDFromRequest(c)
+	if !ok {
+		return
+	}
+	if _, ok := s.resolveAccount(c, accountID); !ok {
+		return
+	}
 	status, err := s.xhsService.CheckLoginStatus(c.Request.Context(), accountID)
 	if err != nil {
+		if s.respondInterventionError(c, err) {
+			return
+		}
 		s.respondError(c, http.StatusInternalServerError, "XHS_STATUS_CHECK_FAILED",
 			"检查XHS登录状态失败", err.Error())
 		return
@@ -334,13 +430,22 @@ func (s *HTTPServer) checkXHSLoginStatusHandler(c *gin.Context) {
 	s.respondSuccess(c, status, "检查XHS登录状态成功")
 }
 
-// xhsLoginHandler XHS登录处理，accountID 通过 Header X-Account-ID 或 Query account_id 传递
+// xhsLoginHandler XHS登录处理，accountID 通过 Query account_id、Header X-Account-ID 或 Body account_id 传递
 func (s *HTTPServer) xhsLoginHandler(c *gin.Context) {
-	accountID := getAccountID(c)
+	accountID, ok := s.accountIDFromRequest(c)
+	if !ok {
+		return
+	}
 	logrus.Infof("登录请求，accountID: %s", accountID)
+	if _, ok := s.resolveAccount(c, accountID); !ok {
+		return
+	}
 
 	result, err := s.xhsService.Login(c.Request.Context(), accountID)
 	if err != nil {
+		if s.respondInterventionError(c, err) {
+			return
+		}
 		s.respondError(c, http.StatusInternalServerError, "XHS_LOGIN_FAILED",
 			"XHS登录失败", err.Error())
 		return
@@ -355,9 +460,211 @@ func (s *HTTPServer) xhsLoginHandler(c *gin.Context) {
 	s.respondSuccess(c, result, "XHS登录成功")
 }
 
-// xhsLogoutHandler XHS登出处理，accountID 通过 Header X-Account-ID 或 Query account_id 传递
+// SMSLoginRequest 短信验证码登录请求
+type SMSLoginRequest struct {
+	AccountID string `json:"account_id,omitempty"`
+	Phone     string `json:"phone" binding:"required"`
+}
+
+// SMSCodeRequest 提交短信验证码请求
+type SMSCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// xhsSMSLoginHandler 短信验证码登录第一步：发送验证码，返回登录会话
+// accountID 可从 Query account_id、Header X-Account-ID 或 Body account_id 传递
+func (s *HTTPServer) xhsSMSLoginHandler(c *gin.Context) {
+	accountID, ok := s.accountIDFromRequest(c)
+	if !ok {
+		return
+	}
+
+	var req SMSLoginRequest
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"请求参数错误", err.Error())
+		return
+	}
+	req.AccountID = accountID
+	logrus.Infof("短信登录请求，accountID: %s", req.AccountID)
+	if _, ok := s.resolveAccount(c, req.AccountID); !ok {
+		return
+	}
+
+	session, err := s.xhsService.StartSMSLogin(c.Request.Context(), req.AccountID, req.Phone)
+	if err != nil {
+		if errors.Is(err, xhs.ErrInvalidPhoneNumber) {
+			s.respondError(c, http.StatusBadRequest, "INVALID_PHONE_NUMBER", err.Error(), nil)
+			return
+		}
+		if s.respondInterventionError(c, err) {
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "XHS_SMS_SEND_FAILED",
+			"发送短信验证码失败", err.Error())
+		return
+	}
+
+	s.respondSuccess(c, session, "短信验证码已发送")
+}
+
+// xhsSMSCodeHandler 短信验证码登录第二步：提交验证码完成登录
+func (s *HTTPServer) xhsSMSCodeHandler(c *gin.Context) {
+	if !s.authorizeSMSSession(c) {
+		return
+	}
+
+	var req SMSCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"请求参数错误", err.Error())
+		return
+	}
+
+	result, err := s.xhsService.SubmitSMSCode(c.Request.Context(), c.Param("id"), req.Code)
+	if err != nil {
+		if errors.Is(err, xhs.ErrSMSSessionNotFound) {
+			s.respondError(c, http.StatusNotFound, "XHS_LOGIN_SESSION_NOT_FOUND",
+				err.Error(), nil)
+			return
+		}
+		if s.respondInterventionError(c, err) {
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "XHS_LOGIN_FAILED",
+			"XHS登录失败", err.Error())
+		return
+	}
+
+	if !result.Success {
+		s.respondError(c, http.StatusBadRequest, "XHS_LOGIN_FAILED",
+			result.Message, nil)
+		return
+	}
+
+	s.respondSuccess(c, result, "XHS登录成功")
+}
+
+// xhsAccountsHealthHandler 返回后台健康检查记录的各账号登录状态
+func (s *HTTPServer) xhsAccountsHealthHandler(c *gin.Context) {
+	var list []*xhs.AccountHealth
+	for _, result := range s.xhsService.HealthMonitor().Results() {
+		if accountAllowed(c, result.AccountID) {
+			list = append(list, result)
+		}
+	}
+	s.respondSuccess(c, list, "获取账号健康状态成功")
+}
+
+// xhsAccountsSessionsHandler 返回所有账号登录 session 的过期时间和最近一次刷新结果
+func (s *HTTPServer) xhsAccountsSessionsHandler(c *gin.Context) {
+	var list []*xhs.SessionInfo
+	for _, info := range s.xhsService.SessionRefresher().Sessions() {
+		if accountAllowed(c, info.AccountID) {
+			list = append(list, info)
+		}
+	}
+	s.respondSuccess(c, list, "获取账号 session 状态成功")
+}
+
+// xhsAttentionHandler 列出需要人工处理的账号（如遇到验证码）
+func (s *HTTPServer) xhsAttentionHandler(c *gin.Context) {
+	s.respondSuccess(c, filterAllowedAccounts(c, s.xhsService.AccountsNeedingAttention()), "获取待处理账号成功")
+}
+
+// xhsClearAttentionHandler 人工处理完成后清除账号的待处理标记
+func (s *HTTPServer) xhsClearAttentionHandler(c *gin.Context) {
+	if !s.authorizeAccount(c, c.Param("id")) {
+		return
+	}
+	s.xhsService.ClearAttention(c.Param("id"))
+	s.respondSuccess(c, nil, "已清除待处理标记")
+}
+
+// xhsRemoteViewHandler 获取验证码远程处理会话信息
+func (s *HTTPServer) xhsRemoteViewHandler(c *gin.Context) {
+	if !s.authorizeRemoteView(c) {
+		return
+	}
+
+	session, err := s.xhsService.GetRemoteView(c.Param("id"))
+	if err != nil {
+		s.respondError(c, http.StatusNotFound, "REMOTE_VIEW_NOT_FOUND", err.Error(), nil)
+		return
+	}
+	s.respondSuccess(c, session, "获取远程处理会话成功")
+}
+
+// xhsRemoteViewScreenshotHandler 返回远程处理页面的当前截图（PNG）
+func (s *HTTPServer) xhsRemoteViewScreenshotHandler(c *gin.Context) {
+	if !s.authorizeRemoteView(c) {
+		return
+	}
+
+	screenshot, err := s.xhsService.RemoteViewScreenshot(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, xhs.ErrRemoteViewNotFound) {
+			s.respondError(c, http.StatusNotFound, "REMOTE_VIEW_NOT_FOUND", err.Error(), nil)
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "REMOTE_VIEW_SCREENSHOT_FAILED",
+			"截图失败", err.Error())
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", screenshot)
+}
+
+// xhsRemoteViewActionHandler 在远程处理页面上执行点击/拖动/输入
+func (s *HTTPServer) xhsRemoteViewActionHandler(c *gin.Context) {
+	if !s.authorizeRemoteView(c) {
+		return
+	}
+
+	var action xhs.RemoteViewAction
+	if err := c.ShouldBindJSON(&action); err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"请求参数错误", err.Error())
+		return
+	}
+
+	if err := s.xhsService.RemoteViewDo(c.Request.Context(), c.Param("id"), action); err != nil {
+		if errors.Is(err, xhs.ErrRemoteViewNotFound) {
+			s.respondError(c, http.StatusNotFound, "REMOTE_VIEW_NOT_FOUND", err.Error(), nil)
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "REMOTE_VIEW_ACTION_FAILED",
+			"远程操作失败", err.Error())
+		return
+	}
+	s.respondSuccess(c, nil, "操作成功")
+}
+
+// xhsRemoteViewCloseHandler 结束远程处理，验证码通过时保存 cookies
+func (s *HTTPServer) xhsRemoteViewCloseHandler(c *gin.Context) {
+	if !s.authorizeRemoteView(c) {
+		return
+	}
+
+	solved, err := s.xhsService.CloseRemoteView(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, xhs.ErrRemoteViewNotFound) {
+			s.respondError(c, http.StatusNotFound, "REMOTE_VIEW_NOT_FOUND", err.Error(), nil)
+			return
+		}
+		if s.respondInterventionError(c, err) {
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "REMOTE_VIEW_CLOSE_FAILED",
+			"结束远程处理失败", err.Error())
+		return
+	}
+	s.respondSuccess(c, map[string]any{"solved": solved}, "远程处理已结束")
+}
+
+// xhsLogoutHandler XHS登出处理，accountID 由中间件解析
 func (s *HTTPServer) xhsLogoutHandler(c *gin.Context) {
-	accountID := getAccountID(c)
+	accountID := c.GetString("xhs_account_id")
 	result, err := s.xhsService.Logout(c.Request.Context(), accountID)
 	if err != nil {
 		s.respondError(c, http.StatusInternalServerError, "XHS_LOGOUT_FAILED",
@@ -374,36 +681,40 @@ func (s *HTTPServer) xhsLogoutHandler(c *gin.Context) {
 	s.respondSuccess(c, nil, "XHS登出成功")
 }
 
-// xhsPublishHandler XHS发布内容，accountID 可从 body.account_id 或 Header X-Account-ID 或 Query account_id 传递
+// xhsPublishHandler XHS发布内容，accountID 由中间件解析（Query > Header > Body，多个来源必须一致）
 func (s *HTTPServer) xhsPublishHandler(c *gin.Context) {
 	var req xhs.PublishContent
-	if err := c.ShouldBindJSON(&req); err != nil {
+	// 中间件已读取过 body，需要用 ShouldBindBodyWith 再次解析
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
 		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
 			"请求参数错误", err.Error())
 		return
 	}
 
-	// 优先使用 middleware 已验证的 accountID，确保一致性
-	middlewareAccountID, exists := c.Get("xhs_account_id")
-	if exists && middlewareAccountID != nil {
-		middlewareAccIDStr := middlewareAccountID.(string)
-		// 如果 body 有 account_id 且与 middleware 不同，以 body 为准（但需要记录警告）
-		if req.AccountID != "" && req.AccountID != middlewareAccIDStr {
-			logrus.Warnf("⚠️  账号不一致！Middleware: %s, Body: %s，使用 Body 的值",
-				middlewareAccIDStr, req.AccountID)
-		} else if req.AccountID == "" {
-			// Body 没有 account_id，使用 middleware 的
-			req.AccountID = middlewareAccIDStr
-		}
-	} else if req.AccountID == "" {
-		// Middleware 和 Body 都没有，从 Header/Query 读取
-		req.AccountID = getAccountID(c)
+	// 使用 middleware 已验证的 accountID，body 中的 account_id 与之不一致时中间件已拒绝
+	req.AccountID = c.GetString("xhs_account_id")
+
+	if !s.applyTemplate(c, &req) {
+		return
 	}
 
 	logrus.Infof("[Handler] 发布请求 - AccountID: %s, Title: %s", req.AccountID, req.Title)
 
+	acct, ok := s.resolveAccount(c, req.AccountID)
+	if !ok {
+		return
+	}
+
+	if req.CallbackURL != "" {
+		if err := s.callbacks.CheckCallbackURL(c.Request.Context(), req.CallbackURL); err != nil {
+			s.respondError(c, http.StatusBadRequest, "INVALID_CALLBACK_URL", err.Error(), nil)
+			return
+		}
+	}
+
 	// 在redis中检查是否存在该账号的发布记录
-	redisKey := fmt.Sprintf("%s:%s:%v", os.Getenv("SNS_POSTER_QUEUE_NAME"), req.AccountID, "success")
+	queueName := config.GetConfig().Redis.QueueName
+	redisKey := fmt.Sprintf("%s:%s:%v", queueName, req.AccountID, "success")
 	redisValue := req.URL
 
 	// 检查是否在set中存在该账号的发布记录
@@ -420,20 +731,104 @@ func (s *HTTPServer) xhsPublishHandler(c *gin.Context) {
 		return
 	}
 
-	result, err := s.xhsService.PublishContent(c.Request.Context(), &req)
+	// 预占今日发布次数，超过账号每日发布上限时拒绝；发布失败时释放
+	countKey := fmt.Sprintf("%s:%s:count:%s", queueName, req.AccountID, time.Now().Format("20060102"))
+	slot, err := s.reservePublishSlot(c.Request.Context(), countKey, acct.DailyPublishLimit)
 	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, "REDIS_CHECK_FAILED",
+			"Redis检查失败", err.Error())
+		return
+	}
+	if slot == nil {
+		s.respondError(c, http.StatusTooManyRequests, "PUBLISH_LIMIT_EXCEEDED",
+			fmt.Sprintf("账号今日发布已达上限: %d", acct.DailyPublishLimit), nil)
+		return
+	}
+
+	jobID := callback.NewID()
+
+	// 指定了回调地址：立即返回任务ID，后台发布完成后推送结果
+	if req.CallbackURL != "" {
+		s.startPublishJob(jobID, req, redisKey, slot)
+
+		c.JSON(http.StatusAccepted, SuccessResponse{
+			Success: true,
+			Data: &xhs.PublishResponse{
+				JobID:     jobID,
+				AccountID: req.AccountID,
+				Title:     req.Title,
+				Content:   req.Content,
+				Images:    len(req.Images),
+				Status:    xhs.PublishStatusAccepted,
+			},
+			Message: "XHS发布任务已接受，完成后回调通知",
+		})
+		return
+	}
+
+	result, err := s.runPublishJob(c.Request.Context(), jobID, &req, redisKey, slot)
+	if err != nil {
+		if s.respondInterventionError(c, err) {
+			return
+		}
+		var rejected *sensitive.RejectedError
+		if errors.As(err, &rejected) {
+			s.respondError(c, http.StatusBadRequest, ErrCodeSensitiveRejected, rejected.Error(), rejected.Hits)
+			return
+		}
+		var unmatched *xhs.UnmatchedTagsError
+		if errors.As(err, &unmatched) {
+			s.respondError(c, http.StatusUnprocessableEntity, xhs.ErrCodeTagsUnmatched, unmatched.Error(), unmatched.Tags)
+			return
+		}
 		s.respondError(c, http.StatusInternalServerError, "XHS_PUBLISH_FAILED",
 			"XHS发布失败", err.Error())
 		return
 	}
 
-	logrus.Infof("[Handler] 发布成功 - AccountID: %s, Title: %s", req.AccountID, req.Title)
+	s.respondSuccess(c, result, "XHS发布成功")
+}
+
+// xhsValidateHandler 返回按当前配置发布时的最终标题、正文、标签、警告和每张图片的处理结果。
+// 内容问题在 data.errors 中返回（data.valid 为 false），不返回错误状态码
+func (s *HTTPServer) xhsValidateHandler(c *gin.Context) {
+	var req xhs.PublishContent
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"请求参数错误", err.Error())
+		return
+	}
+
+	accountID, ok := s.accountIDFromRequest(c)
+	if !ok {
+		return
+	}
+	if _, ok := s.resolveAccount(c, accountID); !ok {
+		return
+	}
+	req.AccountID = accountID
+
+	if !s.applyTemplate(c, &req) {
+		return
+	}
 
-	// 将发布记录添加到set中
-	_, err = s.redisClient.SAdd(c.Request.Context(), redisKey, redisValue).Result()
+	result, err := s.xhsService.ValidateContent(c.Request.Context(), &req)
 	if err != nil {
-		logrus.Warnf("Redis添加发布记录失败: %v", err)
+		s.respondError(c, http.StatusInternalServerError, "XHS_VALIDATE_FAILED",
+			"内容检查失败", err.Error())
+		return
 	}
 
-	s.respondSuccess(c, result, "XHS发布成功")
+	if req.CallbackURL != "" {
+		if err := s.callbacks.CheckCallbackURL(c.Request.Context(), req.CallbackURL); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			result.Valid = false
+		}
+	}
+
+	message := "内容检查通过"
+	if !result.Valid {
+		message = "内容检查未通过"
+	}
+	s.respondSuccess(c, result, message)
 }
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"sns-poster/internal/apikey"
+	"sns-poster/internal/templates"
+	"sns-poster/internal/xhs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// applyTemplate 请求指定了模板时用 fields 渲染标题、正文和标签：请求中的标题、正文非空时优先使用，
+// 模板的标签排在请求的标签之前。渲染后标题或正文为空时拒绝。失败时写入错误响应并返回 false
+func (s *HTTPServer) applyTemplate(c *gin.Context, req *xhs.PublishContent) bool {
+	if req.Template == "" && len(req.Fields) > 0 {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"fields 需要与 template 一起使用", nil)
+		return false
+	}
+
+	if req.Template != "" {
+		rendered, err := s.templates.Render(req.Template, req.Fields)
+		if err != nil {
+			s.respondTemplateError(c, err)
+			return false
+		}
+		if req.Title == "" {
+			req.Title = rendered.Title
+		}
+		if req.Content == "" {
+			req.Content = rendered.Content
+		}
+		req.Tags = append(rendered.Tags, req.Tags...)
+	}
+
+	if req.Title == "" || req.Content == "" {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"请求参数错误", "标题和正文不能为空")
+		return false
+	}
+	return true
+}
+
+// respondTemplateError 返回模板操作的错误响应
+func (s *HTTPServer) respondTemplateError(c *gin.Context, err error) {
+	var fieldsErr *templates.FieldsError
+	switch {
+	case errors.As(err, &fieldsErr):
+		s.respondError(c, http.StatusBadRequest, "TEMPLATE_FIELDS_MISSING",
+			err.Error(), fieldsErr.Missing)
+	case errors.Is(err, templates.ErrTemplateNotFound):
+		s.respondError(c, http.StatusNotFound, "TEMPLATE_NOT_FOUND",
+			"模板不存在", err.Error())
+	case errors.Is(err, templates.ErrInvalidTemplate):
+		s.respondError(c, http.StatusBadRequest, "INVALID_TEMPLATE",
+			"模板不正确", err.Error())
+	case errors.Is(err, templates.ErrRenderFailed):
+		s.respondError(c, http.StatusBadRequest, "TEMPLATE_RENDER_FAILED",
+			"渲染模板失败", err.Error())
+	default:
+		s.respondError(c, http.StatusInternalServerError, "TEMPLATE_SAVE_FAILED",
+			"保存模板失败", err.Error())
+	}
+}
+
+// listTemplatesHandler 列出所有内容模板
+func (s *HTTPServer) listTemplatesHandler(c *gin.Context) {
+	s.respondSuccess(c, s.templates.List(), "获取模板列表成功")
+}
+
+// getTemplateHandler 获取内容模板
+func (s *HTTPServer) getTemplateHandler(c *gin.Context) {
+	t, err := s.templates.Get(c.Param("name"))
+	if err != nil {
+		s.respondTemplateError(c, err)
+		return
+	}
+	s.respondSuccess(c, t, "获取模板成功")
+}
+
+// putTemplateHandler 创建或替换内容模板。模板对所有账号生效，只允许能操作所有账号的管理密钥修改
+func (s *HTTPServer) putTemplateHandler(c *gin.Context) {
+	if !accountAllowed(c, apikey.AllAccounts) {
+		s.respondError(c, http.StatusForbidden, "ACCOUNT_FORBIDDEN",
+			"修改模板需要能操作所有账号的 API 密钥", nil)
+		return
+	}
+
+	var req templates.Template
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"请求参数错误", err.Error())
+		return
+	}
+	if req.Name != "" && req.Name != c.Param("name") {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"请求中的模板名称与路径不一致", nil)
+		return
+	}
+	req.Name = c.Param("name")
+
+	t, err := s.templates.Put(&req)
+	if err != nil {
+		s.respondTemplateError(c, err)
+		return
+	}
+
+	logrus.Infof("保存内容模板: %s", t.Name)
+	s.respondSuccess(c, t, "保存模板成功")
+}
+
+// deleteTemplateHandler 删除内容模板
+func (s *HTTPServer) deleteTemplateHandler(c *gin.Context) {
+	if !accountAllowed(c, apikey.AllAccounts) {
+		s.respondError(c, http.StatusForbidden, "ACCOUNT_FORBIDDEN",
+			"修改模板需要能操作所有账号的 API 密钥", nil)
+		return
+	}
+
+	if err := s.templates.Delete(c.Param("name")); err != nil {
+		s.respondTemplateError(c, err)
+		return
+	}
+
+	logrus.Infof("删除内容模板: %s", c.Param("name"))
+	s.respondSuccess(c, nil, "删除模板成功")
+}
+
+// RenderTemplateRequest 预览模板渲染结果的请求
+type RenderTemplateRequest struct {
+	Fields map[string]any `json:"fields"`
+}
+
+// renderTemplateHandler 预览模板渲染结果，不做敏感词、截取等发布前处理（使用 /validate 查看最终内容）
+func (s *HTTPServer) renderTemplateHandler(c *gin.Context) {
+	var req RenderTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"请求参数错误", err.Error())
+		return
+	}
+
+	rendered, err := s.templates.Render(c.Param("name"), req.Fields)
+	if err != nil {
+		s.respondTemplateError(c, err)
+		return
+	}
+	s.respondSuccess(c, rendered, "渲染模板成功")
+}
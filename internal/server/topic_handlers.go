@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"sns-poster/internal/config"
+	"sns-poster/internal/xhs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// maxTopicQueryLength 话题查询词的最大字符数，查询时逐字输入编辑器，过长的查询没有意义且占用浏览器
+const maxTopicQueryLength = 50
+
+// TopicsResponse 话题联想查询结果
+type TopicsResponse struct {
+	Query  string      `json:"query"`
+	Topics []xhs.Topic `json:"topics"`
+	Cached bool        `json:"cached"` // 结果来自 Redis 缓存
+}
+
+// xhsTopicsHandler 使用账号的发布编辑器查询话题联想，结果按查询词（不区分大小写）缓存 xhs.topic_cache_ttl，
+// 账号按 Query account_id > Header X-Account-ID > 默认账号 取值
+func (s *HTTPServer) xhsTopicsHandler(c *gin.Context) {
+	query := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(c.Query("q")), "#＃"))
+	if query == "" {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			"缺少查询参数 q", nil)
+		return
+	}
+	if n := utf8.RuneCountInString(query); n > maxTopicQueryLength {
+		s.respondError(c, http.StatusBadRequest, "INVALID_REQUEST",
+			fmt.Sprintf("查询参数 q 过长 (%d > %d 个字符)", n, maxTopicQueryLength), nil)
+		return
+	}
+
+	accountID, ok := s.accountIDFromRequest(c)
+	if !ok {
+		return
+	}
+	if _, ok := s.resolveAccount(c, accountID); !ok {
+		return
+	}
+
+	cfg := config.GetConfig()
+	cacheKey := fmt.Sprintf("%s:topics:%s", cfg.Redis.QueueName, strings.ToLower(query))
+	ctx := c.Request.Context()
+
+	if cfg.XHS.TopicCacheTTL > 0 {
+		data, err := s.redisClient.Get(ctx, cacheKey).Bytes()
+		switch {
+		case err == nil:
+			var topics []xhs.Topic
+			if err := json.Unmarshal(data, &topics); err == nil {
+				s.respondSuccess(c, &TopicsResponse{Query: query, Topics: topics, Cached: true}, "查询话题成功")
+				return
+			}
+			logrus.Warnf("话题缓存 %s 格式错误，重新查询", cacheKey)
+		case err != redis.Nil:
+			logrus.Warnf("读取话题缓存失败: %v", err)
+		}
+	}
+
+	topics, err := s.xhsService.SearchTopics(ctx, accountID, query)
+	if err != nil {
+		if s.respondInterventionError(c, err) {
+			return
+		}
+		if errors.Is(err, xhs.ErrNotLoggedIn) {
+			s.respondError(c, http.StatusConflict, "NOT_LOGGED_IN",
+				"账号未登录，请先登录", err.Error())
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "TOPIC_SEARCH_FAILED",
+			"查询话题失败", err.Error())
+		return
+	}
+
+	if cfg.XHS.TopicCacheTTL > 0 {
+		if data, err := json.Marshal(topics); err == nil {
+			if err := s.redisClient.Set(ctx, cacheKey, data, cfg.XHS.TopicCacheTTL).Err(); err != nil {
+				logrus.Warnf("保存话题缓存失败: %v", err)
+			}
+		}
+	}
+
+	s.respondSuccess(c, &TopicsResponse{Query: query, Topics: topics}, "查询话题成功")
+}
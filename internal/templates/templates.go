@@ -0,0 +1,355 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrTemplateNotFound 模板不存在
+	ErrTemplateNotFound = errors.New("模板不存在")
+	// ErrInvalidTemplate 模板定义不正确（名称、语法等）
+	ErrInvalidTemplate = errors.New("模板不正确")
+	// ErrRenderFailed 渲染模板失败（引用了请求中没有且没有默认值的字段等）
+	ErrRenderFailed = errors.New("渲染模板失败")
+)
+
+// namePattern 模板名称：字母、数字、下划线和连字符
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Template 内容模板，标题、正文和每个标签都是 text/template 模板，
+// 渲染数据为发布请求的 fields（如 {{.name}}、{{.price}}）
+type Template struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Title       string   `json:"title"`
+	Content     string   `json:"content"`
+	Tags        []string `json:"tags,omitempty"` // 渲染结果为空的标签被忽略
+	// Required 必填字段，请求中缺少或为空字符串时拒绝
+	Required []string `json:"required,omitempty"`
+	// Defaults 可选字段的默认值；模板引用了请求中没有、也没有默认值的字段时渲染失败
+	Defaults  map[string]string `json:"defaults,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+
+	title   *template.Template
+	content *template.Template
+	tags    []*template.Template
+}
+
+// FieldsError 请求缺少模板的必填字段
+type FieldsError struct {
+	Template string
+	Missing  []string
+}
+
+func (e *FieldsError) Error() string {
+	return fmt.Sprintf("模板 %s 缺少必填字段: %s", e.Template, strings.Join(e.Missing, ", "))
+}
+
+// Rendered 模板渲染结果
+type Rendered struct {
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+}
+
+// funcs 模板中可用的函数
+var funcs = template.FuncMap{
+	"join":  strings.Join,
+	"trim":  strings.TrimSpace,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// default 值为空时使用默认值：{{default "待定" .date}}。
+	// 模板按 missingkey=error 解析，字段不存在时在调用 default 前就会渲染失败，
+	// 可能不传的字段需要在 Defaults 中登记（可以为空字符串）
+	"default": func(def string, v any) any {
+		if v == nil || fmt.Sprint(v) == "" {
+			return def
+		}
+		return v
+	},
+}
+
+// compile 校验模板并解析标题、正文和标签模板
+func (t *Template) compile() error {
+	if !namePattern.MatchString(t.Name) {
+		return errors.Wrapf(ErrInvalidTemplate, "名称 %q 只能包含字母、数字、下划线和连字符（最长 64 个字符）", t.Name)
+	}
+	if strings.TrimSpace(t.Title) == "" && strings.TrimSpace(t.Content) == "" {
+		return errors.Wrapf(ErrInvalidTemplate, "模板 %s 的标题和正文不能都为空", t.Name)
+	}
+
+	parse := func(part, text string) (*template.Template, error) {
+		tmpl, err := template.New(t.Name + "." + part).Funcs(funcs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidTemplate, "模板 %s 的 %s: %v", t.Name, part, err)
+		}
+		return tmpl, nil
+	}
+
+	var err error
+	if t.title, err = parse("title", t.Title); err != nil {
+		return err
+	}
+	if t.content, err = parse("content", t.Content); err != nil {
+		return err
+	}
+	t.tags = make([]*template.Template, 0, len(t.Tags))
+	for i, text := range t.Tags {
+		tmpl, err := parse(fmt.Sprintf("tags[%d]", i), text)
+		if err != nil {
+			return err
+		}
+		t.tags = append(t.tags, tmpl)
+	}
+	return nil
+}
+
+// render 检查必填字段后渲染模板
+func (t *Template) render(fields map[string]any) (*Rendered, error) {
+	var missing []string
+	for _, name := range t.Required {
+		if v, ok := fields[name]; !ok || v == nil || v == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &FieldsError{Template: t.Name, Missing: missing}
+	}
+
+	data := make(map[string]any, len(t.Defaults)+len(fields))
+	for k, v := range t.Defaults {
+		data[k] = v
+	}
+	for k, v := range fields {
+		data[k] = normalizeValue(v)
+	}
+
+	execute := func(tmpl *template.Template) (string, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", errors.Wrapf(ErrRenderFailed, "%v", err)
+		}
+		return strings.TrimSpace(buf.String()), nil
+	}
+
+	result := &Rendered{Tags: []string{}}
+	var err error
+	if result.Title, err = execute(t.title); err != nil {
+		return nil, err
+	}
+	if result.Content, err = execute(t.content); err != nil {
+		return nil, err
+	}
+	for _, tmpl := range t.tags {
+		tag, err := execute(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		if tag != "" {
+			result.Tags = append(result.Tags, tag)
+		}
+	}
+	return result, nil
+}
+
+// normalizeValue JSON 中的整数解析为 float64，按整数输出，避免 1000000 显示为 1e+06
+func normalizeValue(v any) any {
+	if f, ok := v.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return int64(f)
+	}
+	return v
+}
+
+// Store 模板存储，持久化到 JSON 文件
+type Store struct {
+	path      string
+	templates map[string]*Template
+	mu        sync.RWMutex
+}
+
+// NewStore 创建模板存储并从文件加载，文件不存在时为空
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload 重新加载模板文件，文件中的模板有错误时保留当前模板
+func (s *Store) Reload() error {
+	commit, err := s.PrepareReload()
+	if err != nil {
+		return err
+	}
+	commit()
+	return nil
+}
+
+// PrepareReload 读取并校验模板文件，调用返回的 commit 后生效
+func (s *Store) PrepareReload() (func(), error) {
+	templates, err := load(s.path)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		s.mu.Lock()
+		s.templates = templates
+		s.mu.Unlock()
+
+		if len(templates) > 0 {
+			logrus.Infof("已加载内容模板: %s (%d 个模板)", s.path, len(templates))
+		}
+	}, nil
+}
+
+// load 读取并校验模板文件
+func load(path string) (map[string]*Template, error) {
+	templates := make(map[string]*Template)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return templates, nil
+		}
+		return nil, errors.Wrap(err, "读取模板文件失败")
+	}
+
+	var list []*Template
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, errors.Wrap(err, "解析模板文件失败")
+	}
+	for _, t := range list {
+		if err := t.compile(); err != nil {
+			return nil, err
+		}
+		if _, ok := templates[t.Name]; ok {
+			return nil, errors.Wrapf(ErrInvalidTemplate, "模板 %s 重复", t.Name)
+		}
+		templates[t.Name] = t
+	}
+	return templates, nil
+}
+
+// List 返回所有模板（按名称排序）
+func (s *Store) List() []*Template {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sortedLocked()
+}
+
+// Get 获取模板，不存在返回 ErrTemplateNotFound
+func (s *Store) Get(name string) (*Template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.templates[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrTemplateNotFound, "模板 %s", name)
+	}
+	return t, nil
+}
+
+// Put 创建或替换模板
+func (s *Store) Put(t *Template) (*Template, error) {
+	saved := *t
+	saved.UpdatedAt = time.Now()
+	if err := saved.compile(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, existed := s.templates[saved.Name]
+	s.templates[saved.Name] = &saved
+	if err := s.saveLocked(); err != nil {
+		if existed {
+			s.templates[saved.Name] = old
+		} else {
+			delete(s.templates, saved.Name)
+		}
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// Delete 删除模板
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.templates[name]
+	if !ok {
+		return errors.Wrapf(ErrTemplateNotFound, "模板 %s", name)
+	}
+
+	delete(s.templates, name)
+	if err := s.saveLocked(); err != nil {
+		s.templates[name] = t
+		return err
+	}
+	return nil
+}
+
+// Render 使用指定模板渲染标题、正文和标签。
+// 模板不存在返回 ErrTemplateNotFound，缺少必填字段返回 *FieldsError
+func (s *Store) Render(name string, fields map[string]any) (*Rendered, error) {
+	t, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.render(fields)
+}
+
+// sortedLocked 按名称排序的模板列表。调用方需持有锁
+func (s *Store) sortedLocked() []*Template {
+	list := make([]*Template, 0, len(s.templates))
+	for _, t := range s.templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// saveLocked 写入模板文件（先写临时文件再重命名，避免写一半）。调用方需持有写锁
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(s.sortedLocked(), "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "序列化模板失败")
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "创建模板文件目录失败")
+	}
+
+	tmp, err := os.CreateTemp(dir, ".templates-*.json")
+	if err != nil {
+		return errors.Wrap(err, "创建临时文件失败")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "写入模板文件失败")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "写入模板文件失败")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), s.path), "保存模板文件失败")
+}
@@ -0,0 +1,105 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReleaseTemplate() *Template {
+	return &Template{
+		Name:     "release",
+		Title:    "{{.name}} 新品发售",
+		Content:  "价格：{{.price}}日元{{if .note}}\n{{.note}}{{end}}",
+		Tags:     []string{"{{.series}}", "手办"},
+		Required: []string{"name", "price"},
+		Defaults: map[string]string{"note": "", "series": ""},
+	}
+}
+
+func TestRender(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "templates.json"))
+	require.NoError(t, err)
+	_, err = store.Put(newReleaseTemplate())
+	require.NoError(t, err)
+
+	rendered, err := store.Render("release", map[string]any{"name": "桓骑", "price": float64(1000000)})
+	require.NoError(t, err)
+	assert.Equal(t, "桓骑 新品发售", rendered.Title)
+	assert.Equal(t, "价格：1000000日元", rendered.Content)
+	assert.Equal(t, []string{"手办"}, rendered.Tags)
+
+	rendered, err = store.Render("release", map[string]any{"name": "腾", "price": 790, "note": "限量", "series": "王者天下"})
+	require.NoError(t, err)
+	assert.Equal(t, "价格：790日元\n限量", rendered.Content)
+	assert.Equal(t, []string{"王者天下", "手办"}, rendered.Tags)
+}
+
+func TestRenderErrors(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "templates.json"))
+	require.NoError(t, err)
+	_, err = store.Put(newReleaseTemplate())
+	require.NoError(t, err)
+	_, err = store.Put(&Template{Name: "event", Title: "{{.name}}", Content: "{{.place}}"})
+	require.NoError(t, err)
+
+	_, err = store.Render("missing", nil)
+	assert.True(t, errors.Is(err, ErrTemplateNotFound))
+
+	_, err = store.Render("release", map[string]any{"name": "", "series": "x"})
+	var fieldsErr *FieldsError
+	require.ErrorAs(t, err, &fieldsErr)
+	assert.Equal(t, []string{"name", "price"}, fieldsErr.Missing)
+
+	// 引用了没有传入、也没有默认值的字段
+	_, err = store.Render("event", map[string]any{"name": "展会"})
+	assert.True(t, errors.Is(err, ErrRenderFailed))
+}
+
+func TestRenderDefaultFunc(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "templates.json"))
+	require.NoError(t, err)
+	_, err = store.Put(&Template{Name: "event", Title: "{{.name}}", Content: `日期：{{default "待定" .date}}`,
+		Defaults: map[string]string{"date": ""}})
+	require.NoError(t, err)
+
+	rendered, err := store.Render("event", map[string]any{"name": "展会"})
+	require.NoError(t, err)
+	assert.Equal(t, "日期：待定", rendered.Content)
+
+	rendered, err = store.Render("event", map[string]any{"name": "展会", "date": "5月1日"})
+	require.NoError(t, err)
+	assert.Equal(t, "日期：5月1日", rendered.Content)
+}
+
+func TestPutValidatesAndPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	store, err := NewStore(path)
+	require.NoError(t, err)
+
+	_, err = store.Put(&Template{Name: "bad name", Title: "x"})
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+	_, err = store.Put(&Template{Name: "bad", Title: "{{.name"})
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+
+	_, err = store.Put(newReleaseTemplate())
+	require.NoError(t, err)
+
+	reloaded, err := NewStore(path)
+	require.NoError(t, err)
+	require.Len(t, reloaded.List(), 1)
+	_, err = reloaded.Render("release", map[string]any{"name": "桓骑", "price": 790})
+	assert.NoError(t, err)
+
+	require.NoError(t, store.Delete("release"))
+	assert.True(t, errors.Is(store.Delete("release"), ErrTemplateNotFound))
+
+	// 文件内容有错误时保留当前模板
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name":"x","title":"{{"}]`), 0644))
+	assert.Error(t, reloaded.Reload())
+	assert.Len(t, reloaded.List(), 1)
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -17,22 +19,32 @@ import (
 type Browser struct {
 	*rod.Browser
 	launcher          *launcher.Launcher
-	incognitoContexts map[string]*rod.Browser // accountID -> 独立的 incognito browser
-	contextsMux       sync.RWMutex            // 保护 incognitoContexts 的并发访问
+	containerName     string                     // 浏览器所在的 Docker 容器，连接断开时重启
+	incognitoContexts map[string]*accountContext // accountID -> 独立的 incognito browser
+	contextsMux       sync.RWMutex               // 保护 incognitoContexts 的并发访问
 }
 
-// restartRodContainer 重启 Rod Docker 容器并等待其就绪
-func restartRodContainer() error {
-	logrus.Info("尝试重启 xhs-poster-rod Docker 容器...")
+// accountContext 账号的 incognito context 及其使用的代理
+type accountContext struct {
+	browser *rod.Browser
+	proxy   string
+}
+
+// restartRodContainer 重启 Rod Docker 容器并等待其就绪，未配置容器名称时返回错误
+func restartRodContainer(name string) error {
+	if name == "" {
+		return errors.New("未配置 Rod 容器名称，无法重启")
+	}
+	logrus.Infof("尝试重启 %s Docker 容器...", name)
 
 	// 重启 rod 容器
-	cmd := exec.Command("docker", "restart", "xhs-poster-rod")
+	cmd := exec.Command("docker", "restart", name)
 	if err := cmd.Run(); err != nil {
-		logrus.Errorf("重启 xhs-poster-rod 容器失败: %v", err)
+		logrus.Errorf("重启 %s 容器失败: %v", name, err)
 		return err
 	}
 
-	logrus.Info("xhs-poster-rod Docker 容器重启成功，等待容器就绪...")
+	logrus.Infof("%s Docker 容器重启成功，等待容器就绪...", name)
 
 	// 等待容器启动 (通常需要几秒钟)
 	time.Sleep(5 * time.Second)
@@ -44,13 +56,18 @@ func restartRodContainer() error {
 // NewPage 创建新页面并加载指定账号的 cookies
 // 多账号时每个 accountID 使用独立的 incognito context，实现 session 隔离和共存
 func (b *Browser) NewPage(accountID string) *rod.Page {
+	return b.NewPageWithProxy(accountID, "")
+}
+
+// NewPageWithProxy 创建新页面，该账号的 incognito context 通过 proxy 访问网络（为空则直连）
+func (b *Browser) NewPageWithProxy(accountID, proxy string) *rod.Page {
 	// 检查浏览器连接是否有效
 	defer func() {
 		if r := recover(); r != nil {
 			logrus.Errorf("创建页面失败: %v", r)
 
 			// 尝试重启 Rod 容器
-			if err := restartRodContainer(); err != nil {
+			if err := restartRodContainer(b.containerName); err != nil {
 				logrus.Errorf("重启 Rod 容器失败: %v", err)
 			}
 
@@ -59,8 +76,8 @@ func (b *Browser) NewPage(accountID string) *rod.Page {
 	}()
 
 	// 获取或创建该账号的独立浏览器 context
-	browserContext := b.getOrCreateIncognitoContext(accountID)
-	
+	browserContext := b.getOrCreateIncognitoContext(accountID, proxy)
+
 	// 在该 context 中创建 page
 	page := browserContext.MustPage()
 
@@ -73,8 +90,8 @@ func (b *Browser) NewPage(accountID string) *rod.Page {
 }
 
 // getOrCreateIncognitoContext 获取或创建账号的独立 incognito context
-// 每个账号有独立的 cookie 存储，实现多账号同时在线
-func (b *Browser) getOrCreateIncognitoContext(accountID string) *rod.Browser {
+// 每个账号有独立的 cookie 存储，实现多账号同时在线；代理变化时重建 context
+func (b *Browser) getOrCreateIncognitoContext(accountID, proxy string) *rod.Browser {
 	// 默认账号或空 accountID，使用主浏览器
 	if accountID == "" {
 		return b.Browser
@@ -82,9 +99,9 @@ func (b *Browser) getOrCreateIncognitoContext(accountID string) *rod.Browser {
 
 	// 读锁：检查是否已存在
 	b.contextsMux.RLock()
-	if incognito, exists := b.incognitoContexts[accountID]; exists {
+	if ac, exists := b.incognitoContexts[accountID]; exists && ac.proxy == proxy {
 		b.contextsMux.RUnlock()
-		return incognito
+		return ac.browser
 	}
 	b.contextsMux.RUnlock()
 
@@ -93,18 +110,63 @@ func (b *Browser) getOrCreateIncognitoContext(accountID string) *rod.Browser {
 	defer b.contextsMux.Unlock()
 
 	// 双重检查（防止并发创建）
-	if incognito, exists := b.incognitoContexts[accountID]; exists {
-		return incognito
+	if ac, exists := b.incognitoContexts[accountID]; exists {
+		if ac.proxy == proxy {
+			return ac.browser
+		}
+		// 代理已变更，释放旧 context
+		logrus.Infof("[Browser] 账号 %s 代理已变更，重建 incognito context", accountID)
+		_ = proto.TargetDisposeBrowserContext{BrowserContextID: ac.browser.BrowserContextID}.Call(b.Browser)
 	}
 
-	// 创建新的 incognito browser（隔离的 cookie 存储）
-	incognito := b.Browser.MustIncognito()
-	b.incognitoContexts[accountID] = incognito
-	
-	logrus.Infof("[Browser] 为账号 %s 创建独立 incognito context", accountID)
+	// 创建新的 incognito browser（隔离的 cookie 存储，可单独设置代理）
+	incognito := b.mustIncognito(proxy)
+	b.incognitoContexts[accountID] = &accountContext{browser: incognito, proxy: proxy}
+
+	if proxy != "" {
+		logrus.Infof("[Browser] 为账号 %s 创建独立 incognito context（代理: %s）", accountID, proxy)
+	} else {
+		logrus.Infof("[Browser] 为账号 %s 创建独立 incognito context", accountID)
+	}
 	return incognito
 }
 
+// NewTemporaryPage 在新建的临时 incognito context 中创建不带 cookies 的页面，不影响账号的 context。
+// 调用方用完后调用返回的 dispose 释放 context（同时关闭其中的页面）
+func (b *Browser) NewTemporaryPage(proxy string) (*rod.Page, func(), error) {
+	res, err := proto.TargetCreateBrowserContext{ProxyServer: proxy}.Call(b.Browser)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "创建临时浏览器 context 失败")
+	}
+	dispose := func() {
+		err := proto.TargetDisposeBrowserContext{BrowserContextID: res.BrowserContextID}.Call(b.Browser)
+		if err != nil {
+			logrus.Warnf("释放临时浏览器 context 失败: %v", err)
+		}
+	}
+
+	incognito := *b.Browser
+	incognito.BrowserContextID = res.BrowserContextID
+	page, err := incognito.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		dispose()
+		return nil, nil, errors.Wrap(err, "创建临时页面失败")
+	}
+	return page, dispose, nil
+}
+
+// mustIncognito 创建 incognito browser，与 rod.Browser.MustIncognito 相同，但支持设置代理
+func (b *Browser) mustIncognito(proxy string) *rod.Browser {
+	res, err := proto.TargetCreateBrowserContext{ProxyServer: proxy}.Call(b.Browser)
+	if err != nil {
+		panic(err)
+	}
+
+	incognito := *b.Browser
+	incognito.BrowserContextID = res.BrowserContextID
+	return &incognito
+}
+
 // Close 关闭浏览器连接
 func (b *Browser) Close() {
 	logrus.Info("断开浏览器连接...")
@@ -121,8 +183,8 @@ func (b *Browser) Close() {
 func NewBrowser(cfg *config.Config) *Browser {
 	logrus.Info("初始化浏览器管理器...")
 
-	// 硬编码使用管理器模式
-	l := launcher.MustNewManaged("")
+	// 使用管理器模式，地址为空时使用 rod 默认的 ws://127.0.0.1:7317
+	l := launcher.MustNewManaged(cfg.Browser.ManagerURL)
 	// Launch with headful mode
 	// l.Headless(false).XVFB("--server-num=5", "--server-args=-screen 0 1600x900x16")
 
@@ -132,7 +194,7 @@ func NewBrowser(cfg *config.Config) *Browser {
 	logrus.Info("连接到远程浏览器...")
 
 	// 创建带超时的上下文用于连接
-	connectCtx, connectCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	connectCtx, connectCancel := context.WithTimeout(context.Background(), cfg.Browser.ConnectTimeout)
 	defer connectCancel()
 
 	// 使用通道来处理连接结果
@@ -163,14 +225,14 @@ func NewBrowser(cfg *config.Config) *Browser {
 			return &Browser{
 				Browser:           res.browser,
 				launcher:          l,
-				incognitoContexts: make(map[string]*rod.Browser),
+				incognitoContexts: make(map[string]*accountContext),
 			}
 		}
 
 		// 初始化阶段连接失败，尝试重启容器并重试一次
 		logrus.Warn("初始连接失败，尝试重启 Rod 容器并重试...")
-		if err := restartRodContainer(); err != nil {
-			logrus.Fatal("重启 Rod 容器失败，无法继续初始化")
+		if err := restartRodContainer(cfg.Browser.ContainerName); err != nil {
+			logrus.Fatalf("重启 Rod 容器失败，无法继续初始化: %v", err)
 		}
 
 		// 重试连接
@@ -184,14 +246,15 @@ func NewBrowser(cfg *config.Config) *Browser {
 		return &Browser{
 			Browser:           browser,
 			launcher:          l,
-			incognitoContexts: make(map[string]*rod.Browser),
+			containerName:     cfg.Browser.ContainerName,
+			incognitoContexts: make(map[string]*accountContext),
 		}
 
 	case <-connectCtx.Done():
 		// 初始化阶段超时，尝试重启容器
 		logrus.Warn("连接超时，尝试重启 Rod 容器...")
-		if err := restartRodContainer(); err != nil {
-			logrus.Fatal("重启 Rod 容器失败，无法继续初始化")
+		if err := restartRodContainer(cfg.Browser.ContainerName); err != nil {
+			logrus.Fatalf("重启 Rod 容器失败，无法继续初始化: %v", err)
 		}
 
 		// 重试连接（不设置超时，因为刚重启）
@@ -205,7 +268,8 @@ func NewBrowser(cfg *config.Config) *Browser {
 		return &Browser{
 			Browser:           browser,
 			launcher:          l,
-			incognitoContexts: make(map[string]*rod.Browser),
+			containerName:     cfg.Browser.ContainerName,
+			incognitoContexts: make(map[string]*accountContext),
 		}
 	}
 }
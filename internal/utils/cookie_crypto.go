@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// cookie 文件加密格式版本
+const cookieEnvelopeVersion = 1
+
+// ErrCookieKeyMissing cookie 文件已加密，但未配置解密密钥
+var ErrCookieKeyMissing = errors.New("cookie 文件已加密，但未配置密钥")
+
+// cookieEnvelope 加密后的 cookie 文件内容，[]byte 字段以 base64 序列化
+type cookieEnvelope struct {
+	Version    int    `json:"version"`
+	KeyID      string `json:"key_id"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// cookieKey 一把 AES-256-GCM 密钥
+type cookieKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// CookieCipher cookie 文件加解密，使用 AES-256-GCM
+// 用当前密钥加密；解密时按 key_id 在当前密钥和旧密钥中查找，便于轮换密钥
+type CookieCipher struct {
+	primary *cookieKey
+	keys    map[string]*cookieKey
+}
+
+var (
+	cookieCipher    *CookieCipher
+	cookieCipherMux sync.RWMutex
+)
+
+// SetCookieCipher 设置全局 cookie 加密器，nil 表示不加密
+func SetCookieCipher(c *CookieCipher) {
+	cookieCipherMux.Lock()
+	defer cookieCipherMux.Unlock()
+	cookieCipher = c
+}
+
+// getCookieCipher 获取全局 cookie 加密器
+func getCookieCipher() *CookieCipher {
+	cookieCipherMux.RLock()
+	defer cookieCipherMux.RUnlock()
+	return cookieCipher
+}
+
+// NewCookieCipher 创建 cookie 加密器，primary 用于加密，previous 为轮换前的旧密钥（只用于解密）
+func NewCookieCipher(primary []byte, previous ...[]byte) (*CookieCipher, error) {
+	c := &CookieCipher{keys: make(map[string]*cookieKey)}
+
+	key, err := newCookieKey(primary)
+	if err != nil {
+		return nil, err
+	}
+	c.primary = key
+	c.keys[key.id] = key
+
+	for _, raw := range previous {
+		key, err := newCookieKey(raw)
+		if err != nil {
+			return nil, errors.Wrap(err, "旧密钥无效")
+		}
+		if _, ok := c.keys[key.id]; !ok {
+			c.keys[key.id] = key
+		}
+	}
+	return c, nil
+}
+
+// newCookieKey 由 32 字节原始密钥创建 AEAD
+func newCookieKey(raw []byte) (*cookieKey, error) {
+	if len(raw) != 32 {
+		return nil, errors.Errorf("cookie 密钥长度必须为 32 字节，实际 %d 字节", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "创建 AES 加密器失败")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "创建 GCM 加密器失败")
+	}
+
+	sum := sha256.Sum256(raw)
+	return &cookieKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// KeyID 当前加密密钥的标识（密钥 SHA-256 的前 4 字节），用于日志和排查
+func (c *CookieCipher) KeyID() string {
+	return c.primary.id
+}
+
+// Encrypt 用当前密钥加密，accountID 作为附加认证数据，防止不同账号的 cookie 文件被互换
+func (c *CookieCipher) Encrypt(plaintext []byte, accountID string) ([]byte, error) {
+	nonce := make([]byte, c.primary.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "生成随机数失败")
+	}
+
+	envelope := cookieEnvelope{
+		Version:    cookieEnvelopeVersion,
+		KeyID:      c.primary.id,
+		Nonce:      nonce,
+		Ciphertext: c.primary.aead.Seal(nil, nonce, plaintext, []byte(accountID)),
+	}
+	return json.Marshal(envelope)
+}
+
+// Decrypt 解密 Encrypt 的输出
+func (c *CookieCipher) Decrypt(data []byte, accountID string) ([]byte, error) {
+	var envelope cookieEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, errors.Wrap(err, "解析加密 cookie 文件失败")
+	}
+	if envelope.Version != cookieEnvelopeVersion {
+		return nil, errors.Errorf("不支持的 cookie 加密格式版本: %d", envelope.Version)
+	}
+
+	key, ok := c.keys[envelope.KeyID]
+	if !ok {
+		return nil, errors.Errorf("找不到 cookie 文件对应的密钥: %s", envelope.KeyID)
+	}
+	if len(envelope.Nonce) != key.aead.NonceSize() {
+		return nil, errors.New("加密 cookie 文件格式错误")
+	}
+
+	plaintext, err := key.aead.Open(nil, envelope.Nonce, envelope.Ciphertext, []byte(accountID))
+	if err != nil {
+		return nil, errors.Wrap(err, "解密 cookie 文件失败")
+	}
+	return plaintext, nil
+}
+
+// isEncryptedCookieData 判断 cookie 文件内容是否已加密：明文是 JSON 数组，加密后是 JSON 对象
+func isEncryptedCookieData(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// ParseCookieKey 解析 cookie 密钥，支持 base64 和 hex 编码的 32 字节密钥
+func ParseCookieKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("cookie 密钥必须是 base64 或 hex 编码的 32 字节密钥")
+}
+
+// ReadCookieKeyFile 从文件读取 cookie 密钥
+func ReadCookieKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "读取 cookie 密钥文件失败")
+	}
+	return ParseCookieKey(string(data))
+}
+
+// GenerateCookieKey 生成 base64 编码的随机 32 字节密钥
+func GenerateCookieKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.Wrap(err, "生成密钥失败")
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
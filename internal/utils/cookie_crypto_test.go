@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testCookieKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestCookieCipherRoundTrip(t *testing.T) {
+	c, err := NewCookieCipher(testCookieKey(1))
+	if err != nil {
+		t.Fatalf("创建加密器失败: %v", err)
+	}
+
+	plaintext := []byte(`[{"name":"web_session","value":"secret"}]`)
+	data, err := c.Encrypt(plaintext, "acct-1")
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	if bytes.Contains(data, []byte("secret")) {
+		t.Fatal("加密结果中包含明文")
+	}
+	if !isEncryptedCookieData(data) || isEncryptedCookieData(plaintext) {
+		t.Fatal("加密格式识别错误")
+	}
+
+	got, err := c.Decrypt(data, "acct-1")
+	if err != nil {
+		t.Fatalf("解密失败: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("解密结果不一致: %s", got)
+	}
+
+	// 其他账号不能使用该文件
+	if _, err := c.Decrypt(data, "acct-2"); err == nil {
+		t.Fatal("不同账号解密应失败")
+	}
+
+	// 错误的密钥不能解密
+	other, _ := NewCookieCipher(testCookieKey(2))
+	if _, err := other.Decrypt(data, "acct-1"); err == nil {
+		t.Fatal("错误密钥解密应失败")
+	}
+}
+
+func TestCookieCipherRotation(t *testing.T) {
+	oldCipher, _ := NewCookieCipher(testCookieKey(1))
+	data, err := oldCipher.Encrypt([]byte("[]"), "acct")
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+
+	rotated, err := NewCookieCipher(testCookieKey(2), testCookieKey(1))
+	if err != nil {
+		t.Fatalf("创建加密器失败: %v", err)
+	}
+	if _, err := rotated.Decrypt(data, "acct"); err != nil {
+		t.Fatalf("旧密钥加密的文件应能解密: %v", err)
+	}
+	if rotated.KeyID() == oldCipher.KeyID() {
+		t.Fatal("轮换后应使用新密钥加密")
+	}
+}
+
+func TestCookieManagerMigratesPlaintext(t *testing.T) {
+	c, _ := NewCookieCipher(testCookieKey(3))
+	SetCookieCipher(c)
+	defer SetCookieCipher(nil)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "acct.json")
+	if err := os.WriteFile(path, []byte(`[{"name":"a","value":"b"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &CookieManager{store: NewFileCookieStore(dir), accountID: "acct"}
+	cookies, err := m.LoadCookies()
+	if err != nil {
+		t.Fatalf("加载明文 cookies 失败: %v", err)
+	}
+	if len(cookies) != 1 || cookies[0].Name != "a" {
+		t.Fatalf("cookies 内容错误: %+v", cookies)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !isEncryptedCookieData(data) {
+		t.Fatal("明文文件应被加密保存")
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("文件权限应为 0600，实际 %v", info.Mode().Perm())
+	}
+
+	cookies, err = m.LoadCookies()
+	if err != nil || len(cookies) != 1 {
+		t.Fatalf("加载加密 cookies 失败: %v", err)
+	}
+
+	SetCookieCipher(nil)
+	if _, err := m.LoadCookies(); err == nil {
+		t.Fatal("未配置密钥时加载加密文件应失败")
+	}
+}
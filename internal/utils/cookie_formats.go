@@ -0,0 +1,277 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/pkg/errors"
+)
+
+// cookie 导入导出格式
+const (
+	// CookieFormatJSON DevTools 协议的 NetworkCookie JSON 数组（cookie 存储使用的格式）
+	CookieFormatJSON = "json"
+	// CookieFormatNetscape curl/wget 和浏览器扩展使用的 cookies.txt
+	CookieFormatNetscape = "netscape"
+	// CookieFormatEditThisCookie EditThisCookie 等浏览器扩展导出的 JSON 数组
+	CookieFormatEditThisCookie = "editthiscookie"
+)
+
+// netscapeHttpOnlyPrefix cookies.txt 中 HttpOnly cookie 行的前缀
+const netscapeHttpOnlyPrefix = "#HttpOnly_"
+
+// editThisCookie EditThisCookie 导出的单个 cookie
+type editThisCookie struct {
+	Domain         string  `json:"domain"`
+	ExpirationDate float64 `json:"expirationDate,omitempty"`
+	HostOnly       bool    `json:"hostOnly"`
+	HTTPOnly       bool    `json:"httpOnly"`
+	Name           string  `json:"name"`
+	Path           string  `json:"path"`
+	SameSite       string  `json:"sameSite"`
+	Secure         bool    `json:"secure"`
+	Session        bool    `json:"session"`
+	StoreID        string  `json:"storeId"`
+	Value          string  `json:"value"`
+	ID             int     `json:"id"`
+}
+
+// DetectCookieFormat 根据内容判断 cookie 格式：JSON 数组中含 expirationDate/hostOnly/storeId
+// 字段的为 EditThisCookie，其他 JSON 为 NetworkCookie，非 JSON 按 cookies.txt 处理
+func DetectCookieFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("[")) {
+		return CookieFormatNetscape
+	}
+
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &items); err != nil {
+		return CookieFormatJSON
+	}
+	for _, item := range items {
+		for _, field := range []string{"expirationDate", "hostOnly", "storeId"} {
+			if _, ok := item[field]; ok {
+				return CookieFormatEditThisCookie
+			}
+		}
+	}
+	return CookieFormatJSON
+}
+
+// ParseCookies 解析指定格式的 cookies，format 为空时自动识别
+func ParseCookies(data []byte, format string) ([]*proto.NetworkCookie, error) {
+	if format == "" {
+		format = DetectCookieFormat(data)
+	}
+
+	switch format {
+	case CookieFormatJSON:
+		var cookies []*proto.NetworkCookie
+		if err := json.Unmarshal(data, &cookies); err != nil {
+			return nil, errors.Wrap(err, "解析 NetworkCookie JSON 失败")
+		}
+		return cookies, nil
+	case CookieFormatEditThisCookie:
+		return parseEditThisCookie(data)
+	case CookieFormatNetscape:
+		return parseNetscapeCookies(data)
+	default:
+		return nil, errors.Errorf("不支持的 cookie 格式: %s", format)
+	}
+}
+
+// FormatCookies 把 cookies 序列化为指定格式
+func FormatCookies(cookies []*proto.NetworkCookie, format string) ([]byte, error) {
+	switch format {
+	case CookieFormatJSON:
+		return json.MarshalIndent(cookies, "", "  ")
+	case CookieFormatEditThisCookie:
+		return formatEditThisCookie(cookies)
+	case CookieFormatNetscape:
+		return formatNetscapeCookies(cookies), nil
+	default:
+		return nil, errors.Errorf("不支持的 cookie 格式: %s", format)
+	}
+}
+
+// parseEditThisCookie 解析 EditThisCookie JSON
+func parseEditThisCookie(data []byte) ([]*proto.NetworkCookie, error) {
+	var items []editThisCookie
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, errors.Wrap(err, "解析 EditThisCookie JSON 失败")
+	}
+
+	cookies := make([]*proto.NetworkCookie, 0, len(items))
+	for _, item := range items {
+		cookie := &proto.NetworkCookie{
+			Name:     item.Name,
+			Value:    item.Value,
+			Domain:   item.Domain,
+			Path:     item.Path,
+			HTTPOnly: item.HTTPOnly,
+			Secure:   item.Secure,
+			Session:  item.Session || item.ExpirationDate == 0,
+			SameSite: sameSiteFromExtension(item.SameSite),
+			Expires:  -1,
+		}
+		if !cookie.Session {
+			cookie.Expires = proto.TimeSinceEpoch(item.ExpirationDate)
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies, nil
+}
+
+// formatEditThisCookie 序列化为 EditThisCookie JSON
+func formatEditThisCookie(cookies []*proto.NetworkCookie) ([]byte, error) {
+	items := make([]editThisCookie, 0, len(cookies))
+	for i, cookie := range cookies {
+		item := editThisCookie{
+			Domain:   cookie.Domain,
+			HostOnly: !strings.HasPrefix(cookie.Domain, "."),
+			HTTPOnly: cookie.HTTPOnly,
+			Name:     cookie.Name,
+			Path:     cookie.Path,
+			SameSite: sameSiteToExtension(cookie.SameSite),
+			Secure:   cookie.Secure,
+			Session:  cookie.Session || cookie.Expires <= 0,
+			StoreID:  "0",
+			Value:    cookie.Value,
+			ID:       i + 1,
+		}
+		if !item.Session {
+			item.ExpirationDate = float64(cookie.Expires)
+		}
+		items = append(items, item)
+	}
+	return json.MarshalIndent(items, "", "  ")
+}
+
+// sameSiteFromExtension 浏览器扩展的 sameSite 取值转为 DevTools 协议取值
+func sameSiteFromExtension(v string) proto.NetworkCookieSameSite {
+	switch strings.ToLower(v) {
+	case "strict":
+		return proto.NetworkCookieSameSiteStrict
+	case "lax":
+		return proto.NetworkCookieSameSiteLax
+	case "no_restriction", "none":
+		return proto.NetworkCookieSameSiteNone
+	default:
+		return ""
+	}
+}
+
+// sameSiteToExtension DevTools 协议的 sameSite 取值转为浏览器扩展取值
+func sameSiteToExtension(v proto.NetworkCookieSameSite) string {
+	switch v {
+	case proto.NetworkCookieSameSiteStrict:
+		return "strict"
+	case proto.NetworkCookieSameSiteLax:
+		return "lax"
+	case proto.NetworkCookieSameSiteNone:
+		return "no_restriction"
+	default:
+		return "unspecified"
+	}
+}
+
+// parseNetscapeCookies 解析 cookies.txt：
+// domain \t includeSubdomains \t path \t secure \t expires \t name \t value
+func parseNetscapeCookies(data []byte) ([]*proto.NetworkCookie, error) {
+	var cookies []*proto.NetworkCookie
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := false
+		if strings.HasPrefix(line, netscapeHttpOnlyPrefix) {
+			httpOnly = true
+			line = strings.TrimPrefix(line, netscapeHttpOnlyPrefix)
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// 值为空时部分工具会省略最后一个字段
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, errors.Errorf("cookies.txt 第 %d 行格式错误：应为 7 个以 tab 分隔的字段", lineNo)
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("cookies.txt 第 %d 行过期时间格式错误: %s", lineNo, fields[4])
+		}
+
+		cookie := &proto.NetworkCookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HTTPOnly: httpOnly,
+			Session:  expires == 0,
+			Expires:  -1,
+		}
+		if expires > 0 {
+			cookie.Expires = proto.TimeSinceEpoch(expires)
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "读取 cookies.txt 失败")
+	}
+	return cookies, nil
+}
+
+// formatNetscapeCookies 序列化为 cookies.txt，会话 cookie 的过期时间写 0
+func formatNetscapeCookies(cookies []*proto.NetworkCookie) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Netscape HTTP Cookie File\n")
+	buf.WriteString("# This file was generated by sns-poster. Do not share it.\n\n")
+
+	for _, cookie := range cookies {
+		var expires int64
+		if !cookie.Session && cookie.Expires > 0 {
+			expires = int64(math.Floor(float64(cookie.Expires)))
+		}
+
+		prefix := ""
+		if cookie.HTTPOnly {
+			prefix = netscapeHttpOnlyPrefix
+		}
+
+		fmt.Fprintf(&buf, "%s%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			prefix,
+			cookie.Domain,
+			netscapeBool(strings.HasPrefix(cookie.Domain, ".")),
+			cookie.Path,
+			netscapeBool(cookie.Secure),
+			expires,
+			cookie.Name,
+			cookie.Value,
+		)
+	}
+	return buf.Bytes()
+}
+
+// netscapeBool cookies.txt 中的布尔值
+func netscapeBool(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
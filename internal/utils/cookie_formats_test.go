@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+func TestCookieFormatsRoundTrip(t *testing.T) {
+	cookies := []*proto.NetworkCookie{
+		{Name: "web_session", Value: "abc", Domain: ".xiaohongshu.com", Path: "/", Expires: 1893456000, HTTPOnly: true, Secure: true, SameSite: proto.NetworkCookieSameSiteLax},
+		{Name: "xsecappid", Value: "xhs-pc-web", Domain: "www.xiaohongshu.com", Path: "/", Expires: -1, Session: true},
+	}
+
+	for _, format := range []string{CookieFormatJSON, CookieFormatNetscape, CookieFormatEditThisCookie} {
+		t.Run(format, func(t *testing.T) {
+			data, err := FormatCookies(cookies, format)
+			if err != nil {
+				t.Fatalf("序列化失败: %v", err)
+			}
+			if got := DetectCookieFormat(data); got != format {
+				t.Fatalf("格式识别错误: %s", got)
+			}
+
+			parsed, err := ParseCookies(data, "")
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if len(parsed) != len(cookies) {
+				t.Fatalf("cookie 数量不一致: %d", len(parsed))
+			}
+
+			first, second := parsed[0], parsed[1]
+			if first.Name != "web_session" || first.Value != "abc" || first.Domain != ".xiaohongshu.com" ||
+				!first.HTTPOnly || !first.Secure || first.Expires != 1893456000 || first.Session {
+				t.Fatalf("持久 cookie 解析错误: %+v", first)
+			}
+			if !second.Session || second.Expires > 0 {
+				t.Fatalf("会话 cookie 解析错误: %+v", second)
+			}
+		})
+	}
+}
+
+func TestParseNetscapeCookiesInvalidLine(t *testing.T) {
+	if _, err := ParseCookies([]byte("# Netscape HTTP Cookie File\n.xiaohongshu.com\tTRUE\t/\n"), CookieFormatNetscape); err == nil {
+		t.Fatal("字段不足时应返回错误")
+	}
+}
@@ -0,0 +1,354 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrEmptyCookieAccount cookie 必须属于某个账号，不再支持账号ID为空的"默认账号"
+	ErrEmptyCookieAccount = errors.New("cookie 账号ID不能为空")
+	// ErrCookieVersionConflict 保存时存储中的版本号与读取时不同，cookies 已被其他实例或请求更新
+	ErrCookieVersionConflict = errors.New("cookies 已被更新，版本号不一致")
+)
+
+// AnyCookieVersion 保存时不检查版本号（如新登录得到的 cookies）
+const AnyCookieVersion int64 = -1
+
+// CookieStore cookie 存储后端，保存的是 CookieManager 序列化（及加密）后的数据
+// 每次保存都是原子的，并返回该账号新的版本号；版本号随保存单调递增，
+// 保存时比较调用方读取到的版本号，避免多实例用旧数据覆盖新数据
+type CookieStore interface {
+	// Load 读取账号的 cookie 数据和版本号，不存在时返回 nil 数据和版本 0
+	Load(accountID string) ([]byte, int64, error)
+	// Save 原子地保存账号的 cookie 数据，返回新的版本号。
+	// expected 不为 AnyCookieVersion 且与当前版本号不同时不保存，返回 ErrCookieVersionConflict
+	Save(accountID string, data []byte, expected int64) (int64, error)
+	// Delete 删除账号的 cookie 数据，不存在时忽略
+	Delete(accountID string) error
+	// List 列出已保存 cookie 的账号ID
+	List() ([]string, error)
+	// Describe 账号 cookie 的存储位置，用于日志
+	Describe(accountID string) string
+}
+
+var (
+	cookieStore    CookieStore = NewFileCookieStore(filepath.Join(".", "cookies"))
+	cookieStoreMux sync.RWMutex
+)
+
+// SetCookieStore 设置全局 cookie 存储后端，默认为 ./cookies 目录下的文件存储
+func SetCookieStore(store CookieStore) {
+	cookieStoreMux.Lock()
+	defer cookieStoreMux.Unlock()
+	cookieStore = store
+}
+
+// getCookieStore 获取全局 cookie 存储后端
+func getCookieStore() CookieStore {
+	cookieStoreMux.RLock()
+	defer cookieStoreMux.RUnlock()
+	return cookieStore
+}
+
+// FileCookieStore 本地文件存储：<dir>/<accountID>.json，文件权限 0600
+// 版本号保存在 <accountID>.version 中，读写时对 <accountID>.lock 加文件锁（多个进程共享目录时同样有效），
+// 数据和版本号都先写临时文件再重命名
+type FileCookieStore struct {
+	dir string
+}
+
+// NewFileCookieStore 创建文件存储
+func NewFileCookieStore(dir string) *FileCookieStore {
+	return &FileCookieStore{dir: dir}
+}
+
+// path 账号的 cookie 文件路径
+func (s *FileCookieStore) path(accountID string) string {
+	return filepath.Join(s.dir, accountID+".json")
+}
+
+// versionPath 账号的版本号文件路径
+func (s *FileCookieStore) versionPath(accountID string) string {
+	return filepath.Join(s.dir, accountID+".version")
+}
+
+// Describe 返回 cookie 文件路径
+func (s *FileCookieStore) Describe(accountID string) string {
+	return s.path(accountID)
+}
+
+// lock 对账号加文件锁（exclusive 为 false 时为共享锁），返回解锁函数
+func (s *FileCookieStore) lock(accountID string, exclusive bool) (func(), error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create cookies directory")
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, accountID+".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open cookies lock file")
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "failed to lock cookies file")
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// readVersion 读取版本号文件，不存在时为 0
+func (s *FileCookieStore) readVersion(accountID string) (int64, error) {
+	data, err := os.ReadFile(s.versionPath(accountID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, errors.Wrap(err, "failed to read cookies version")
+	}
+	version, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid cookies version")
+	}
+	return version, nil
+}
+
+// Load 读取 cookie 文件和版本号
+func (s *FileCookieStore) Load(accountID string) ([]byte, int64, error) {
+	if accountID == "" {
+		return nil, 0, ErrEmptyCookieAccount
+	}
+	unlock, err := s.lock(accountID, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(s.path(accountID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil // 文件不存在是正常的
+		}
+		return nil, 0, errors.Wrap(err, "failed to read cookies file")
+	}
+
+	version, err := s.readVersion(accountID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, version, nil
+}
+
+// Save 加锁后比较版本号，先写数据再递增版本号，都先写临时文件再重命名，避免写一半的文件
+func (s *FileCookieStore) Save(accountID string, data []byte, expected int64) (int64, error) {
+	if accountID == "" {
+		return 0, ErrEmptyCookieAccount
+	}
+	unlock, err := s.lock(accountID, true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	current, err := s.readVersion(accountID)
+	if err != nil {
+		return 0, err
+	}
+	if expected != AnyCookieVersion && expected != current {
+		return 0, errors.Wrapf(ErrCookieVersionConflict, "期望版本 %d，当前版本 %d", expected, current)
+	}
+
+	if err := writeFileAtomic(s.dir, s.path(accountID), data); err != nil {
+		return 0, errors.Wrap(err, "failed to save cookies file")
+	}
+	version := current + 1
+	if err := writeFileAtomic(s.dir, s.versionPath(accountID), []byte(strconv.FormatInt(version, 10))); err != nil {
+		return 0, errors.Wrap(err, "failed to save cookies version")
+	}
+	return version, nil
+}
+
+// writeFileAtomic 在 dir 中写临时文件（权限 0600）后重命名为 path
+func writeFileAtomic(dir, path string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, ".cookies-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete 删除 cookie 文件，保留版本号，重新登录后版本号继续递增
+func (s *FileCookieStore) Delete(accountID string) error {
+	if accountID == "" {
+		return ErrEmptyCookieAccount
+	}
+	unlock, err := s.lock(accountID, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(s.path(accountID)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove cookies file")
+	}
+	return nil
+}
+
+// List 列出 <dir>/*.json 对应的账号ID
+func (s *FileCookieStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read cookies directory")
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	return ids, nil
+}
+
+// redisCookieTimeout 单次 Redis 操作超时时间
+const redisCookieTimeout = 5 * time.Second
+
+// RedisCookieStore Redis 存储，多个实例共享登录 session
+// 每个账号一个 hash：<prefix>:<accountID>，字段 data 为 cookie 数据，version 为版本号
+type RedisCookieStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisCookieStore 创建 Redis 存储，prefix 为 key 前缀
+func NewRedisCookieStore(client *redis.Client, prefix string) *RedisCookieStore {
+	return &RedisCookieStore{client: client, prefix: prefix}
+}
+
+// key 账号对应的 Redis key
+func (s *RedisCookieStore) key(accountID string) string {
+	return s.prefix + ":" + accountID
+}
+
+// Describe 返回 Redis key
+func (s *RedisCookieStore) Describe(accountID string) string {
+	return "redis:" + s.key(accountID)
+}
+
+// Load 读取 cookie 数据和版本号
+func (s *RedisCookieStore) Load(accountID string) ([]byte, int64, error) {
+	if accountID == "" {
+		return nil, 0, ErrEmptyCookieAccount
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisCookieTimeout)
+	defer cancel()
+
+	values, err := s.client.HMGet(ctx, s.key(accountID), "data", "version").Result()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to read cookies from redis")
+	}
+
+	data, ok := values[0].(string)
+	if !ok {
+		return nil, 0, nil
+	}
+
+	var version int64
+	if v, ok := values[1].(string); ok {
+		version, _ = strconv.ParseInt(v, 10, 64)
+	}
+	return []byte(data), version, nil
+}
+
+// saveCookieScript 版本号一致（或 ARGV[2] 为 -1）时写入数据并递增版本号，否则返回 -1
+var saveCookieScript = redis.NewScript(`
+local current = tonumber(redis.call('HGET', KEYS[1], 'version') or '0')
+local expected = tonumber(ARGV[2])
+if expected >= 0 and expected ~= current then
+	return -1
+end
+redis.call('HSET', KEYS[1], 'data', ARGV[1])
+return redis.call('HINCRBY', KEYS[1], 'version', 1)
+`)
+
+// Save 用 Lua 脚本原子地比较版本号、写入数据并递增版本号
+func (s *RedisCookieStore) Save(accountID string, data []byte, expected int64) (int64, error) {
+	if accountID == "" {
+		return 0, ErrEmptyCookieAccount
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisCookieTimeout)
+	defer cancel()
+
+	version, err := saveCookieScript.Run(ctx, s.client, []string{s.key(accountID)}, data, expected).Int64()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to save cookies to redis")
+	}
+	if version < 0 {
+		return 0, errors.Wrapf(ErrCookieVersionConflict, "期望版本 %d", expected)
+	}
+	return version, nil
+}
+
+// Delete 删除账号的 cookie 数据，保留版本号，重新登录后版本号继续递增
+func (s *RedisCookieStore) Delete(accountID string) error {
+	if accountID == "" {
+		return ErrEmptyCookieAccount
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisCookieTimeout)
+	defer cancel()
+
+	if err := s.client.HDel(ctx, s.key(accountID), "data").Err(); err != nil {
+		return errors.Wrap(err, "failed to remove cookies from redis")
+	}
+	return nil
+}
+
+// List 扫描 <prefix>:* 列出账号ID
+func (s *RedisCookieStore) List() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisCookieTimeout)
+	defer cancel()
+
+	var ids []string
+	iter := s.client.Scan(ctx, 0, s.prefix+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		id := strings.TrimPrefix(iter.Val(), s.prefix+":")
+		// 已删除（只剩版本号）的账号不列出
+		exists, err := s.client.HExists(ctx, iter.Val(), "data").Result()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list cookies in redis")
+		}
+		if exists {
+			ids = append(ids, id)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to list cookies in redis")
+	}
+	return ids, nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCookieStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileCookieStore(dir)
+
+	data, version, err := store.Load("acct")
+	if err != nil || data != nil || version != 0 {
+		t.Fatalf("未保存时应返回空数据: %v %v %v", data, version, err)
+	}
+
+	v1, err := store.Save("acct", []byte("[]"), 0)
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	v2, err := store.Save("acct", []byte(`[{"name":"a"}]`), v1)
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	if v2 <= v1 {
+		t.Fatalf("版本号应递增: %d -> %d", v1, v2)
+	}
+
+	// 按旧版本号保存应冲突且不覆盖数据
+	if _, err := store.Save("acct", []byte("stale"), v1); !errors.Is(err, ErrCookieVersionConflict) {
+		t.Fatalf("旧版本号保存应返回冲突: %v", err)
+	}
+
+	data, version, err = store.Load("acct")
+	if err != nil || string(data) != `[{"name":"a"}]` || version != v2 {
+		t.Fatalf("读取结果错误: %s %d %v", data, version, err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "acct.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("文件权限应为 0600，实际 %v", info.Mode().Perm())
+	}
+
+	// 临时文件不应残留
+	tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(tmps) != 0 {
+		t.Fatalf("临时文件未清理: %v", tmps)
+	}
+
+	ids, err := store.List()
+	if err != nil || len(ids) != 1 || ids[0] != "acct" {
+		t.Fatalf("List 结果错误: %v %v", ids, err)
+	}
+
+	if err := store.Delete("acct"); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+	if err := store.Delete("acct"); err != nil {
+		t.Fatalf("重复删除不应报错: %v", err)
+	}
+	if data, _, _ := store.Load("acct"); data != nil {
+		t.Fatal("删除后应读取不到数据")
+	}
+
+	// 删除后版本号继续递增
+	if v3, err := store.Save("acct", []byte("[]"), AnyCookieVersion); err != nil || v3 != v2+1 {
+		t.Fatalf("删除后保存的版本号应为 %d: %d %v", v2+1, v3, err)
+	}
+}
@@ -2,75 +2,147 @@ package utils
 
 import (
 	"encoding/json"
-	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/proto"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
-// CookieManager Cookie管理器，支持按账号隔离
+// CookieManager Cookie管理器，支持按账号隔离，数据保存在全局 CookieStore 中
+// 加载过 cookies 后，保存时检查存储中的版本号未变，避免用旧数据覆盖其他实例保存的新数据
 type CookieManager struct {
-	filePath  string
+	store     CookieStore
 	accountID string
-}
-
-// NewCookieManager 创建Cookie管理器（默认账号，向后兼容）
-func NewCookieManager() *CookieManager {
-	return NewCookieManagerForAccount("")
+	version   int64 // 最近一次加载或保存的版本号，未加载时为 AnyCookieVersion
 }
 
 // NewCookieManagerForAccount 创建指定账号的Cookie管理器，多账号时每个账号独立文件
 func NewCookieManagerForAccount(accountID string) *CookieManager {
 	return &CookieManager{
-		filePath:  getCookiesFilePath(accountID),
+		store:     getCookieStore(),
 		accountID: accountID,
+		version:   AnyCookieVersion,
 	}
 }
 
-// AccountID 返回当前管理器对应的账号ID，空表示默认账号
+// AccountID 返回当前管理器对应的账号ID
 func (c *CookieManager) AccountID() string {
 	return c.accountID
 }
 
-// LoadCookies 加载Cookies
+// LoadCookies 加载Cookies，加密的数据自动解密
+// 配置了密钥时，旧的明文数据读取后会重新加密保存
 func (c *CookieManager) LoadCookies() ([]*proto.NetworkCookie, error) {
-	data, err := os.ReadFile(c.filePath)
+	data, version, err := c.store.Load(c.accountID)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil // 文件不存在是正常的
+		return nil, err
+	}
+	c.version = version
+	if data == nil {
+		return nil, nil // 没有保存过是正常的
+	}
+
+	location := c.store.Describe(c.accountID)
+	cipher := getCookieCipher()
+	encrypted := isEncryptedCookieData(data)
+	if encrypted {
+		if cipher == nil {
+			return nil, errors.Wrap(ErrCookieKeyMissing, location)
+		}
+		if data, err = cipher.Decrypt(data, c.accountID); err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt cookies %s", location)
 		}
-		return nil, errors.Wrap(err, "failed to read cookies file")
 	}
 
 	var cookies []*proto.NetworkCookie
 	if err := json.Unmarshal(data, &cookies); err != nil {
 		return nil, errors.Wrap(err, "failed to unmarshal cookies")
 	}
+	logrus.Debugf("已加载 cookies: %s (版本 %d)", location, version)
+
+	if !encrypted && cipher != nil {
+		if err := c.writeCookies(cookies); err != nil {
+			logrus.Warnf("明文 cookies %s 加密失败: %v", location, err)
+		} else {
+			logrus.Infof("明文 cookies %s 已加密保存", location)
+		}
+	}
 
 	return cookies, nil
 }
 
-// SaveCookies 保存Cookies
+// SaveCookies 保存Cookies，之前用同一个管理器加载过时，cookies 已被更新则返回 ErrCookieVersionConflict
 func (c *CookieManager) SaveCookies(page *rod.Page) error {
 	cookies, err := page.Browser().GetCookies()
 	if err != nil {
 		return errors.Wrap(err, "failed to get cookies from browser")
 	}
 
+	return c.writeCookies(cookies)
+}
+
+// Reencrypt 用当前密钥重新加密 cookies（轮换密钥或迁移明文数据），没有保存过时忽略
+func (c *CookieManager) Reencrypt() error {
+	if getCookieCipher() == nil {
+		return ErrCookieKeyMissing
+	}
+
+	cookies, err := c.LoadCookies()
+	if err != nil {
+		return err
+	}
+	if cookies == nil {
+		return nil
+	}
+	return c.writeCookies(cookies)
+}
+
+// writeCookies 序列化 cookies 并写入存储，配置了密钥时加密
+func (c *CookieManager) writeCookies(cookies []*proto.NetworkCookie) error {
 	data, err := json.Marshal(cookies)
 	if err != nil {
 		return errors.Wrap(err, "failed to marshal cookies")
 	}
 
-	// 确保目录存在
-	dir := filepath.Dir(c.filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return errors.Wrap(err, "failed to create cookies directory")
+	if cipher := getCookieCipher(); cipher != nil {
+		if data, err = cipher.Encrypt(data, c.accountID); err != nil {
+			return errors.Wrap(err, "failed to encrypt cookies")
+		}
+	}
+
+	version, err := c.store.Save(c.accountID, data, c.version)
+	if err != nil {
+		return err
+	}
+	c.version = version
+	logrus.Debugf("已保存 cookies: %s (版本 %d)", c.store.Describe(c.accountID), version)
+	return nil
+}
+
+// ReencryptCookieFiles 用当前密钥重新加密所有账号保存的 cookies，返回处理的账号数
+func ReencryptCookieFiles() (int, error) {
+	ids, err := ListCookieAccountIDs()
+	if err != nil {
+		return 0, err
 	}
 
-	return os.WriteFile(c.filePath, data, 0644)
+	var failed []string
+	count := 0
+	for _, id := range ids {
+		if err := NewCookieManagerForAccount(id).Reencrypt(); err != nil {
+			logrus.Errorf("重新加密账号 %s 的 cookies 失败: %v", id, err)
+			failed = append(failed, id)
+			continue
+		}
+		count++
+	}
+
+	if len(failed) > 0 {
+		return count, errors.Errorf("%d 个账号的 cookies 重新加密失败: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return count, nil
 }
 
 // ClearCookies 清理浏览器中的 Cookies（当前页面所在浏览器）
@@ -82,12 +154,9 @@ func (c *CookieManager) ClearCookies(page *rod.Page) error {
 	return nil
 }
 
-// ClearCookieFile 删除该账号的 cookie 存储文件，登出后下次将无 cookie 可用
+// ClearCookieFile 删除该账号保存的 cookies，登出后下次将无 cookie 可用
 func (c *CookieManager) ClearCookieFile() error {
-	if err := os.Remove(c.filePath); err != nil && !os.IsNotExist(err) {
-		return errors.Wrap(err, "failed to remove cookies file")
-	}
-	return nil
+	return c.store.Delete(c.accountID)
 }
 
 // SetCookies 设置Cookies到浏览器
@@ -97,6 +166,11 @@ func (c *CookieManager) SetCookies(page *rod.Page) error {
 		return err
 	}
 
+	return ApplyCookies(page, cookies)
+}
+
+// ApplyCookies 把 cookies 设置到页面所在浏览器（context）中
+func ApplyCookies(page *rod.Page, cookies []*proto.NetworkCookie) error {
 	if len(cookies) == 0 {
 		return nil // 没有cookies需要设置
 	}
@@ -122,23 +196,7 @@ func (c *CookieManager) SetCookies(page *rod.Page) error {
 	return page.Browser().SetCookies(cookieParams)
 }
 
-// getCookiesFilePath 获取cookies文件路径
-// - accountID 为空或未指定时：使用 ./cookies.json（单账号默认路径）
-// - accountID 非空时：使用 ./cookies/<accountID>.json（多账号隔离）
-func getCookiesFilePath(accountID string) string {
-	baseDir := "."
-	
-	// accountID 为空：使用默认单账号路径 cookies.json
-	if accountID == "" {
-		// 向后兼容：优先使用旧的 /tmp/cookies.json（如果存在）
-		tmpPath := filepath.Join(os.TempDir(), "cookies.json")
-		if _, err := os.Stat(tmpPath); err == nil {
-			return tmpPath
-		}
-		// 默认使用当前目录的 cookies.json
-		return filepath.Join(baseDir, "cookies.json")
-	}
-	
-	// accountID 非空：使用 cookies/<accountID>.json 实现多账号隔离
-	return filepath.Join(baseDir, "cookies", accountID+".json")
+// ListCookieAccountIDs 列出已保存 cookies 的账号ID
+func ListCookieAccountIDs() ([]string, error) {
+	return getCookieStore().List()
 }
@@ -0,0 +1,571 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"sns-poster/internal/config"
+
+	"github.com/pkg/errors"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册 webp 解码
+)
+
+// 重新编码 JPEG 时逐步降低质量、缩小尺寸，直到不超过大小上限
+const (
+	minJPEGQuality   = 60
+	jpegQualityStep  = 10
+	shrinkFactor     = 0.75
+	maxShrinkAttempt = 4
+)
+
+// NormalizeOptions 图片规范化参数
+type NormalizeOptions struct {
+	MaxSize        int64   // 最大字节数
+	MaxPixels      int64   // 宽×高的上限，超出时不解码直接拒绝，0 表示不限制
+	MaxDimension   int     // 最长边像素，超出时等比缩小
+	MaxAspectRatio float64 // 长边与短边之比的上限，超出时用白色补边，0 表示不限制
+	JPEGQuality    int     // 重新编码 JPEG 的初始质量
+}
+
+// NewNormalizeOptions 按配置创建图片规范化参数，maxSize 为发布平台的单张图片大小上限
+func NewNormalizeOptions(cfg *config.ImageConfig, maxSize int64) NormalizeOptions {
+	return NormalizeOptions{
+		MaxSize:        maxSize,
+		MaxPixels:      int64(cfg.MaxMegapixels) * 1000 * 1000,
+		MaxDimension:   cfg.MaxDimension,
+		MaxAspectRatio: cfg.MaxAspectRatio,
+		JPEGQuality:    cfg.JPEGQuality,
+	}
+}
+
+// ImageDiagnostics 单张图片的规范化结果
+type ImageDiagnostics struct {
+	Format       string   `json:"format"` // 原图格式：jpeg、png、gif、webp
+	Width        int      `json:"width"`
+	Height       int      `json:"height"`
+	Size         int64    `json:"size"`
+	OutputFormat string   `json:"output_format"`
+	OutputWidth  int      `json:"output_width"`
+	OutputHeight int      `json:"output_height"`
+	OutputSize   int64    `json:"output_size"`
+	Quality      int      `json:"quality,omitempty"`     // 重新编码 JPEG 时的质量
+	Orientation  int      `json:"orientation,omitempty"` // EXIF 方向（2-8 时已按方向旋转）
+	Actions      []string `json:"actions"`               // 执行的处理，为空表示原图直接上传
+	Warnings     []string `json:"warnings,omitempty"`
+}
+
+func (d *ImageDiagnostics) action(format string, args ...any) {
+	d.Actions = append(d.Actions, fmt.Sprintf(format, args...))
+}
+
+// NormalizeImage 把图片规范化为小红书能接受的 JPEG/PNG：转换 webp、gif 等格式，CMYK 转为 RGB，
+// 按 EXIF 方向旋转，缩小到最大边长以内，删除 EXIF 等元数据，重新编码到不超过大小上限。
+// 已经符合要求的 JPEG/PNG 不重新编码，只无损删除元数据；不需要处理时返回原路径。
+// 处理后的图片保存在 outDir 中
+func NormalizeImage(path, outDir string, opts NormalizeOptions) (string, *ImageDiagnostics, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "读取图片失败")
+	}
+	if isHEIF(data) {
+		return "", nil, errors.New("不支持 HEIC/HEIF 图片，请转换为 JPEG 后上传")
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, errors.Wrap(err, "无法识别的图片格式")
+	}
+	// 解码前检查像素数：很小的文件也可以声明极大的尺寸，解码时会占用大量内存
+	if pixels := int64(cfg.Width) * int64(cfg.Height); opts.MaxPixels > 0 && pixels > opts.MaxPixels {
+		return "", nil, errors.Errorf("图片尺寸过大: %dx%d 超过 %d 万像素", cfg.Width, cfg.Height, opts.MaxPixels/10000)
+	}
+	diag := &ImageDiagnostics{
+		Format: format,
+		Width:  cfg.Width,
+		Height: cfg.Height,
+		Size:   int64(len(data)),
+		// 默认与原图相同，重新编码后更新
+		OutputFormat: format,
+		OutputWidth:  cfg.Width,
+		OutputHeight: cfg.Height,
+		Actions:      []string{},
+	}
+	if format == "jpeg" {
+		diag.Orientation = jpegOrientation(data)
+	}
+
+	// 不需要解码像素的情况：只删除元数据
+	if !needsReencode(format, cfg, diag.Orientation, opts) {
+		stripped, removed := stripMetadata(format, data)
+		if int64(len(stripped)) <= opts.MaxSize {
+			if removed == 0 {
+				diag.OutputSize = diag.Size
+				return path, diag, nil
+			}
+			diag.action("删除元数据 (%d 段)", removed)
+			return writeNormalized(outDir, data, format, stripped, diag)
+		}
+	}
+
+	img, err := decodeImage(data, format, diag)
+	if err != nil {
+		return "", nil, err
+	}
+	if cfg.ColorModel == color.CMYKModel {
+		diag.action("CMYK 转为 RGB")
+	}
+	diag.action("删除元数据")
+
+	nrgba := toNRGBA(img)
+	if diag.Orientation > 1 {
+		nrgba = applyOrientation(nrgba, diag.Orientation)
+		diag.action("按 EXIF 方向 %d 旋转", diag.Orientation)
+	}
+	// 先缩小再补白：补白只加长短边，不会超过长边，避免按原始分辨率分配补白后的大图
+	if opts.MaxDimension > 0 {
+		nrgba = fitDimension(nrgba, opts.MaxDimension, diag)
+	}
+	nrgba = padAspectRatio(nrgba, opts.MaxAspectRatio, diag)
+
+	out, outFormat, err := encodeWithinSize(nrgba, format, opts, diag)
+	if err != nil {
+		return "", nil, err
+	}
+	if outFormat != format {
+		diag.action("%s 转为 %s", format, outFormat)
+	}
+	return writeNormalized(outDir, data, outFormat, out, diag)
+}
+
+// needsReencode 是否需要解码重新编码：格式不是 JPEG/PNG、CMYK、需要旋转、尺寸或长宽比超出
+func needsReencode(format string, cfg image.Config, orientation int, opts NormalizeOptions) bool {
+	long, short := cfg.Width, cfg.Height
+	if short > long {
+		long, short = short, long
+	}
+	return (format != "jpeg" && format != "png") ||
+		cfg.ColorModel == color.CMYKModel ||
+		orientation > 1 ||
+		(opts.MaxDimension > 0 && long > opts.MaxDimension) ||
+		(opts.MaxAspectRatio > 0 && short > 0 && float64(long)/float64(short) > opts.MaxAspectRatio)
+}
+
+// decodeImage 解码图片，动图只解码第一帧
+func decodeImage(data []byte, format string, diag *ImageDiagnostics) (image.Image, error) {
+	if format == "gif" {
+		if frames := gifFrameCount(data); frames > 1 {
+			diag.Warnings = append(diag.Warnings, fmt.Sprintf("动图共 %d 帧，只使用第一帧", frames))
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "解码图片失败")
+	}
+	return img, nil
+}
+
+// encodeWithinSize 编码为不超过大小上限的 JPEG 或 PNG：PNG 原图和有透明像素的图片优先 PNG，
+// 超过上限时铺白底转为 JPEG；JPEG 逐步降低质量，仍超过时缩小尺寸
+func encodeWithinSize(img *image.NRGBA, format string, opts NormalizeOptions, diag *ImageDiagnostics) ([]byte, string, error) {
+	if format == "png" || (format != "jpeg" && !img.Opaque()) {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", errors.Wrap(err, "编码 PNG 失败")
+		}
+		if int64(buf.Len()) <= opts.MaxSize {
+			b := img.Bounds()
+			diag.OutputWidth, diag.OutputHeight = b.Dx(), b.Dy()
+			return buf.Bytes(), "png", nil
+		}
+		if !img.Opaque() {
+			diag.action("PNG 超过大小上限，透明部分铺白底")
+		}
+	}
+
+	flat := flatten(img)
+	quality := opts.JPEGQuality
+	if quality <= 0 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+	for attempt := 0; ; attempt++ {
+		for q := quality; q >= minJPEGQuality || q == quality; q -= jpegQualityStep {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: q}); err != nil {
+				return nil, "", errors.Wrap(err, "编码 JPEG 失败")
+			}
+			if int64(buf.Len()) <= opts.MaxSize {
+				b := flat.Bounds()
+				diag.OutputWidth, diag.OutputHeight = b.Dx(), b.Dy()
+				diag.Quality = q
+				return buf.Bytes(), "jpeg", nil
+			}
+		}
+		if attempt >= maxShrinkAttempt {
+			return nil, "", errors.Errorf("图片压缩后仍超过 %dMB", opts.MaxSize/1024/1024)
+		}
+
+		b := flat.Bounds()
+		w, h := max(int(float64(b.Dx())*shrinkFactor), 1), max(int(float64(b.Dy())*shrinkFactor), 1)
+		diag.action("超过大小上限，缩小 %dx%d -> %dx%d", b.Dx(), b.Dy(), w, h)
+		flat = scale(flat, w, h)
+	}
+}
+
+// toNRGBA 转为 NRGBA，CMYK、调色板等颜色模型统一转换
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// flatten 透明部分铺白底
+func flatten(img *image.NRGBA) *image.NRGBA {
+	if img.Opaque() {
+		return img
+	}
+	dst := image.NewNRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+// scale 缩放到指定尺寸
+func scale(img *image.NRGBA, w, h int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return dst
+}
+
+// fitDimension 最长边超过 maxDim 时等比缩小
+func fitDimension(img *image.NRGBA, maxDim int, diag *ImageDiagnostics) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxDim && h <= maxDim {
+		return img
+	}
+
+	nw, nh := maxDim, h*maxDim/w
+	if h > w {
+		nw, nh = w*maxDim/h, maxDim
+	}
+	diag.action("缩小 %dx%d -> %dx%d", w, h, max(nw, 1), max(nh, 1))
+	return scale(img, max(nw, 1), max(nh, 1))
+}
+
+// padAspectRatio 长宽比超过上限时在短边两侧补白，使长宽比等于上限
+func padAspectRatio(img *image.NRGBA, maxRatio float64, diag *ImageDiagnostics) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if maxRatio <= 0 || w == 0 || h == 0 {
+		return img
+	}
+
+	nw, nh := w, h
+	switch {
+	case float64(h)/float64(w) > maxRatio:
+		nw = int(float64(h)/maxRatio + 0.5)
+	case float64(w)/float64(h) > maxRatio:
+		nh = int(float64(w)/maxRatio + 0.5)
+	default:
+		return img
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, nw, nh))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	offset := image.Pt((nw-w)/2, (nh-h)/2)
+	draw.Draw(dst, img.Bounds().Add(offset), img, image.Point{}, draw.Src)
+	diag.action("长宽比超过 %.2g，补白 %dx%d -> %dx%d", maxRatio, w, h, nw, nh)
+	return dst
+}
+
+// applyOrientation 按 EXIF 方向（2-8）变换图片，使其按正常方向显示
+func applyOrientation(img *image.NRGBA, orientation int) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转 90°
+				dx, dy = h-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转 90°
+				dx, dy = y, w-1-x
+			default:
+				return img
+			}
+			si := img.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], img.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// writeNormalized 把处理后的图片写入 outDir，文件名由原图内容和输出格式决定
+func writeNormalized(outDir string, original []byte, format string, data []byte, diag *ImageDiagnostics) (string, *ImageDiagnostics, error) {
+	ext := ".jpg"
+	if format == "png" {
+		ext = ".png"
+	}
+	name := fmt.Sprintf("norm_%x%s", md5.Sum(append(append([]byte{}, original...), diag.actionKey()...)), ext)
+	path := filepath.Join(outDir, name)
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", nil, errors.Wrap(err, "创建图片目录失败")
+	}
+	// 先写入临时文件再改名：同一张图片同时发布时，另一个请求可能正在上传同名文件
+	tmp, err := os.CreateTemp(outDir, ".norm-*.tmp")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "保存处理后的图片失败")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", nil, errors.Wrap(err, "保存处理后的图片失败")
+	}
+	if err := tmp.Close(); err != nil {
+		return "", nil, errors.Wrap(err, "保存处理后的图片失败")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", nil, errors.Wrap(err, "保存处理后的图片失败")
+	}
+	diag.OutputFormat = format
+	diag.OutputSize = int64(len(data))
+	return path, diag, nil
+}
+
+// actionKey 区分同一张原图不同处理参数的输出文件
+func (d *ImageDiagnostics) actionKey() []byte {
+	return []byte(fmt.Sprint(d.Actions, d.Quality, d.OutputWidth, d.OutputHeight))
+}
+
+// gifFrameCount 不解码像素，按块结构统计 GIF 的帧数，格式错误时返回已统计的帧数
+func gifFrameCount(data []byte) int {
+	// 文件头 6 字节、逻辑屏幕描述 7 字节，之后是可选的全局颜色表
+	const headerLen = 13
+	if len(data) < headerLen {
+		return 0
+	}
+	i := headerLen
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << (flags&0x07 + 1)
+	}
+
+	// skipSubBlocks 跳过以长度 0 结尾的数据子块
+	skipSubBlocks := func(i int) int {
+		for i < len(data) && data[i] != 0 {
+			i += int(data[i]) + 1
+		}
+		return i + 1
+	}
+
+	frames := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // 扩展块：标签 1 字节 + 数据子块
+			i = skipSubBlocks(i + 2)
+		case 0x2C: // 图像描述 10 字节 + 可选的局部颜色表 + LZW 最小码长 1 字节 + 数据子块
+			if i+10 > len(data) {
+				return frames
+			}
+			frames++
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i = skipSubBlocks(i + 1)
+		default: // 0x3B 文件结束或格式错误
+			return frames
+		}
+	}
+	return frames
+}
+
+// isHEIF 是否是 HEIC/HEIF 图片（ISO BMFF 容器，ftyp 品牌为 heic、heix、mif1 等）
+func isHEIF(data []byte) bool {
+	if len(data) < 12 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	switch string(data[8:12]) {
+	case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+		return true
+	}
+	return false
+}
+
+// jpegOrientation 读取 JPEG 中 EXIF 的方向（1-8），没有时返回 0
+func jpegOrientation(data []byte) int {
+	orientation := 0
+	walkJPEGSegments(data, func(marker byte, segment []byte) bool {
+		if marker != 0xE1 || len(segment) < 14 || string(segment[:6]) != "Exif\x00\x00" {
+			return true
+		}
+		orientation = exifOrientation(segment[6:])
+		return false
+	})
+	return orientation
+}
+
+// exifOrientation 从 TIFF 结构的 IFD0 中读取方向标签（0x0112）
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8 : entry+10])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// walkJPEGSegments 依次处理 JPEG 图像数据（SOS）之前的标记段，fn 返回 false 时停止
+func walkJPEGSegments(data []byte, fn func(marker byte, segment []byte) bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			return
+		}
+		if !fn(marker, data[i+4:i+2+length]) {
+			return
+		}
+		i += 2 + length
+	}
+}
+
+// stripMetadata 无损删除元数据：JPEG 的 EXIF/XMP（APP1）、IPTC（APP13）、注释等段，
+// 保留 JFIF（APP0）、ICC 色彩配置（APP2）和 Adobe（APP14）；PNG 的文本、EXIF 和时间块。返回删除的段数
+func stripMetadata(format string, data []byte) ([]byte, int) {
+	switch format {
+	case "jpeg":
+		return stripJPEGMetadata(data)
+	case "png":
+		return stripPNGMetadata(data)
+	}
+	return data, 0
+}
+
+func stripJPEGMetadata(data []byte) ([]byte, int) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data, 0
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	removed := 0
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			return data, 0
+		}
+		isMetadata := marker == 0xFE || (marker >= 0xE1 && marker <= 0xEF && marker != 0xE2 && marker != 0xEE)
+		if isMetadata {
+			removed++
+		} else {
+			out = append(out, data[i:i+2+length]...)
+		}
+		i += 2 + length
+	}
+	if removed == 0 {
+		return data, 0
+	}
+	return append(out, data[i:]...), removed
+}
+
+// pngMetadataChunks 删除的 PNG 辅助块
+var pngMetadataChunks = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true}
+
+func stripPNGMetadata(data []byte) ([]byte, int) {
+	const sigLen = 8
+	if len(data) < sigLen {
+		return data, 0
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:sigLen]...)
+	removed := 0
+	for i := sigLen; i < len(data); {
+		if i+8 > len(data) {
+			return data, 0
+		}
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return data, 0
+		}
+		if pngMetadataChunks[string(data[i+4:i+8])] {
+			removed++
+		} else {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	if removed == 0 {
+		return data, 0
+	}
+	return out, removed
+}
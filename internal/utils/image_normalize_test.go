@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testNormalizeOptions() NormalizeOptions {
+	return NormalizeOptions{MaxSize: 1024 * 1024, MaxDimension: 100, JPEGQuality: 90}
+}
+
+// gradient 每个像素颜色不同的测试图片
+func gradient(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 255 / w), uint8(y * 255 / h), 128, 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("编码 JPEG 失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withExif 在 SOI 之后插入只包含方向标签的 EXIF（APP1）段
+func withExif(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("写入测试图片失败: %v", err)
+	}
+	return path
+}
+
+func decodeFile(t *testing.T, path string) (image.Image, string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取图片失败: %v", err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("解码图片失败: %v", err)
+	}
+	return img, format
+}
+
+func TestNormalizeImageKeepsCompliantImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gradient(40, 30)); err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestFile(t, "ok.png", buf.Bytes())
+
+	got, diag, err := NormalizeImage(path, t.TempDir(), testNormalizeOptions())
+	if err != nil {
+		t.Fatalf("规范化失败: %v", err)
+	}
+	if got != path || len(diag.Actions) != 0 {
+		t.Fatalf("符合要求的图片应直接使用: %s %v", got, diag.Actions)
+	}
+	if diag.Format != "png" || diag.Width != 40 || diag.OutputSize != diag.Size {
+		t.Fatalf("诊断信息错误: %+v", diag)
+	}
+}
+
+func TestNormalizeImageStripsMetadataLosslessly(t *testing.T) {
+	original := encodeJPEG(t, gradient(40, 30))
+	path := writeTestFile(t, "exif.jpg", withExif(original, 1))
+
+	got, diag, err := NormalizeImage(path, t.TempDir(), testNormalizeOptions())
+	if err != nil {
+		t.Fatalf("规范化失败: %v", err)
+	}
+	data, _ := os.ReadFile(got)
+	if !bytes.Equal(data, original) {
+		t.Fatal("只删除元数据时图像数据应保持不变")
+	}
+	if diag.Quality != 0 || len(diag.Actions) != 1 {
+		t.Fatalf("不应重新编码: %+v", diag)
+	}
+}
+
+func TestNormalizeImageAppliesOrientation(t *testing.T) {
+	path := writeTestFile(t, "rotated.jpg", withExif(encodeJPEG(t, gradient(40, 30)), 6))
+
+	got, diag, err := NormalizeImage(path, t.TempDir(), testNormalizeOptions())
+	if err != nil {
+		t.Fatalf("规范化失败: %v", err)
+	}
+	if diag.Orientation != 6 {
+		t.Fatalf("方向 = %d", diag.Orientation)
+	}
+	img, format := decodeFile(t, got)
+	if format != "jpeg" || img.Bounds().Dx() != 30 || img.Bounds().Dy() != 40 {
+		t.Fatalf("旋转后应为 30x40 的 JPEG: %s %v", format, img.Bounds())
+	}
+	data, _ := os.ReadFile(got)
+	if jpegOrientation(data) != 0 {
+		t.Fatal("输出中不应保留 EXIF")
+	}
+}
+
+func TestNormalizeImageResizesAndCompresses(t *testing.T) {
+	// 随机噪声难以压缩，需要降低质量或缩小尺寸才能满足大小上限
+	noise := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	rand.New(rand.NewSource(1)).Read(noise.Pix)
+	for i := 3; i < len(noise.Pix); i += 4 {
+		noise.Pix[i] = 255
+	}
+	path := writeTestFile(t, "noise.jpg", encodeJPEG(t, noise))
+
+	opts := testNormalizeOptions()
+	opts.MaxDimension = 300
+	opts.MaxSize = 8 * 1024
+	got, diag, err := NormalizeImage(path, t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("规范化失败: %v", err)
+	}
+	stat, _ := os.Stat(got)
+	if stat.Size() > opts.MaxSize || diag.OutputSize != stat.Size() {
+		t.Fatalf("输出大小 %d 超过上限 %d", stat.Size(), opts.MaxSize)
+	}
+	if ratio := diag.OutputWidth - 2*diag.OutputHeight; diag.OutputWidth > 300 || ratio < -1 || ratio > 1 {
+		t.Fatalf("应等比缩小到 300 以内: %dx%d", diag.OutputWidth, diag.OutputHeight)
+	}
+	if diag.Quality == 0 || diag.Quality > opts.JPEGQuality {
+		t.Fatalf("质量 = %d", diag.Quality)
+	}
+}
+
+func TestNormalizeImagePadsAspectRatio(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gradient(10, 80)); err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestFile(t, "tall.png", buf.Bytes())
+
+	opts := testNormalizeOptions()
+	opts.MaxAspectRatio = 4
+	_, diag, err := NormalizeImage(path, t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("规范化失败: %v", err)
+	}
+	if diag.OutputFormat != "png" || diag.OutputWidth != 20 || diag.OutputHeight != 80 {
+		t.Fatalf("应补白到 20x80 的 PNG: %+v", diag)
+	}
+}
+
+func TestNormalizeImageResizesBeforePadding(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gradient(20, 400)); err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestFile(t, "long.png", buf.Bytes())
+
+	opts := testNormalizeOptions()
+	opts.MaxAspectRatio = 4
+	_, diag, err := NormalizeImage(path, t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("规范化失败: %v", err)
+	}
+	if diag.OutputWidth != 25 || diag.OutputHeight != 100 {
+		t.Fatalf("应缩小到 5x100 后补白到 25x100: %+v", diag)
+	}
+	if !strings.HasPrefix(diag.Actions[1], "缩小 20x400 -> 5x100") || !strings.Contains(diag.Actions[2], "5x100 -> 25x100") {
+		t.Fatalf("应先缩小再补白: %v", diag.Actions)
+	}
+}
+
+func TestNormalizeImageRejectsHEIC(t *testing.T) {
+	path := writeTestFile(t, "photo.heic", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"))
+
+	if _, _, err := NormalizeImage(path, t.TempDir(), testNormalizeOptions()); err == nil {
+		t.Fatal("HEIC 图片应返回错误")
+	}
+}
+
+func TestNormalizeImageRejectsHugeDimensions(t *testing.T) {
+	// 只有文件头的 GIF，声明 30000x30000 像素
+	header := []byte("GIF89a")
+	header = binary.LittleEndian.AppendUint16(header, 30000)
+	header = binary.LittleEndian.AppendUint16(header, 30000)
+	header = append(header, 0, 0, 0, 0x3B)
+	path := writeTestFile(t, "huge.gif", header)
+
+	opts := testNormalizeOptions()
+	opts.MaxPixels = 50 * 1000 * 1000
+	if _, _, err := NormalizeImage(path, t.TempDir(), opts); err == nil || !bytes.Contains([]byte(err.Error()), []byte("30000x30000")) {
+		t.Fatalf("像素数超过上限的图片应在解码前拒绝: %v", err)
+	}
+}
+
+func TestGIFFrameCount(t *testing.T) {
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.White, color.Black})
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame, frame, frame}, Delay: []int{1, 1, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := gifFrameCount(buf.Bytes()); n != 3 {
+		t.Fatalf("帧数 = %d", n)
+	}
+	if n := gifFrameCount(buf.Bytes()[:20]); n > 1 {
+		t.Fatalf("截断的文件帧数 = %d", n)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 2x3 的图片，左上角为红色
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 3))
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		red         image.Point
+	}{
+		{2, 2, 3, image.Pt(1, 0)},
+		{3, 2, 3, image.Pt(1, 2)},
+		{4, 2, 3, image.Pt(0, 2)},
+		{5, 3, 2, image.Pt(0, 0)},
+		{6, 3, 2, image.Pt(2, 0)},
+		{7, 3, 2, image.Pt(2, 1)},
+		{8, 3, 2, image.Pt(0, 1)},
+	}
+	for _, tt := range tests {
+		out := applyOrientation(img, tt.orientation)
+		if out.Bounds().Dx() != tt.w || out.Bounds().Dy() != tt.h {
+			t.Errorf("方向 %d: 尺寸 %v", tt.orientation, out.Bounds())
+			continue
+		}
+		if r, _, _, _ := out.At(tt.red.X, tt.red.Y).RGBA(); r != 0xffff {
+			t.Errorf("方向 %d: 红色像素应在 %v", tt.orientation, tt.red)
+		}
+	}
+}
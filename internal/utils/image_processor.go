@@ -10,28 +10,33 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
+
+	"sns-poster/internal/config"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
-const downloadDir = "/tmp/xhs-poster"
-
 // ImageProcessor 图片处理器
 type ImageProcessor struct {
 	// 爬虫的URL
 	url string
+	// 下载图片的保存目录
+	downloadDir string
+	// HTTP 客户端，超时时间来自配置
+	client *http.Client
 }
 
 // NewImageProcessor 创建图片处理器
-func NewImageProcessor(url string) *ImageProcessor {
-	if err := os.MkdirAll(downloadDir, 0755); err != nil {
+func NewImageProcessor(cfg *config.ImageConfig, url string) *ImageProcessor {
+	if err := os.MkdirAll(cfg.DownloadDir, 0755); err != nil {
 		logrus.Fatalf("创建目录失败: %v", err)
 	}
 
 	return &ImageProcessor{
-		url: url,
+		url:         url,
+		downloadDir: cfg.DownloadDir,
+		client:      &http.Client{Timeout: cfg.DownloadTimeout},
 	}
 }
 
@@ -40,7 +45,7 @@ func (p *ImageProcessor) ProcessImages(images []string) ([]string, error) {
 	var paths []string
 
 	for _, image := range images {
-		path, err := p.processImage(image)
+		path, err := p.ProcessImage(image)
 		if err != nil {
 			return nil, errors.Wrapf(err, "处理图片失败: %s", image)
 		}
@@ -50,8 +55,8 @@ func (p *ImageProcessor) ProcessImages(images []string) ([]string, error) {
 	return paths, nil
 }
 
-// processImage 处理单个图片
-func (p *ImageProcessor) processImage(image string) (string, error) {
+// ProcessImage 处理单个图片，返回本地路径
+func (p *ImageProcessor) ProcessImage(image string) (string, error) {
 	// 判断是URL还是本地路径
 	if strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://") {
 		return p.downloadImage(image)
@@ -65,7 +70,7 @@ func (p *ImageProcessor) processImage(image string) (string, error) {
 	return image, nil
 }
 
-// downloadImage 下载URL图片到 /tmp/xhs-poster
+// downloadImage 下载URL图片到配置的下载目录
 func (p *ImageProcessor) downloadImage(url string) (string, error) {
 	imageURL := url
 
@@ -99,8 +104,7 @@ func (p *ImageProcessor) downloadImage(url string) (string, error) {
 	}
 
 	// 下载
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return "", errors.Wrap(err, "下载失败")
 	}
@@ -122,7 +126,7 @@ func (p *ImageProcessor) downloadImage(url string) (string, error) {
 
 	ext := p.getExtension(contentType)
 	filename := fmt.Sprintf("img_%x.%s", hash, ext)
-	filePath := filepath.Join(downloadDir, filename)
+	filePath := filepath.Join(p.downloadDir, filename)
 
 	if err := os.WriteFile(filePath, data, 0644); err != nil {
 		return "", errors.Wrap(err, "写入文件失败")
@@ -169,8 +173,7 @@ func (p *ImageProcessor) signBandaiHobbyImage(imageURL string) (string, error) {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return "", errors.Wrap(err, "请求签名服务失败")
 	}
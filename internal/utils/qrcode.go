@@ -2,17 +2,14 @@ package utils
 
 import (
 	"bytes"
-	"crypto/md5"
 	"encoding/base64"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"image"
-	"io"
-	"net/http"
 	"os"
 	"strings"
 
+	"sns-poster/internal/notify"
+
 	"github.com/sirupsen/logrus"
 )
 
@@ -52,20 +49,6 @@ func (q *QRCodeDisplay) DisplayQRCode(dataURL string, accountID string) error {
 
 	logrus.Infof("二维码数据大小: %d bytes", len(imageData))
 
-	textPayload := map[string]any{
-		"msgtype": "text",
-		"text": map[string]string{
-			"content": fmt.Sprintf("小红书登录二维码 for accountID: %s", accountID),
-		},
-	}
-
-	jsonData, err := json.Marshal(textPayload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %v", err)
-	}
-	http.Post("http://localhost:6181/api/v1/alert-wecom",
-		"application/json", bytes.NewBuffer(jsonData))
-
 	// 在日志中显示二维码图像信息
 	q.printQRCodeImageInLog(dataURL)
 
@@ -75,11 +58,15 @@ func (q *QRCodeDisplay) DisplayQRCode(dataURL string, accountID string) error {
 		logrus.Warnf("无法显示原始二维码ASCII版本: %v", err)
 	}
 
-	// 发送二维码到企业微信
-	err = q.sendQRCodeToWecom(imageData, accountID)
-	if err != nil {
-		logrus.Warnf("无法发送二维码到企业微信: %v", err)
-	}
+	// 通过配置的通知渠道发送二维码
+	notify.Notify(&notify.Message{
+		Event:     notify.EventQRCodeNeeded,
+		AccountID: accountID,
+		Title:     "小红书登录二维码",
+		Text:      fmt.Sprintf("账号 %s 需要扫码登录，请使用小红书APP扫描二维码", accountID),
+		Image:     imageData,
+		ImageType: strings.TrimPrefix(strings.Split(parts[0], ";")[0], "data:"),
+	})
 
 	// 同时显示一个备用的提示QR码
 	q.displayBackupQRCodeWithQRTerminal()
@@ -180,43 +167,6 @@ func (q *QRCodeDisplay) printQRCodeASCII(imageData []byte) error {
 	return nil
 }
 
-func (q *QRCodeDisplay) sendQRCodeToWecom(imageData []byte, accountID string) error {
-	// 注：图片（base64编码前）最大不能超过2M，支持JPG,PNG格式
-	logrus.Infof("发送二维码到企业微信")
-	// 分离base64数据
-	base64Data := base64.StdEncoding.EncodeToString(imageData)
-	// 获得文件的byte后进行md5计算
-	md5Data := md5.Sum(imageData)
-	md5String := hex.EncodeToString(md5Data[:])
-
-	payload := map[string]any{
-		"msgtype": "image",
-		"image": map[string]string{
-			"base64": base64Data,
-			"md5":    md5String,
-		},
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %v", err)
-	}
-	resp, err := http.Post("http://localhost:6181/api/v1/alert-wecom", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to send QR code to Wecom: %v", err)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
-	}
-	logrus.Infof("发送二维码到企业微信响应: %s", string(body))
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to send QR code to Wecom: %v", resp.StatusCode)
-	}
-	return nil
-}
-
 // displayBackupQRCodeWithQRTerminal 显示备用提示信息
 func (q *QRCodeDisplay) displayBackupQRCodeWithQRTerminal() {
 	logrus.Info("📱 主要方式: 扫描上方ASCII格式的小红书二维码")
@@ -0,0 +1,195 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// 签名相关的请求头
+const (
+	HeaderSource    = "X-Webhook-Source"    // 来源名称，用于查找密钥
+	HeaderTimestamp = "X-Webhook-Timestamp" // 签名时间（Unix 秒）
+	HeaderNonce     = "X-Webhook-Nonce"     // 每个请求唯一的随机串
+	HeaderSignature = "X-Webhook-Signature" // sha256=<hex(HMAC-SHA256(secret, timestamp.nonce.body))>
+)
+
+const (
+	// signaturePrefix 签名头的格式前缀
+	signaturePrefix = "sha256="
+	// DefaultTolerance 允许的签名时间与服务器时间的最大偏差
+	DefaultTolerance = 5 * time.Minute
+	// minSecretLength 密钥最小长度
+	minSecretLength = 32
+	// maxNonceLength nonce 最大长度
+	maxNonceLength = 128
+)
+
+var (
+	// ErrInvalidSignature 签名缺失、来源未知或签名不匹配
+	ErrInvalidSignature = errors.New("webhook 签名无效")
+	// ErrExpired 签名时间超出允许范围
+	ErrExpired = errors.New("webhook 签名已过期")
+	// ErrReplayed nonce 已使用过
+	ErrReplayed = errors.New("webhook 请求重复")
+)
+
+// Source webhook 来源配置
+type Source struct {
+	Name     string   `json:"name"`
+	Secret   string   `json:"secret"`   // HMAC 密钥，至少 32 个字符
+	Accounts []string `json:"accounts"` // 允许发布的账号ID，"*" 表示所有账号
+	Disabled bool     `json:"disabled,omitempty"`
+}
+
+// NonceStore 记录已使用的 nonce，用于防重放
+type NonceStore interface {
+	// Remember 记录 nonce，ttl 后过期；nonce 已存在时返回 false
+	Remember(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// Verifier 校验 webhook 请求签名
+type Verifier struct {
+	sources   map[string]*Source
+	nonces    NonceStore
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+// NewVerifier 创建签名校验器，校验每个来源的配置
+func NewVerifier(sources []*Source, nonces NonceStore) (*Verifier, error) {
+	v := &Verifier{
+		sources:   make(map[string]*Source),
+		nonces:    nonces,
+		tolerance: DefaultTolerance,
+		now:       time.Now,
+	}
+	for _, source := range sources {
+		if source.Name == "" {
+			return nil, errors.New("webhook 来源名称不能为空")
+		}
+		if len(source.Secret) < minSecretLength {
+			return nil, errors.Errorf("webhook 来源 %s 的密钥至少需要 %d 个字符", source.Name, minSecretLength)
+		}
+		if len(source.Accounts) == 0 {
+			return nil, errors.Errorf("webhook 来源 %s 未配置允许的账号", source.Name)
+		}
+		if _, ok := v.sources[source.Name]; ok {
+			return nil, errors.Errorf("webhook 来源 %s 重复", source.Name)
+		}
+		v.sources[source.Name] = source
+	}
+	return v, nil
+}
+
+// LoadFile 从 JSON 文件加载 webhook 来源，文件不存在时没有任何来源
+func LoadFile(path string, nonces NonceStore) (*Verifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NewVerifier(nil, nonces)
+		}
+		return nil, errors.Wrap(err, "读取 webhook 配置失败")
+	}
+
+	var sources []*Source
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, errors.Wrap(err, "解析 webhook 配置失败")
+	}
+
+	v, err := NewVerifier(sources, nonces)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("已加载 webhook 来源: %s (%d 个)", path, len(sources))
+	return v, nil
+}
+
+// Len 来源数量
+func (v *Verifier) Len() int {
+	return len(v.sources)
+}
+
+// Verify 校验请求头中的签名和时间戳，并记录 nonce 防止重放，返回请求的来源
+func (v *Verifier) Verify(ctx context.Context, header http.Header, body []byte) (*Source, error) {
+	source, ok := v.sources[header.Get(HeaderSource)]
+	if !ok || source.Disabled {
+		return nil, errors.Wrap(ErrInvalidSignature, "未知的来源")
+	}
+
+	timestamp := header.Get(HeaderTimestamp)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidSignature, "时间戳格式错误")
+	}
+	if skew := v.now().Sub(time.Unix(ts, 0)); skew > v.tolerance || skew < -v.tolerance {
+		return nil, errors.Wrapf(ErrExpired, "签名时间与服务器时间相差 %s", skew.Round(time.Second))
+	}
+
+	nonce := header.Get(HeaderNonce)
+	if nonce == "" || len(nonce) > maxNonceLength {
+		return nil, errors.Wrap(ErrInvalidSignature, "nonce 缺失或过长")
+	}
+
+	signature := header.Get(HeaderSignature)
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return nil, errors.Wrap(ErrInvalidSignature, "签名格式错误")
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil || !hmac.Equal(got, sign(source.Secret, timestamp, nonce, body)) {
+		return nil, ErrInvalidSignature
+	}
+
+	// 签名通过后才记录 nonce，避免伪造请求占用 nonce；过期时间覆盖整个时间窗口
+	fresh, err := v.nonces.Remember(ctx, source.Name+":"+nonce, 2*v.tolerance)
+	if err != nil {
+		return nil, errors.Wrap(err, "记录 webhook nonce 失败")
+	}
+	if !fresh {
+		return nil, ErrReplayed
+	}
+	return source, nil
+}
+
+// Sign 计算签名头的值，供上游系统和测试使用
+func Sign(secret, timestamp, nonce string, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(sign(secret, timestamp, nonce, body))
+}
+
+// sign HMAC-SHA256(secret, timestamp + "." + nonce + "." + body)
+func sign(secret, timestamp, nonce string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// RedisNonceStore 使用 Redis SETNX 记录 nonce，多个实例共享
+type RedisNonceStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisNonceStore 创建 Redis nonce 存储，prefix 为 key 前缀
+func NewRedisNonceStore(client *redis.Client, prefix string) *RedisNonceStore {
+	return &RedisNonceStore{client: client, prefix: prefix}
+}
+
+// Remember 记录 nonce，已存在时返回 false
+func (s *RedisNonceStore) Remember(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, s.prefix+":"+key, 1, ttl).Result()
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryNonceStore 测试用的内存 nonce 存储
+type memoryNonceStore struct {
+	seen map[string]bool
+	mu   sync.Mutex
+}
+
+func (m *memoryNonceStore) Remember(_ context.Context, key string, _ time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.seen[key] {
+		return false, nil
+	}
+	m.seen[key] = true
+	return true, nil
+}
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func signedHeader(secret string, ts time.Time, nonce string, body []byte) http.Header {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	h := http.Header{}
+	h.Set(HeaderSource, "crawler")
+	h.Set(HeaderTimestamp, timestamp)
+	h.Set(HeaderNonce, nonce)
+	h.Set(HeaderSignature, Sign(secret, timestamp, nonce, body))
+	return h
+}
+
+func TestVerifierVerify(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	v, err := NewVerifier([]*Source{{Name: "crawler", Secret: testSecret, Accounts: []string{"*"}}},
+		&memoryNonceStore{seen: make(map[string]bool)})
+	require.NoError(t, err)
+	v.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	body := []byte(`{"title":"t"}`)
+
+	source, err := v.Verify(ctx, signedHeader(testSecret, now, "n1", body), body)
+	require.NoError(t, err)
+	assert.Equal(t, "crawler", source.Name)
+
+	// 同一 nonce 重放
+	_, err = v.Verify(ctx, signedHeader(testSecret, now, "n1", body), body)
+	assert.ErrorIs(t, err, ErrReplayed)
+
+	// body 被篡改
+	_, err = v.Verify(ctx, signedHeader(testSecret, now, "n2", body), []byte(`{"title":"x"}`))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// 错误的密钥
+	_, err = v.Verify(ctx, signedHeader("ffffffffffffffffffffffffffffffff", now, "n3", body), body)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// 签名时间超出范围
+	_, err = v.Verify(ctx, signedHeader(testSecret, now.Add(-10*time.Minute), "n4", body), body)
+	assert.ErrorIs(t, err, ErrExpired)
+
+	// 未知来源
+	h := signedHeader(testSecret, now, "n5", body)
+	h.Set(HeaderSource, "unknown")
+	_, err = v.Verify(ctx, h, body)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// 签名失败的请求不占用 nonce
+	_, err = v.Verify(ctx, signedHeader(testSecret, now, "n2", body), body)
+	assert.NoError(t, err)
+}
+
+func TestNewVerifierValidate(t *testing.T) {
+	_, err := NewVerifier([]*Source{{Name: "short", Secret: "secret", Accounts: []string{"*"}}}, nil)
+	assert.Error(t, err, "过短的密钥应被拒绝")
+
+	_, err = NewVerifier([]*Source{{Name: "no-accounts", Secret: testSecret}}, nil)
+	assert.Error(t, err, "未配置账号应被拒绝")
+}
@@ -0,0 +1,273 @@
+package xhs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sns-poster/internal/account"
+	"sns-poster/internal/config"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ErrCodeCaptchaRequired 遇到验证码挑战时返回给调用方的错误码
+	ErrCodeCaptchaRequired = "CAPTCHA_REQUIRED"
+	// ErrCodeAccountMismatch 登录的小红书用户与账号登记的用户不一致时的错误码
+	ErrCodeAccountMismatch = "ACCOUNT_MISMATCH"
+)
+
+// challengeScreenshotDir 验证码截图保存目录
+const challengeScreenshotDir = "./debug"
+
+// ChallengeError 导航过程中遇到滑块/图片验证码，需要人工处理
+type ChallengeError struct {
+	Kind         string `json:"kind"`                     // 挑战类型: slider, image, verify
+	URL          string `json:"url"`                      // 出现挑战的页面
+	Screenshot   string `json:"screenshot,omitempty"`     // 截图路径
+	RemoteViewID string `json:"remote_view_id,omitempty"` // 远程查看会话ID（开启远程处理时）
+}
+
+func (e *ChallengeError) Error() string {
+	return fmt.Sprintf("检测到验证码挑战(%s)，需要人工处理: %s", e.Kind, e.URL)
+}
+
+// challengeDetector 已知的验证码浮层特征
+type challengeDetector struct {
+	kind      string
+	selectors []string
+}
+
+// challengeURLKeywords 验证码跳转页的URL特征
+var challengeURLKeywords = []string{
+	"website-login/captcha",
+	"/captcha",
+	"verifyType=",
+}
+
+// challengeDetectors 按类型列出的验证码浮层选择器
+var challengeDetectors = []challengeDetector{
+	{
+		kind: "slider",
+		selectors: []string{
+			".red-captcha-slider",
+			"#red-captcha-slider",
+			".geetest_slider",
+			"div[class*='captcha-slider']",
+		},
+	},
+	{
+		kind: "image",
+		selectors: []string{
+			"#red-captcha",
+			".red-captcha",
+			".geetest_panel",
+			"div[class*='captcha-img']",
+		},
+	},
+	{
+		kind: "verify",
+		selectors: []string{
+			"iframe[src*='captcha']",
+			"#captcha-div",
+			".verify-container",
+		},
+	},
+}
+
+// detectChallenge 检查当前页面是否出现验证码挑战，出现时保存截图并返回 *ChallengeError
+// 检测本身出错时不阻塞流程，返回 nil
+func detectChallenge(page *rod.Page) *ChallengeError {
+	info, err := page.Info()
+	if err != nil {
+		logrus.Debugf("验证码检测获取页面信息失败: %v", err)
+		return nil
+	}
+
+	kind := ""
+	for _, detector := range challengeDetectors {
+		if hasVisibleElement(page, detector.selectors) {
+			kind = detector.kind
+			break
+		}
+	}
+
+	// 整页跳转到验证页面（没有匹配到具体浮层）
+	if kind == "" {
+		for _, keyword := range challengeURLKeywords {
+			if strings.Contains(info.URL, keyword) {
+				kind = "verify"
+				break
+			}
+		}
+	}
+
+	if kind == "" {
+		return nil
+	}
+
+	challenge := &ChallengeError{Kind: kind, URL: info.URL}
+	if path, err := saveChallengeScreenshot(page, kind); err != nil {
+		logrus.Warnf("保存验证码截图失败: %v", err)
+	} else {
+		challenge.Screenshot = path
+	}
+
+	logrus.Warnf("检测到验证码挑战: 类型=%s, URL=%s, 截图=%s", kind, info.URL, challenge.Screenshot)
+	return challenge
+}
+
+// hasVisibleElement 任一选择器匹配到可见元素时返回 true
+func hasVisibleElement(page *rod.Page, selectors []string) bool {
+	for _, selector := range selectors {
+		exists, elem, err := page.Has(selector)
+		if err != nil || !exists || elem == nil {
+			continue
+		}
+		if visible, _ := elem.Visible(); visible {
+			return true
+		}
+	}
+	return false
+}
+
+// checkChallenge 导航后调用，出现验证码时返回 error，否则返回 nil
+func checkChallenge(page *rod.Page) error {
+	if challenge := detectChallenge(page); challenge != nil {
+		return challenge
+	}
+	return nil
+}
+
+// saveChallengeScreenshot 保存验证码页面截图，返回文件路径
+func saveChallengeScreenshot(page *rod.Page, kind string) (string, error) {
+	if err := os.MkdirAll(challengeScreenshotDir, 0755); err != nil {
+		return "", err
+	}
+
+	screenshot, err := page.Screenshot(false, &proto.PageCaptureScreenshot{})
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(challengeScreenshotDir, fmt.Sprintf("captcha_%s_%d.png", kind, time.Now().Unix()))
+	if err := os.WriteFile(filename, screenshot, 0644); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// isChallenge 判断错误链中是否包含验证码挑战
+func isChallenge(err error) bool {
+	return asChallenge(err) != nil
+}
+
+// asChallenge 从错误链中取出验证码挑战，不存在返回 nil
+func asChallenge(err error) *ChallengeError {
+	var challenge *ChallengeError
+	if errors.As(err, &challenge) {
+		return challenge
+	}
+	return nil
+}
+
+// handleIntervention 处理浏览器流程返回的需要人工介入的错误：
+// 遇到验证码时标记账号待处理，开启远程处理时接管页面（不再由调用方关闭）；
+// 登录用户与账号不一致时标记账号待处理。返回原错误
+func (s *Service) handleIntervention(accountID string, page *rod.Page, err error) error {
+	var mismatch *AccountMismatchError
+	if errors.As(err, &mismatch) {
+		s.markAttention(accountID, ErrCodeAccountMismatch, mismatch.Error())
+		return err
+	}
+
+	challenge := asChallenge(err)
+	if challenge == nil {
+		return err
+	}
+
+	if config.GetConfig().XHS.CaptchaRemoteView {
+		session := s.openRemoteView(accountID, page, challenge)
+		challenge.RemoteViewID = session.ID
+	}
+
+	s.markChallenge(accountID, challenge)
+	return err
+}
+
+// needsIntervention 错误是否需要人工介入（验证码或登录用户不一致）
+func needsIntervention(err error) bool {
+	return isChallenge(err) || isAccountMismatch(err)
+}
+
+// markAttention 标记账号需要人工处理
+func (s *Service) markAttention(accountID, code, reason string) {
+	err := s.accounts.SetAttention(accountID, &account.Attention{
+		Code:   code,
+		Reason: reason,
+		Since:  time.Now(),
+	})
+	if err != nil {
+		logrus.Warnf("标记账号 %s 待处理失败: %v", accountID, err)
+		return
+	}
+	logrus.Warnf("账号 %s 已标记为需要人工处理: %s", accountID, reason)
+}
+
+// markChallenge 标记账号遇到验证码，需要人工处理
+func (s *Service) markChallenge(accountID string, challenge *ChallengeError) {
+	err := s.accounts.SetAttention(accountID, &account.Attention{
+		Code:         ErrCodeCaptchaRequired,
+		Reason:       challenge.Error(),
+		Screenshot:   challenge.Screenshot,
+		RemoteViewID: challenge.RemoteViewID,
+		Since:        time.Now(),
+	})
+	if err != nil {
+		logrus.Warnf("标记账号 %s 待处理失败: %v", accountID, err)
+		return
+	}
+	logrus.Warnf("账号 %s 已标记为需要人工处理: %s", accountID, challenge.Error())
+}
+
+// ClearAttention 清除账号的待处理标记
+func (s *Service) ClearAttention(accountID string) {
+	a, err := s.accounts.Get(accountID)
+	if err != nil || a.Attention == nil {
+		return
+	}
+	if err := s.accounts.SetAttention(accountID, nil); err != nil {
+		logrus.Warnf("清除账号 %s 待处理标记失败: %v", accountID, err)
+		return
+	}
+	logrus.Infof("账号 %s 的待处理标记已清除", accountID)
+}
+
+// AccountsNeedingAttention 返回所有需要人工处理的账号
+func (s *Service) AccountsNeedingAttention() []*account.Account {
+	var list []*account.Account
+	for _, a := range s.accounts.List() {
+		if a.Attention != nil {
+			list = append(list, a)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Attention.Since.Before(list[j].Attention.Since)
+	})
+	return list
+}
+
+// releasePage 关闭页面，已被远程处理会话接管的页面除外
+func (s *Service) releasePage(page *rod.Page) {
+	if s.holdsPage(page) {
+		return
+	}
+	page.Close()
+}
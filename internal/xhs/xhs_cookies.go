@@ -0,0 +1,137 @@
+package xhs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"sns-poster/internal/utils"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// xhsCookieDomain 小红书 cookie 所属域名
+const xhsCookieDomain = "xiaohongshu.com"
+
+var (
+	// ErrNoCookies 账号没有保存的 cookies
+	ErrNoCookies = errors.New("账号没有保存的 cookies")
+	// ErrInvalidCookies 导入的 cookies 不可用
+	ErrInvalidCookies = errors.New("导入的 cookies 不可用")
+)
+
+// CookieImportResult 导入 cookies 的结果
+type CookieImportResult struct {
+	AccountID string `json:"account_id"`
+	UserID    string `json:"user_id"`  // 导入的 session 对应的小红书用户ID
+	Imported  int    `json:"imported"` // 导入的 cookie 数量
+	// 被忽略的 cookie（非小红书域名或已过期），格式为 "name@domain: 原因"
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// filterImportCookies 只保留小红书域名下未过期的 cookies，返回保留的和被忽略的说明
+func filterImportCookies(cookies []*proto.NetworkCookie, now time.Time) ([]*proto.NetworkCookie, []string) {
+	var (
+		kept    []*proto.NetworkCookie
+		skipped []string
+	)
+	for _, cookie := range cookies {
+		label := fmt.Sprintf("%s@%s", cookie.Name, cookie.Domain)
+
+		domain := strings.TrimPrefix(strings.ToLower(cookie.Domain), ".")
+		switch {
+		case cookie.Name == "":
+			skipped = append(skipped, label+": 缺少名称")
+		case domain != xhsCookieDomain && !strings.HasSuffix(domain, "."+xhsCookieDomain):
+			skipped = append(skipped, label+": 非小红书域名")
+		case !cookie.Session && cookie.Expires > 0 && cookie.Expires.Time().Before(now):
+			skipped = append(skipped, label+": 已过期")
+		default:
+			if cookie.Path == "" {
+				cookie.Path = "/"
+			}
+			kept = append(kept, cookie)
+		}
+	}
+	return kept, skipped
+}
+
+// ImportCookies 导入账号的 cookies：过滤域名和过期时间后在临时浏览器 context 中检查登录状态
+// （登记了小红书用户ID时同时校验用户），通过后才保存并替换账号 context 中的 cookies，
+// 失败时账号原有的 cookies 和正在进行的操作不受影响。遇到验证码或用户不一致时标记账号需要人工处理
+func (s *Service) ImportCookies(ctx context.Context, accountID string, cookies []*proto.NetworkCookie) (*CookieImportResult, error) {
+	kept, skipped := filterImportCookies(cookies, time.Now())
+	if len(kept) == 0 {
+		return nil, errors.Wrap(ErrInvalidCookies, "没有未过期的小红书 cookie")
+	}
+
+	proxy := ""
+	if a, err := s.accounts.Get(accountID); err == nil {
+		proxy = a.Proxy
+	}
+	page, dispose, err := s.getBrowser().NewTemporaryPage(proxy)
+	if err != nil {
+		return nil, err
+	}
+	defer dispose()
+
+	if err := utils.ApplyCookies(page, kept); err != nil {
+		return nil, errors.Wrap(err, "设置 cookies 失败")
+	}
+
+	loginAction := NewLogin(page).ExpectUserID(s.expectedUserID(accountID))
+	userID, err := loginAction.CheckLoginStatus(ctx)
+	if err != nil {
+		var mismatch *AccountMismatchError
+		switch {
+		case errors.Is(err, ErrNotLoggedIn):
+			return nil, errors.Wrap(ErrInvalidCookies, "导入的 cookies 未登录")
+		case errors.As(err, &mismatch):
+			s.markAttention(accountID, ErrCodeAccountMismatch, mismatch.Error())
+		case isChallenge(err):
+			// 临时 context 在返回时释放，不打开远程处理会话
+			s.markChallenge(accountID, asChallenge(err))
+		}
+		return nil, err
+	}
+
+	// 保存浏览器中的 cookies（包含访问页面后刷新的值）
+	cm := utils.NewCookieManagerForAccount(accountID)
+	if err := cm.SaveCookies(page); err != nil {
+		return nil, err
+	}
+	s.touchLogin(accountID)
+
+	// 用新保存的 cookies 替换账号 context 中原有的 cookies
+	accountPage := s.newPage(accountID)
+	if err := cm.ClearCookies(accountPage); err != nil {
+		logrus.Warnf("清空账号 %s 浏览器 cookies 失败: %v", accountID, err)
+	} else if err := cm.SetCookies(accountPage); err != nil {
+		logrus.Warnf("设置账号 %s 导入的 cookies 失败: %v", accountID, err)
+	}
+	s.releasePage(accountPage)
+
+	logrus.Infof("账号 %s 导入 cookies 成功，小红书用户: %s，导入 %d 个，忽略 %d 个",
+		accountID, userID, len(kept), len(skipped))
+	return &CookieImportResult{
+		AccountID: accountID,
+		UserID:    userID,
+		Imported:  len(kept),
+		Skipped:   skipped,
+	}, nil
+}
+
+// ExportCookies 导出账号保存的 cookies
+func (s *Service) ExportCookies(accountID string) ([]*proto.NetworkCookie, error) {
+	cookies, err := utils.NewCookieManagerForAccount(accountID).LoadCookies()
+	if err != nil {
+		return nil, err
+	}
+	if len(cookies) == 0 {
+		return nil, errors.Wrapf(ErrNoCookies, "账号 %s", accountID)
+	}
+	return cookies, nil
+}
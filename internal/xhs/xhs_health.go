@@ -0,0 +1,226 @@
+package xhs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"sns-poster/internal/notify"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// 账号登录健康状态
+const (
+	HealthStatusUnknown         = "unknown"
+	HealthStatusLoggedIn        = "logged_in"
+	HealthStatusLoggedOut       = "logged_out"
+	HealthStatusCaptchaRequired = "captcha_required"
+	HealthStatusAccountMismatch = "account_mismatch"
+	HealthStatusError           = "error"
+)
+
+const (
+	// 单个账号检查超时时间
+	healthCheckTimeout = 2 * time.Minute
+	// 两次账号检查之间的最小间隔，避免账号少时检查过于密集
+	healthCheckMinSpacing = 30 * time.Second
+)
+
+// AccountHealth 账号登录健康检查结果
+type AccountHealth struct {
+	AccountID string     `json:"account_id"`
+	Status    string     `json:"status"`
+	UserID    string     `json:"user_id,omitempty"` // 检测到的小红书用户ID
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	// 最近一次状态变化的时间
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+}
+
+// HealthMonitor 后台定期检查所有已注册账号的登录状态
+// 每轮检查均匀分散在整个周期内，避免集中打开大量页面
+type HealthMonitor struct {
+	service *Service
+
+	results map[string]*AccountHealth
+	mu      sync.RWMutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newHealthMonitor 创建登录健康监控
+func newHealthMonitor(service *Service) *HealthMonitor {
+	return &HealthMonitor{
+		service: service,
+		results: make(map[string]*AccountHealth),
+	}
+}
+
+// Start 启动后台检查，interval 为每轮检查的周期，<=0 表示不启用
+func (m *HealthMonitor) Start(interval time.Duration) {
+	if interval <= 0 {
+		logrus.Info("账号登录健康检查未启用")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+
+	logrus.Infof("启动账号登录健康检查，周期: %s", interval)
+
+	go func() {
+		defer close(m.done)
+		for {
+			m.runRound(ctx, interval)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查，等待正在进行的检查结束
+func (m *HealthMonitor) Stop() {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	<-m.done
+	logrus.Info("账号登录健康检查已停止")
+}
+
+// Results 返回所有已注册账号的最近检查结果，未检查过的账号状态为 unknown
+func (m *HealthMonitor) Results() []*AccountHealth {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	accounts := m.service.accounts.List()
+	list := make([]*AccountHealth, 0, len(accounts))
+	for _, a := range accounts {
+		if result, ok := m.results[a.ID]; ok {
+			copied := *result
+			list = append(list, &copied)
+			continue
+		}
+		list = append(list, &AccountHealth{AccountID: a.ID, Status: HealthStatusUnknown})
+	}
+	return list
+}
+
+// runRound 检查一轮所有启用的账号，检查之间等待 interval/账号数
+func (m *HealthMonitor) runRound(ctx context.Context, interval time.Duration) {
+	var ids []string
+	for _, a := range m.service.accounts.List() {
+		if a.Enabled {
+			ids = append(ids, a.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		sleepContext(ctx, interval)
+		return
+	}
+
+	spacing := interval / time.Duration(len(ids))
+	if spacing < healthCheckMinSpacing {
+		spacing = healthCheckMinSpacing
+	}
+
+	for _, id := range ids {
+		// 先等待再检查：服务刚启动时不立即打开页面
+		if !sleepContext(ctx, spacing) {
+			return
+		}
+		m.checkAccount(ctx, id)
+	}
+}
+
+// checkAccount 检查单个账号并记录结果
+func (m *HealthMonitor) checkAccount(ctx context.Context, accountID string) {
+	checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	result := &AccountHealth{AccountID: accountID}
+	status, err := m.checkLoginStatus(checkCtx, accountID)
+	switch {
+	case err == nil && status.IsLoggedIn:
+		result.Status = HealthStatusLoggedIn
+		result.UserID = status.UserId
+	case err == nil, errors.Is(err, ErrNotLoggedIn):
+		result.Status = HealthStatusLoggedOut
+	case isChallenge(err):
+		result.Status = HealthStatusCaptchaRequired
+		result.Error = err.Error()
+	case isAccountMismatch(err):
+		result.Status = HealthStatusAccountMismatch
+		result.Error = err.Error()
+	default:
+		result.Status = HealthStatusError
+		result.Error = err.Error()
+	}
+
+	now := time.Now()
+	result.CheckedAt = &now
+
+	m.mu.Lock()
+	previous := m.results[accountID]
+	if previous != nil && previous.Status == result.Status {
+		result.ChangedAt = previous.ChangedAt
+	} else {
+		result.ChangedAt = &now
+	}
+	m.results[accountID] = result
+	m.mu.Unlock()
+
+	logrus.Infof("[HealthMonitor] 账号 %s 登录状态: %s", accountID, result.Status)
+
+	// 账号从其他状态变为未登录时告警
+	if result.Status == HealthStatusLoggedOut && (previous == nil || previous.Status != HealthStatusLoggedOut) {
+		m.alertLoggedOut(accountID)
+	}
+}
+
+// checkLoginStatus 检查登录状态，浏览器异常（panic）时转为错误，不影响后续账号
+func (m *HealthMonitor) checkLoginStatus(ctx context.Context, accountID string) (status *LoginStatusResponse, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("检查登录状态异常: %v", r)
+		}
+	}()
+	return m.service.CheckLoginStatus(ctx, accountID)
+}
+
+// alertLoggedOut 发送账号掉线告警
+func (m *HealthMonitor) alertLoggedOut(accountID string) {
+	name := accountID
+	if a, err := m.service.accounts.Get(accountID); err == nil && a.DisplayName != "" {
+		name = fmt.Sprintf("%s (%s)", a.DisplayName, accountID)
+	}
+
+	content := fmt.Sprintf("小红书账号已掉线，请重新登录: %s", name)
+	logrus.Warn(content)
+	notify.Notify(&notify.Message{
+		Event:     notify.EventAccountLoggedOut,
+		AccountID: accountID,
+		Title:     "小红书账号已掉线",
+		Text:      content,
+	})
+}
+
+// sleepContext 等待 d，ctx 取消时提前返回 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,42 @@
+package xhs
+
+import (
+	"unicode/utf8"
+
+	"github.com/clipperhouse/uax29/v2/graphemes"
+)
+
+// xhsLength 按小红书编辑器的计数规则计算文本长度（标题、正文的字数限制都按此计算）：
+//   - 按字素簇计数，emoji 组合序列（👨‍👩‍👧‍👦、国旗、肤色）整体计 2
+//   - ASCII 和 Latin-1 字符计 1
+//   - 中文、全角标点、…、■ 等其他字符计 2
+//   - 换行计 2（\r\n 合计 2）
+func xhsLength(s string) int {
+	length := 0
+	iter := graphemes.FromString(s)
+	for iter.Next() {
+		length += graphemeLength(iter.Value())
+	}
+	return length
+}
+
+// graphemeLength 单个字素簇的长度
+func graphemeLength(g string) int {
+	r, size := utf8.DecodeRuneInString(g)
+	if size == len(g) && r <= 0xFF && r != '\n' {
+		return 1
+	}
+	return 2
+}
+
+// tagsLength 标签计入正文的长度。发布时在正文后换两行，每个标签输入为 "#标签 "（见 Publisher.inputTags）
+func tagsLength(tags []string) int {
+	if len(tags) == 0 {
+		return 0
+	}
+	length := xhsLength("\n\n")
+	for _, tag := range tags {
+		length += xhsLength("#" + tag + " ")
+	}
+	return length
+}
@@ -0,0 +1,127 @@
+package xhs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 按小红书编辑器规则计数：ASCII 1，中文、全角符号、emoji 2，换行 2
+func TestXHSLength(t *testing.T) {
+	tests := []struct {
+		name           string
+		text           string
+		expectedLength int
+		description    string
+	}{
+		{
+			name:           "Empty string",
+			text:           "",
+			expectedLength: 0,
+			description:    "Empty string has length 0",
+		},
+		{
+			name:           "Pure English",
+			text:           "Hello World",
+			expectedLength: 11,
+			description:    "ASCII characters count as 1 each",
+		},
+		{
+			name:           "Pure Chinese",
+			text:           "你好世界",
+			expectedLength: 8,
+			description:    "Chinese characters count as 2 each",
+		},
+		{
+			name:           "Mixed Chinese and English",
+			text:           "Hello世界",
+			expectedLength: 9,
+			description:    "Hello(5) + 世界(4)",
+		},
+		{
+			name:           "Full-width punctuation",
+			text:           "你好，世界！",
+			expectedLength: 12,
+			description:    "Full-width punctuation counts as 2",
+		},
+		{
+			name:           "Latin-1 accented letter",
+			text:           "café",
+			expectedLength: 4,
+			description:    "Precomposed é is a Latin-1 character and counts as 1",
+		},
+		{
+			name:           "Combining accent",
+			text:           "café",
+			expectedLength: 5,
+			description:    "e + combining acute is one grapheme outside Latin-1: caf(3) + é(2)",
+		},
+		{
+			name:           "Symbols outside Latin-1",
+			text:           "■…",
+			expectedLength: 4,
+			description:    "■ and … count as 2 although they are narrow in a terminal",
+		},
+		{
+			name:           "Single emoji",
+			text:           "😀",
+			expectedLength: 2,
+			description:    "Emoji counts as 2",
+		},
+		{
+			name:           "Emoji with skin tone",
+			text:           "👍🏻",
+			expectedLength: 2,
+			description:    "Emoji + skin tone modifier is one grapheme",
+		},
+		{
+			name:           "ZWJ sequence",
+			text:           "👨‍👩‍👧‍👦",
+			expectedLength: 2,
+			description:    "Family ZWJ sequence is one grapheme",
+		},
+		{
+			name:           "Flag",
+			text:           "🇯🇵",
+			expectedLength: 2,
+			description:    "Regional indicator pair is one grapheme",
+		},
+		{
+			name:           "Keycap",
+			text:           "1️⃣",
+			expectedLength: 2,
+			description:    "Digit + VS16 + keycap is one grapheme",
+		},
+		{
+			name:           "Newline",
+			text:           "■发售日期：\n",
+			expectedLength: 14,
+			description:    "■(2) + 发售日期(8) + ：(2) + \\n(2)",
+		},
+		{
+			name:           "CRLF",
+			text:           "a\r\nb",
+			expectedLength: 4,
+			description:    "\\r\\n is one grapheme and counts as a single newline",
+		},
+		{
+			name:           "Tab and space",
+			text:           "a\tb c",
+			expectedLength: 5,
+			description:    "Tab and space count as 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedLength, xhsLength(tt.text),
+				"Length mismatch for '%s': %s", tt.text, tt.description)
+		})
+	}
+}
+
+func TestTagsLength(t *testing.T) {
+	assert.Equal(t, 0, tagsLength(nil))
+	// \n\n(4) + "#手办 "(6) + "#figure "(8)
+	assert.Equal(t, 18, tagsLength([]string{"手办", "figure"}))
+}
@@ -3,6 +3,7 @@ package xhs
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
 	"os"
 	"strings"
 	"time"
@@ -18,6 +19,8 @@ import (
 // Login 小红书登录处理
 type Login struct {
 	page *rod.Page
+	// expectedUserID 账号登记的小红书用户ID，非空时只保存属于该用户的 cookies
+	expectedUserID string
 }
 
 type UserInfo struct {
@@ -28,11 +31,30 @@ type UserInfo struct {
 	} `json:"data"`
 }
 
+// ErrNotLoggedIn 当前 cookies 未登录
+var ErrNotLoggedIn = errors.New("未登录，请先登录")
+
+// AccountMismatchError 登录的小红书用户与账号登记的用户不一致
+type AccountMismatchError struct {
+	ExpectedUserID string `json:"expected_user_id"`
+	ActualUserID   string `json:"actual_user_id"`
+}
+
+func (e *AccountMismatchError) Error() string {
+	return fmt.Sprintf("登录的小红书用户(%s)与账号登记的用户(%s)不一致", e.ActualUserID, e.ExpectedUserID)
+}
+
 // NewLogin 创建登录处理实例
 func NewLogin(page *rod.Page) *Login {
 	return &Login{page: page}
 }
 
+// ExpectUserID 设置账号登记的小红书用户ID，登录后校验当前用户，不一致时拒绝保存 cookies
+func (l *Login) ExpectUserID(userID string) *Login {
+	l.expectedUserID = userID
+	return l
+}
+
 // CheckLoginStatus 检查登录状态
 func (l *Login) CheckLoginStatus(ctx context.Context) (string, error) {
 	pp := l.page.Context(ctx)
@@ -42,13 +64,17 @@ func (l *Login) CheckLoginStatus(ctx context.Context) (string, error) {
 
 	time.Sleep(1 * time.Second)
 
+	if err := checkChallenge(pp); err != nil {
+		return "", err
+	}
+
 	exists, _, err := pp.Has(`.main-container .user .link-wrapper .channel`)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to check login status")
 	}
 
 	if !exists {
-		return "", errors.New("未登录，请先登录")
+		return "", ErrNotLoggedIn
 	}
 
 	accountIdText, err := l.getUserInfo(pp)
@@ -57,10 +83,14 @@ func (l *Login) CheckLoginStatus(ctx context.Context) (string, error) {
 	}
 	logrus.Infof("小红书账号: %+v，已经登录", accountIdText)
 
+	if err := l.checkUserID(accountIdText); err != nil {
+		return accountIdText, err
+	}
+
 	return accountIdText, nil
 }
 
-// Login 登录到小红书，accountID 用于保存 cookie 到该账号，为空为默认账号
+// Login 登录到小红书，accountID 用于保存 cookie 到该账号
 // 多账号时每个账号在独立的 incognito context 中，session 互不干扰
 func (l *Login) Login(ctx context.Context, accountID string) error {
 	logrus.Infof("登录流程 - accountID: %s", accountID)
@@ -70,12 +100,18 @@ func (l *Login) Login(ctx context.Context, accountID string) error {
 	pp.MustNavigate("https://www.xiaohongshu.com/explore").MustWaitLoad()
 	time.Sleep(2 * time.Second)
 
+	if err := checkChallenge(pp); err != nil {
+		return err
+	}
+
 	// 检查是否已登录
 	if exists, _, _ := pp.Has(".main-container .user .link-wrapper .channel"); exists {
 		logrus.Info("已登录，保存 cookies")
 
-		cookieManager := utils.NewCookieManagerForAccount(accountID)
-		if err := cookieManager.SaveCookies(pp); err != nil {
+		if err := l.saveAccountCookies(pp, accountID); err != nil {
+			if isAccountMismatch(err) {
+				return err
+			}
 			logrus.Warnf("保存 cookies 失败: %v", err)
 		}
 		return nil
@@ -99,16 +135,74 @@ func (l *Login) Login(ctx context.Context, accountID string) error {
 		return err
 	}
 
-	// 登录成功，保存 cookies
+	// 登录成功，校验用户后保存 cookies
+	if err := l.saveAccountCookies(pp, accountID); err != nil {
+		return err
+	}
+
+	logrus.Infof("登录成功 - accountID: %s", accountID)
+	return nil
+}
+
+// saveAccountCookies 校验当前登录用户属于该账号后保存 cookies
+// 用户不一致时清空浏览器中的 cookies，避免错误的 session 留在该账号的 context 中
+func (l *Login) saveAccountCookies(page *rod.Page, accountID string) error {
 	cookieManager := utils.NewCookieManagerForAccount(accountID)
-	if err := cookieManager.SaveCookies(pp); err != nil {
+
+	if l.expectedUserID != "" {
+		userID, err := l.currentUserID(page)
+		if err != nil {
+			return errors.Wrap(err, "获取当前登录用户失败")
+		}
+		if err := l.checkUserID(userID); err != nil {
+			logrus.Errorf("账号 %s 登录用户不一致，拒绝保存 cookies: %v", accountID, err)
+			if clearErr := cookieManager.ClearCookies(page); clearErr != nil {
+				logrus.Warnf("清空浏览器 cookies 失败: %v", clearErr)
+			}
+			return err
+		}
+	}
+
+	if err := cookieManager.SaveCookies(page); err != nil {
 		return errors.Wrap(err, "保存 cookies 失败")
 	}
+	return nil
+}
 
-	logrus.Infof("登录成功 - accountID: %s", accountID)
+// currentUserID 获取当前登录的小红书用户ID，不在首页时先导航到首页
+func (l *Login) currentUserID(page *rod.Page) (string, error) {
+	if exists, _, _ := page.Has(".main-container .user a.link-wrapper"); !exists {
+		page.MustNavigate("https://www.xiaohongshu.com/explore").MustWaitLoad()
+		time.Sleep(1 * time.Second)
+
+		if err := checkChallenge(page); err != nil {
+			return "", err
+		}
+	}
+
+	if exists, _, _ := page.Has(".main-container .user .link-wrapper .channel"); !exists {
+		return "", ErrNotLoggedIn
+	}
+	return l.getUserInfo(page)
+}
+
+// checkUserID 校验用户ID与账号登记的一致，未登记时不校验
+func (l *Login) checkUserID(userID string) error {
+	if l.expectedUserID == "" {
+		return nil
+	}
+	if userID != l.expectedUserID {
+		return &AccountMismatchError{ExpectedUserID: l.expectedUserID, ActualUserID: userID}
+	}
 	return nil
 }
 
+// isAccountMismatch 判断错误链中是否包含登录用户不一致
+func isAccountMismatch(err error) bool {
+	var mismatch *AccountMismatchError
+	return errors.As(err, &mismatch)
+}
+
 // getUserInfo 通过接口获取用户信息
 func (l *Login) getUserInfo(page *rod.Page) (string, error) {
 	href, err := page.MustElement(".main-container .user a.link-wrapper").Attribute("href")
@@ -126,6 +220,10 @@ func (l *Login) triggerLoginQRCode(page *rod.Page) error {
 	page.MustNavigate("https://www.xiaohongshu.com/login").MustWaitLoad()
 	time.Sleep(3 * time.Second)
 
+	if err := checkChallenge(page); err != nil {
+		return err
+	}
+
 	// 检查是否已经在登录页面上，如果有二维码直接返回
 	if qrExists, _, _ := page.Has(".qrcode-img"); qrExists {
 		logrus.Info("已在登录页面，发现二维码")
@@ -379,6 +477,11 @@ func (l *Login) waitForLoginSuccess(page *rod.Page, ctx context.Context) error {
 			}
 		}
 
+		// 扫码后可能弹出验证码
+		if err := checkChallenge(page); err != nil {
+			return err
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
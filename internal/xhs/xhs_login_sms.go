@@ -0,0 +1,237 @@
+package xhs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// 短信登录会话有效期（验证码一般 5 分钟内有效）
+	smsLoginSessionTTL = 5 * time.Minute
+)
+
+// 中国大陆手机号：11位，以1开头
+var phoneNumberRegexp = regexp.MustCompile(`^1\d{10}$`)
+
+// ErrInvalidPhoneNumber 手机号格式不正确
+var ErrInvalidPhoneNumber = errors.New("手机号格式不正确")
+
+// SMSLoginSession 短信验证码登录会话，在发送验证码和提交验证码两次调用之间保持登录页面
+type SMSLoginSession struct {
+	ID        string    `json:"session_id"`
+	AccountID string    `json:"account_id"`
+	Phone     string    `json:"phone"` // 脱敏后的手机号
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	page  *rod.Page
+	timer *time.Timer
+	mu    sync.Mutex
+}
+
+// NormalizePhoneNumber 规范化手机号：去掉空格、横线和 +86 前缀，并校验格式
+func NormalizePhoneNumber(phone string) (string, error) {
+	phone = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(phone))
+	phone = strings.TrimPrefix(phone, "+86")
+	if !phoneNumberRegexp.MatchString(phone) {
+		return "", errors.Wrapf(ErrInvalidPhoneNumber, "手机号 %s", phone)
+	}
+	return phone, nil
+}
+
+// maskPhoneNumber 手机号脱敏：138****8000
+func maskPhoneNumber(phone string) string {
+	if len(phone) < 7 {
+		return phone
+	}
+	return phone[:3] + "****" + phone[len(phone)-4:]
+}
+
+// newSessionID 生成随机会话ID
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand 不应失败，退化为时间戳
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
+
+// SendSMSCode 打开登录页，切换到手机号登录并触发发送短信验证码
+func (l *Login) SendSMSCode(ctx context.Context, phone string) error {
+	pp := l.page.Context(ctx)
+
+	logrus.Infof("短信登录 - 发送验证码到 %s", maskPhoneNumber(phone))
+
+	pp.MustNavigate("https://www.xiaohongshu.com/login").MustWaitLoad()
+	time.Sleep(3 * time.Second)
+
+	if err := checkChallenge(pp); err != nil {
+		return err
+	}
+
+	// 部分页面默认展示二维码，需要先切换到手机号登录
+	if exists, tab, _ := pp.HasR("div, span, a", "手机号登录|验证码登录"); exists && tab != nil {
+		if visible, _ := tab.Visible(); visible {
+			if err := tab.Click(proto.InputMouseButtonLeft, 1); err != nil {
+				logrus.Warnf("切换到手机号登录失败: %v", err)
+			}
+			time.Sleep(1 * time.Second)
+		}
+	}
+
+	phoneInput, err := l.findElement(pp, []string{
+		"input[placeholder*='手机号']",
+		".phone input",
+		"input[type='tel']",
+	})
+	if err != nil {
+		debugScreenshot(pp, "sms_phone_input_not_found")
+		return errors.Wrap(err, "未找到手机号输入框")
+	}
+	// 先全选已有内容，输入时直接覆盖
+	_ = phoneInput.SelectAllText()
+	if err := phoneInput.Input(phone); err != nil {
+		return errors.Wrap(err, "输入手机号失败")
+	}
+
+	// 勾选用户协议（未勾选时点击登录会弹出提示）
+	l.acceptAgreement(pp)
+
+	codeButton, err := l.findElement(pp, []string{
+		".code-button",
+		"span[class*='code']",
+	})
+	if err != nil {
+		if ok, elem, _ := pp.HasR("span, div, button", "获取验证码"); ok && elem != nil {
+			codeButton = elem
+		} else {
+			debugScreenshot(pp, "sms_code_button_not_found")
+			return errors.Wrap(err, "未找到获取验证码按钮")
+		}
+	}
+	if err := codeButton.Click(proto.InputMouseButtonLeft, 1); err != nil {
+		return errors.Wrap(err, "点击获取验证码失败")
+	}
+
+	time.Sleep(2 * time.Second)
+
+	// 发送验证码前可能要求先完成滑块验证
+	if err := checkChallenge(pp); err != nil {
+		return err
+	}
+
+	// 检查页面错误提示（如：手机号格式错误、发送过于频繁）
+	if msg := l.loginErrorMessage(pp); msg != "" {
+		return errors.Errorf("发送验证码失败: %s", msg)
+	}
+
+	logrus.Infof("短信验证码已发送到 %s", maskPhoneNumber(phone))
+	return nil
+}
+
+// SubmitSMSCode 输入短信验证码并提交登录，登录成功并校验用户后保存该账号的 cookies
+func (l *Login) SubmitSMSCode(ctx context.Context, accountID, code string) error {
+	pp := l.page.Context(ctx)
+
+	codeInput, err := l.findElement(pp, []string{
+		"input[placeholder*='验证码']",
+		".code-area input",
+		"input[type='number']",
+	})
+	if err != nil {
+		debugScreenshot(pp, "sms_code_input_not_found")
+		return errors.Wrap(err, "未找到验证码输入框")
+	}
+	// 重新提交时覆盖上次输入的验证码
+	_ = codeInput.SelectAllText()
+	if err := codeInput.Input(code); err != nil {
+		return errors.Wrap(err, "输入验证码失败")
+	}
+
+	l.acceptAgreement(pp)
+
+	submitButton, err := l.findElement(pp, []string{
+		".submit",
+		"button[type='submit']",
+	})
+	if err != nil {
+		if ok, elem, _ := pp.HasR("button, div", "^登录$"); ok && elem != nil {
+			submitButton = elem
+		} else {
+			debugScreenshot(pp, "sms_submit_not_found")
+			return errors.Wrap(err, "未找到登录按钮")
+		}
+	}
+	if err := submitButton.Click(proto.InputMouseButtonLeft, 1); err != nil {
+		return errors.Wrap(err, "点击登录失败")
+	}
+
+	time.Sleep(2 * time.Second)
+
+	if msg := l.loginErrorMessage(pp); msg != "" {
+		return errors.Errorf("验证码登录失败: %s", msg)
+	}
+
+	if err := l.waitForLoginSuccess(pp, ctx); err != nil {
+		return err
+	}
+
+	if err := l.saveAccountCookies(pp, accountID); err != nil {
+		return err
+	}
+
+	logrus.Infof("短信登录成功 - accountID: %s", accountID)
+	return nil
+}
+
+// findElement 依次尝试多个选择器，返回第一个可见元素
+func (l *Login) findElement(page *rod.Page, selectors []string) (*rod.Element, error) {
+	for _, selector := range selectors {
+		exists, elem, err := page.Has(selector)
+		if err != nil || !exists || elem == nil {
+			continue
+		}
+		if visible, _ := elem.Visible(); visible {
+			return elem, nil
+		}
+	}
+	return nil, errors.Errorf("未找到元素: %s", strings.Join(selectors, ", "))
+}
+
+// acceptAgreement 勾选登录协议
+func (l *Login) acceptAgreement(page *rod.Page) {
+	exists, checkbox, _ := page.Has(".agreements .icon-wrapper")
+	if !exists || checkbox == nil {
+		return
+	}
+	// 已勾选时图标带有 checked 样式
+	if class, err := checkbox.Attribute("class"); err == nil && class != nil && strings.Contains(*class, "checked") {
+		return
+	}
+	if err := checkbox.Click(proto.InputMouseButtonLeft, 1); err != nil {
+		logrus.Warnf("勾选登录协议失败: %v", err)
+	}
+}
+
+// loginErrorMessage 读取登录表单上的错误提示，没有则返回空字符串
+func (l *Login) loginErrorMessage(page *rod.Page) string {
+	for _, selector := range []string{".err-msg", ".error-msg", ".d-new-toast"} {
+		if exists, elem, _ := page.Has(selector); exists && elem != nil {
+			if text, err := elem.Text(); err == nil && strings.TrimSpace(text) != "" {
+				return strings.TrimSpace(text)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,214 @@
+package xhs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"sns-poster/internal/config"
+	"sns-poster/internal/sensitive"
+	"sns-poster/internal/utils"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// PreparedContent 发布前处理（不需要浏览器的步骤）的结果：最终发布的内容、警告和每张图片的检查结果
+type PreparedContent struct {
+	AccountID string   `json:"account_id"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	// TitleLength、ContentLength 按小红书编辑器规则计算的长度，正文长度包含标签
+	TitleLength   int `json:"title_length"`
+	ContentLength int `json:"content_length"`
+	// Images 每张图片的处理结果，顺序与请求一致
+	Images []*ImageCheck `json:"images"`
+	// Valid 没有错误，可以发布
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+	// SensitiveHits 命中的敏感词及其处理方式
+	SensitiveHits []sensitive.Hit `json:"sensitive_hits"`
+}
+
+// ImageCheck 单张图片的处理结果
+type ImageCheck struct {
+	Source string `json:"source"`         // 请求中的图片 URL 或本地路径
+	Path   string `json:"path,omitempty"` // 下载后的本地路径
+	Size   int64  `json:"size,omitempty"` // 字节数
+	// Used 是否上传，超过数量上限的图片不上传
+	Used  bool   `json:"used"`
+	Error string `json:"error,omitempty"`
+	// Normalized 规范化的结果（images.normalize 开启时），Path 和 Size 为规范化后的图片
+	Normalized *utils.ImageDiagnostics `json:"normalized,omitempty"`
+}
+
+// ValidateContent 试运行发布前的所有处理（敏感词、标签、截取、图片下载和检查），不使用浏览器。
+// 内容问题记录在返回结果的 Errors 中，不作为错误返回
+func (s *Service) ValidateContent(ctx context.Context, req *PublishContent) (*PreparedContent, error) {
+	content := *req
+	prepared, err := s.prepare(ctx, config.GetConfig(), &content, true)
+	if prepared == nil {
+		return nil, err
+	}
+	return prepared, nil
+}
+
+// prepare 执行发布前不需要浏览器的处理，并把结果写回 req。
+// dryRun 为 false 时遇到第一个错误即返回；为 true 时继续执行后续步骤以便报告所有问题，返回第一个错误
+func (s *Service) prepare(ctx context.Context, cfg *config.Config, req *PublishContent, dryRun bool) (*PreparedContent, error) {
+	p := &PreparedContent{
+		AccountID:     req.AccountID,
+		Images:        []*ImageCheck{},
+		Errors:        []string{},
+		Warnings:      []string{},
+		SensitiveHits: []sensitive.Hit{},
+	}
+	var firstErr error
+	fail := func(err error) bool {
+		if firstErr == nil {
+			firstErr = err
+		}
+		p.Errors = append(p.Errors, err.Error())
+		return !dryRun
+	}
+	finish := func() (*PreparedContent, error) {
+		p.Title, p.Content, p.Tags = req.Title, req.Content, req.Tags
+		if p.Tags == nil {
+			p.Tags = []string{}
+		}
+		p.Valid = len(p.Errors) == 0
+		return p, firstErr
+	}
+
+	// 标签：按配置（或请求的 extract_tags）提取正文中的 #话题，统一写法、替换同义词、去重，超过数量上限的忽略
+	extract := cfg.XHS.ExtractHashtags
+	if req.ExtractTags != nil {
+		extract = *req.ExtractTags
+	}
+	if extract {
+		var extracted []string
+		req.Content, extracted = extractHashtags(req.Content)
+		req.Tags = append(req.Tags, extracted...)
+	}
+	var dropped []string
+	req.Tags, dropped = newTagRules(&cfg.XHS).normalize(req.Tags)
+	if len(dropped) > 0 {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("标签数量超过限制 (%d)，忽略: %s", cfg.XHS.MaxTags, strings.Join(dropped, ", ")))
+	}
+
+	// 敏感词：命中 reject 词表时取消发布，其余按词表替换；命中的位置相对于原始内容
+	checked, err := s.words.Check(req.AccountID, &sensitive.Input{Title: req.Title, Content: req.Content, Tags: req.Tags})
+	p.SensitiveHits = checked.Hits
+	req.Title, req.Content, req.Tags = checked.Title, checked.Content, checked.Tags
+	if err != nil && fail(err) {
+		return finish()
+	}
+
+	// 自动截取标题长度 - 上限为 xhs.max_title_width（MaxTitleWidth，默认 38），按编辑器的规则计数（见 xhsLength）
+	// 优先在句子、分句、词语边界截取，不拆分 emoji
+	maxTitleWidth := cfg.XHS.MaxTitleWidth
+	originalWidth := xhsLength(req.Title)
+	if originalWidth > maxTitleWidth {
+		logrus.Warnf("标题长度超过限制 (%d > %d)，开始截取", originalWidth, maxTitleWidth)
+
+		// 截取到指定宽度
+		req.Title = truncateText(req.Title, maxTitleWidth, cfg.XHS.TruncateEllipsis)
+		p.Warnings = append(p.Warnings, fmt.Sprintf("标题长度超过限制 (%d > %d)，已截取", originalWidth, maxTitleWidth))
+
+		logrus.Infof("截取完成: %d字符 -> %d字符", originalWidth, xhsLength(req.Title))
+		logrus.Infof("截取后的标题: %s", req.Title)
+	}
+	p.TitleLength = xhsLength(req.Title)
+
+	// 自动截取内容长度 - 上限为 xhs.max_content_width（MaxContentWidth，默认 1200），标签输入在正文末尾，一起计数
+	maxContentWidth := cfg.XHS.MaxContentWidth - tagsLength(req.Tags)
+	if maxContentWidth <= 0 {
+		if fail(errors.Errorf("标签总长度 %d 超过正文长度限制 %d", tagsLength(req.Tags), cfg.XHS.MaxContentWidth)) {
+			return finish()
+		}
+		maxContentWidth = 0
+	}
+	originalContentWidth := xhsLength(req.Content)
+	if originalContentWidth > maxContentWidth {
+		logrus.Warnf("内容长度超过限制 (%d > %d)，开始截取", originalContentWidth, maxContentWidth)
+		req.Content = truncateText(req.Content, maxContentWidth, cfg.XHS.TruncateEllipsis)
+		p.Warnings = append(p.Warnings, fmt.Sprintf("内容长度超过限制 (%d > %d)，已截取", originalContentWidth, maxContentWidth))
+
+		logrus.Infof("截取完成: %d字符 -> %d字符", originalContentWidth, xhsLength(req.Content))
+	}
+	p.ContentLength = xhsLength(req.Content) + tagsLength(req.Tags)
+
+	logrus.Infof("处理图片: %v", req.URL)
+	// 处理图片：下载URL图片或使用本地路径，超过数量上限的图片不上传
+	processor := utils.NewImageProcessor(&cfg.Images, req.URL)
+	req.ImagePaths = nil
+	for i, image := range req.Images {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		check := &ImageCheck{Source: image}
+		p.Images = append(p.Images, check)
+		if i >= cfg.XHS.MaxImageCount {
+			continue
+		}
+
+		if err := checkImage(processor, cfg, check); err != nil {
+			check.Error = err.Error()
+			if fail(errors.Wrapf(err, "第 %d 张图片", i+1)) {
+				return finish()
+			}
+			continue
+		}
+		check.Used = true
+		req.ImagePaths = append(req.ImagePaths, check.Path)
+		if check.Normalized != nil {
+			for _, w := range check.Normalized.Warnings {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("第 %d 张图片: %s", i+1, w))
+			}
+		}
+	}
+	if len(req.Images) > cfg.XHS.MaxImageCount {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("图片数量超过限制 (%d > %d)，只使用前%d张图片",
+			len(req.Images), cfg.XHS.MaxImageCount, cfg.XHS.MaxImageCount))
+	}
+	if len(req.Images) == 0 {
+		fail(errors.New("图片不能为空"))
+	}
+
+	return finish()
+}
+
+// checkImage 下载或定位图片，按配置规范化格式、尺寸和大小，并检查大小
+func checkImage(processor *utils.ImageProcessor, cfg *config.Config, check *ImageCheck) error {
+	path, err := processor.ProcessImage(check.Source)
+	if err != nil {
+		return err
+	}
+	check.Path = path
+
+	if cfg.Images.Normalize {
+		opts := utils.NewNormalizeOptions(&cfg.Images, cfg.XHS.MaxImageSize())
+		normalized, diag, err := utils.NormalizeImage(path, cfg.Images.DownloadDir, opts)
+		if err != nil {
+			return err
+		}
+		check.Path, check.Normalized = normalized, diag
+		if len(diag.Actions) > 0 {
+			logrus.Infof("规范化图片 %s: %s", check.Source, strings.Join(diag.Actions, ", "))
+		}
+	}
+
+	stat, err := os.Stat(check.Path)
+	if err != nil {
+		return errors.Wrap(err, "读取图片失败")
+	}
+	check.Size = stat.Size()
+	if stat.Size() > cfg.XHS.MaxImageSize() {
+		return fmt.Errorf("图片过大: %.2fMB > %dMB", float64(stat.Size())/1024/1024, cfg.XHS.MaxImageSizeMB)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package xhs
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sns-poster/internal/config"
+	"sns-poster/internal/sensitive"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPrepareTestService 使用内置敏感词规则的服务，图片下载目录在临时目录中。
+// 测试图片不是有效图片，不做规范化
+func newPrepareTestService(t *testing.T) (*Service, *config.Config) {
+	words, err := sensitive.NewFilter(context.Background(), filepath.Join(t.TempDir(), "missing.json"), nil)
+	require.NoError(t, err)
+
+	cfg := config.Default()
+	cfg.Images.DownloadDir = t.TempDir()
+	cfg.Images.Normalize = false
+	cfg.XHS.MaxTitleWidth = 10
+	cfg.XHS.MaxImageCount = 2
+	cfg.XHS.MaxImageSizeMB = 1
+	return &Service{words: words}, cfg
+}
+
+// writeImage 写入指定大小的测试图片
+func writeImage(t *testing.T, name string, size int) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0600))
+	return path
+}
+
+func TestPrepareContent(t *testing.T) {
+	s, cfg := newPrepareTestService(t)
+	img1, img2, img3 := writeImage(t, "1.jpg", 10), writeImage(t, "2.jpg", 10), writeImage(t, "3.jpg", 10)
+
+	req := &PublishContent{
+		AccountID: "acct",
+		Title:     "一个很长很长的标题",
+		Content:   "详情见 https://example.com/item",
+		Images:    []string{img1, img2, img3},
+		Tags:      []string{"#手办", " 手办 ", "＃模型", ""},
+	}
+	p, err := s.prepare(context.Background(), cfg, req, false)
+	require.NoError(t, err)
+
+	assert.True(t, p.Valid)
+	assert.Equal(t, "一个很长…", p.Title)
+	assert.Equal(t, "详情见 ***", p.Content)
+	assert.Equal(t, []string{"手办", "模型"}, p.Tags)
+	assert.Equal(t, 10, p.TitleLength)
+	assert.Equal(t, xhsLength(p.Content)+tagsLength(p.Tags), p.ContentLength)
+	assert.Len(t, p.Warnings, 2)
+	require.Len(t, p.Images, 3)
+	assert.True(t, p.Images[1].Used)
+	assert.False(t, p.Images[2].Used)
+	assert.Equal(t, []string{img1, img2}, req.ImagePaths)
+	assert.Equal(t, p.Title, req.Title)
+}
+
+func TestValidateContentReportsAllErrors(t *testing.T) {
+	s, cfg := newPrepareTestService(t)
+	config.InitConfig(cfg)
+	t.Cleanup(func() { config.InitConfig(config.Default()) })
+
+	big := writeImage(t, "big.jpg", 2*1024*1024)
+	req := &PublishContent{
+		AccountID: "acct",
+		Title:     "我的英雄学院",
+		Content:   "正文",
+		Images:    []string{"/not/exist.jpg", big},
+	}
+	p, err := s.ValidateContent(context.Background(), req)
+	require.NoError(t, err)
+
+	assert.False(t, p.Valid)
+	require.Len(t, p.Errors, 3)
+	assert.Contains(t, p.Errors[0], "敏感词")
+	assert.NotEmpty(t, p.Images[0].Error)
+	assert.True(t, strings.Contains(p.Images[1].Error, "图片过大"))
+	// 试运行不修改请求
+	assert.Equal(t, "我的英雄学院", req.Title)
+
+	// 正式发布遇到第一个错误即返回
+	_, err = s.prepare(context.Background(), cfg, req, false)
+	var rejected *sensitive.RejectedError
+	assert.ErrorAs(t, err, &rejected)
+}
+
+func TestPrepareNormalizesImages(t *testing.T) {
+	s, cfg := newPrepareTestService(t)
+	cfg.Images.Normalize = true
+
+	path := filepath.Join(t.TempDir(), "anim.gif")
+	frame := image.NewPaletted(image.Rect(0, 0, 40, 20), color.Palette{color.White, color.Black})
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, gif.EncodeAll(f, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}))
+	require.NoError(t, f.Close())
+
+	req := &PublishContent{AccountID: "acct", Title: "标题", Content: "正文", Images: []string{path}}
+	p, err := s.prepare(context.Background(), cfg, req, false)
+	require.NoError(t, err)
+
+	check := p.Images[0]
+	require.NotNil(t, check.Normalized)
+	assert.Equal(t, "gif", check.Normalized.Format)
+	assert.Equal(t, "jpeg", check.Normalized.OutputFormat)
+	assert.Len(t, check.Normalized.Warnings, 1)
+	assert.Equal(t, []string{"第 1 张图片: 动图共 2 帧，只使用第一帧"}, p.Warnings)
+	assert.Equal(t, filepath.Dir(check.Path), cfg.Images.DownloadDir)
+	assert.Equal(t, []string{check.Path}, req.ImagePaths)
+	assert.Equal(t, check.Normalized.OutputSize, check.Size)
+}
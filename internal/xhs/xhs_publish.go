@@ -2,39 +2,94 @@ package xhs
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
+	"sns-poster/internal/config"
+
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/input"
+	"github.com/go-rod/rod/lib/proto"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 // PublishContent 发布内容结构
 type PublishContent struct {
-	AccountID  string   `json:"account_id,omitempty"` // 多账号时指定账号，为空为默认账号
-	Title      string   `json:"title" binding:"required"`
-	Content    string   `json:"content" binding:"required"`
+	AccountID  string   `json:"account_id,omitempty"` // 发布账号，由 HTTP 层解析
+	Title      string   `json:"title"`                // 指定模板时可以为空，使用模板渲染的标题
+	Content    string   `json:"content"`              // 指定模板时可以为空，使用模板渲染的正文
 	Images     []string `json:"images" binding:"required,min=1"`
 	Tags       []string `json:"tags,omitempty"`
 	ImagePaths []string `json:"-"` // 处理后的图片路径
 	URL        string   `json:"url,omitempty"`
+	// CallbackURL 发布完成后推送结果的地址，设置后接口立即返回任务ID，发布在后台进行
+	CallbackURL string `json:"callback_url,omitempty"`
+	// Template 内容模板名称，Fields 为渲染模板的字段（如商品名、价格、发售日期），由 HTTP 层渲染
+	Template string         `json:"template,omitempty"`
+	Fields   map[string]any `json:"fields,omitempty"`
+	// ExtractTags 是否从正文中提取 #话题 作为标签，为空时按配置 xhs.extract_hashtags
+	ExtractTags *bool `json:"extract_tags,omitempty"`
+	// UnmatchedTags 标签没有匹配到话题时的处理方式（keep/drop/fail），为空时按配置 xhs.unmatched_tags
+	UnmatchedTags string `json:"unmatched_tags,omitempty" binding:"omitempty,oneof=keep drop fail"`
+}
+
+// 标签没有匹配到话题（话题联想中没有与标签同名的话题）时的处理方式
+const (
+	UnmatchedTagsKeep = "keep" // 保留为普通文字
+	UnmatchedTagsDrop = "drop" // 从正文中删除
+	UnmatchedTagsFail = "fail" // 取消发布
+)
+
+// ErrCodeTagsUnmatched 标签没有匹配到话题且处理方式为 fail 时的错误码
+const ErrCodeTagsUnmatched = "TAGS_UNMATCHED"
+
+// TagResult 标签的话题匹配结果
+type TagResult struct {
+	Tag     string `json:"tag"`
+	Matched bool   `json:"matched"`           // 选中了话题联想中与标签同名（不区分大小写）的话题
+	Topic   string `json:"topic,omitempty"`   // 选中的话题名称
+	Dropped bool   `json:"dropped,omitempty"` // 没有匹配到话题，已从正文中删除
+	// Suggestion 没有同名话题时联想中的第一个话题，不会被选中
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// UnmatchedTagsError 有标签没有匹配到话题，且处理方式为 fail
+type UnmatchedTagsError struct {
+	Tags []TagResult `json:"tags"`
+}
+
+func (e *UnmatchedTagsError) Error() string {
+	var unmatched []string
+	for _, t := range e.Tags {
+		if !t.Matched {
+			unmatched = append(unmatched, t.Tag)
+		}
+	}
+	return fmt.Sprintf("标签没有匹配到话题: %s", strings.Join(unmatched, ", "))
+}
+
+// PublishResult 发布结果
+type PublishResult struct {
+	NoteID string      // 未能从发布接口获取时为空
+	Tags   []TagResult // 每个标签的话题匹配结果
 }
 
 // Publisher 小红书发布器
 type Publisher struct {
 	page      *rod.Page
+	config    *config.XHSConfig
 	accountID string
 }
 
 const (
-	// 直接进入图片发布模式
-	publishURL    = `https://creator.xiaohongshu.com/publish/publish?source=official&from=menu&target=image`
-	MaxImageCount = 18
-	MaxImageSize  = 32 * 1024 * 1024 // 32MB
+	// 创作中心发布笔记接口，响应中包含新笔记的ID
+	noteCreateAPIPath = "/web_api/sns/v2/note"
+	// 发布完成后等待发布接口响应的时间
+	noteIDWaitTimeout = 5 * time.Second
 )
 
 // debugScreenshot 保存调试截图
@@ -55,14 +110,16 @@ func debugScreenshot(page *rod.Page, filename string) error {
 }
 
 // NewPublisher 创建发布器实例，accountID 用于发布过程中如需登录时保存 cookie
-func NewPublisher(page *rod.Page, accountID string) (*Publisher, error) {
-	// 使用独立的context，设置足够长的超时时间
-	pp := page.Timeout(300 * time.Second) // 5分钟超时，足够完成发布流程
+// expectedUserID 为账号登记的小红书用户ID，自动登录时校验，不一致时拒绝保存 cookie
+// cfg 提供发布页面地址、超时时间和图片限制
+func NewPublisher(page *rod.Page, cfg *config.XHSConfig, accountID, expectedUserID string) (*Publisher, error) {
+	// 使用独立的context，设置足够长的超时时间，足够完成发布流程
+	pp := page.Timeout(cfg.PublishTimeout)
 
-	logrus.Info("开始导航到小红书发布页面", "url", publishURL)
+	logrus.Info("开始导航到小红书发布页面", "url", cfg.PublishURL)
 
 	// 导航到发布页面
-	err := pp.Navigate(publishURL)
+	err := pp.Navigate(cfg.PublishURL)
 	if err != nil {
 		return nil, fmt.Errorf("导航到发布页面失败: %w", err)
 	}
@@ -70,13 +127,17 @@ func NewPublisher(page *rod.Page, accountID string) (*Publisher, error) {
 	// 等待页面完全加载
 	time.Sleep(3 * time.Second)
 
+	if err := checkChallenge(pp); err != nil {
+		return nil, err
+	}
+
 	// 检查是否重定向到登录页面
 	currentURL := pp.MustInfo().URL
 	if strings.Contains(currentURL, "login") {
 		logrus.Infof("检测到登录页面，开始自动登录流程 (accountID: %s)", accountID)
 
 		// 在当前浏览器实例中执行登录（使用当前请求的 accountID 保存 cookie）
-		loginHandler := &Login{page: pp}
+		loginHandler := NewLogin(pp).ExpectUserID(expectedUserID)
 		loginErr := loginHandler.Login(context.Background(), accountID)
 		if loginErr != nil {
 			return nil, fmt.Errorf("发布时自动登录失败: %w", loginErr)
@@ -85,13 +146,17 @@ func NewPublisher(page *rod.Page, accountID string) (*Publisher, error) {
 		logrus.Info("发布时登录成功，重新导航到发布页面")
 
 		// 重新导航到发布页面
-		err = pp.Navigate(publishURL)
+		err = pp.Navigate(cfg.PublishURL)
 		if err != nil {
 			return nil, fmt.Errorf("登录后重新导航失败: %w", err)
 		}
 
 		// 再次等待页面加载
 		time.Sleep(3 * time.Second)
+
+		if err := checkChallenge(pp); err != nil {
+			return nil, err
+		}
 	}
 
 	logrus.Info("页面加载完成，开始查找上传内容区域")
@@ -99,6 +164,10 @@ func NewPublisher(page *rod.Page, accountID string) (*Publisher, error) {
 	// 等待上传内容区域可见
 	uploadElem, err := pp.Element("div.upload-wrapper")
 	if err != nil {
+		// 超时找不到上传区域时，先确认是否被验证码拦截
+		if challengeErr := checkChallenge(pp); challengeErr != nil {
+			return nil, challengeErr
+		}
 		debugScreenshot(pp, "upload_wrapper_not_found.png")
 		return nil, fmt.Errorf("找不到上传区域: %w", err)
 	}
@@ -112,35 +181,109 @@ func NewPublisher(page *rod.Page, accountID string) (*Publisher, error) {
 
 	return &Publisher{
 		page:      pp,
+		config:    cfg,
 		accountID: accountID,
 	}, nil
 }
 
-// Publish 发布内容
-func (p *Publisher) Publish(ctx context.Context, content PublishContent) error {
+// Publish 发布内容，返回新笔记的ID（未能从发布接口获取时为空）和标签的话题匹配结果
+func (p *Publisher) Publish(ctx context.Context, content PublishContent) (*PublishResult, error) {
 	if len(content.ImagePaths) == 0 {
-		return errors.New("图片不能为空")
+		return nil, errors.New("图片不能为空")
 	}
 
-	// 如果图片数量超过18张，截取前18张并记录日志
-	if len(content.ImagePaths) > MaxImageCount {
-		logrus.Warnf("图片数量超过限制 (%d > %d)，将只使用前%d张图片", len(content.ImagePaths), MaxImageCount, MaxImageCount)
-		content.ImagePaths = content.ImagePaths[:MaxImageCount]
+	// 如果图片数量超过上限，截取前面的图片并记录日志
+	maxImageCount := p.config.MaxImageCount
+	if len(content.ImagePaths) > maxImageCount {
+		logrus.Warnf("图片数量超过限制 (%d > %d)，将只使用前%d张图片", len(content.ImagePaths), maxImageCount, maxImageCount)
+		content.ImagePaths = content.ImagePaths[:maxImageCount]
 	}
 
 	page := p.page.Context(ctx)
 
 	// 上传图片
 	if err := p.uploadImages(page, content.ImagePaths); err != nil {
-		return errors.Wrap(err, "小红书上传图片失败")
+		return nil, errors.Wrap(err, "小红书上传图片失败")
+	}
+
+	// 提交前开始监听发布接口的响应
+	waitNoteID := watchNoteID(page)
+
+	unmatched := content.UnmatchedTags
+	if unmatched == "" {
+		unmatched = p.config.UnmatchedTags
 	}
 
 	// 提交发布
-	if err := p.submitPublish(page, content.Title, content.Content, content.Tags); err != nil {
-		return errors.Wrap(err, "小红书发布失败")
+	tags, err := p.submitPublish(page, content.Title, content.Content, content.Tags, unmatched)
+	if err != nil {
+		waitNoteID(0)
+		return nil, errors.Wrap(err, "小红书发布失败")
 	}
 
-	return nil
+	noteID := waitNoteID(noteIDWaitTimeout)
+	if noteID == "" {
+		logrus.Warn("未能从发布接口获取笔记ID")
+	}
+	return &PublishResult{NoteID: noteID, Tags: tags}, nil
+}
+
+// watchNoteID 监听发布笔记接口的响应，返回的函数最多等待 timeout 并取得笔记ID，同时停止监听
+func watchNoteID(page *rod.Page) func(timeout time.Duration) string {
+	ctx, cancel := context.WithCancel(page.GetContext())
+	result := make(chan string, 1)
+
+	// 回调在同一个 goroutine 中依次执行，无需加锁
+	var requestID proto.NetworkRequestID
+	wait := page.Context(ctx).EachEvent(
+		func(e *proto.NetworkResponseReceived) {
+			if strings.Contains(e.Response.URL, noteCreateAPIPath) {
+				requestID = e.RequestID
+			}
+		},
+		// 响应体在加载完成后才能读取
+		func(e *proto.NetworkLoadingFinished) bool {
+			if requestID == "" || e.RequestID != requestID {
+				return false
+			}
+			body, err := proto.NetworkGetResponseBody{RequestID: e.RequestID}.Call(page)
+			if err != nil {
+				logrus.Warnf("读取发布接口响应失败: %v", err)
+				result <- ""
+				return true
+			}
+			result <- parseNoteID(body.Body)
+			return true
+		},
+	)
+	go wait()
+
+	return func(timeout time.Duration) string {
+		defer cancel()
+		select {
+		case id := <-result:
+			return id
+		case <-time.After(timeout):
+			return ""
+		}
+	}
+}
+
+// parseNoteID 从发布接口的响应中解析笔记ID
+func parseNoteID(body string) string {
+	var resp struct {
+		Data struct {
+			ID     string `json:"id"`
+			NoteID string `json:"note_id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return ""
+	}
+	if resp.Data.ID != "" {
+		return resp.Data.ID
+	}
+	return resp.Data.NoteID
 }
 
 func (p *Publisher) uploadImages(page *rod.Page, imagesPaths []string) error {
@@ -154,8 +297,8 @@ func (p *Publisher) uploadImages(page *rod.Page, imagesPaths []string) error {
 		}
 		logrus.Info("准备上传", "index", i+1, "path", path, "size_mb", float64(stat.Size())/1024/1024)
 
-		if stat.Size() > MaxImageSize {
-			return fmt.Errorf("图片过大: %.2fMB > %dMB", float64(stat.Size())/1024/1024, MaxImageSize/1024/1024)
+		if stat.Size() > p.config.MaxImageSize() {
+			return fmt.Errorf("图片过大: %.2fMB > %dMB", float64(stat.Size())/1024/1024, p.config.MaxImageSizeMB)
 		}
 	}
 
@@ -214,17 +357,18 @@ func (p *Publisher) waitForUploadComplete(page *rod.Page, expectedCount int) err
 	return errors.New("上传超时，请检查网络连接和图片大小")
 }
 
-func (p *Publisher) submitPublish(page *rod.Page, title, content string, tags []string) error {
+// submitPublish 输入标题、正文和标签后提交，返回标签的话题匹配结果
+func (p *Publisher) submitPublish(page *rod.Page, title, content string, tags []string, unmatched string) ([]TagResult, error) {
 	logrus.Info("[提交发布] 开始提交发布")
 
 	titleElem, err := page.Element("div.d-input input.d-text")
 	if err != nil {
 		debugScreenshot(page, "title_input_not_found.png")
-		return fmt.Errorf("[提交发布] 查找标题输入框失败: %w", err)
+		return nil, fmt.Errorf("[提交发布] 查找标题输入框失败: %w", err)
 	}
 	err = titleElem.Input(title)
 	if err != nil {
-		return fmt.Errorf("[提交发布] 输入标题失败: %w", err)
+		return nil, fmt.Errorf("[提交发布] 输入标题失败: %w", err)
 	}
 
 	time.Sleep(1 * time.Second)
@@ -232,35 +376,39 @@ func (p *Publisher) submitPublish(page *rod.Page, title, content string, tags []
 	contentElem, err := page.Element("div.edit-container div[contenteditable='true']")
 	if err != nil {
 		debugScreenshot(page, "content_input_not_found.png")
-		return fmt.Errorf("[提交发布] 查找内容输入框失败: %w", err)
+		return nil, fmt.Errorf("[提交发布] 查找内容输入框失败: %w", err)
 	}
 
 	err = contentElem.Input(content)
 	if err != nil {
-		return fmt.Errorf("[提交发布] 输入内容失败: %w", err)
+		return nil, fmt.Errorf("[提交发布] 输入内容失败: %w", err)
 	}
 
-	p.inputTags(contentElem, tags)
+	tagResults, err := p.inputTags(contentElem, tags, unmatched)
+	if err != nil {
+		return nil, err
+	}
 
 	time.Sleep(1 * time.Second)
 
 	submitShadowRoot, err := page.MustElement("xhs-publish-btn").ShadowRoot()
 	if err != nil {
-		return fmt.Errorf("[提交发布] 查找阴影根元素失败: %w", err)
+		return nil, fmt.Errorf("[提交发布] 查找阴影根元素失败: %w", err)
 	}
 	submitButton, err := submitShadowRoot.ElementR("button", "发布")
 	if err != nil {
-		return fmt.Errorf("[提交发布] 查找提交按钮失败: %w", err)
+		return nil, fmt.Errorf("[提交发布] 查找提交按钮失败: %w", err)
 	}
 	submitButton.MustClick()
 
-	return p.waitPublishComplete(page)
+	return tagResults, p.waitPublishComplete(page)
 }
 
-func (p *Publisher) inputTags(contentElem *rod.Element, tags []string) {
+// inputTags 在正文末尾空一行后逐个输入标签，unmatched 为 fail 时有标签没有匹配到话题则返回 *UnmatchedTagsError
+func (p *Publisher) inputTags(contentElem *rod.Element, tags []string, unmatched string) ([]TagResult, error) {
 	logrus.Info("[提交发布] 开始输入标签", "tags", tags)
 	if len(tags) == 0 {
-		return
+		return nil, nil
 	}
 
 	time.Sleep(1 * time.Second)
@@ -279,13 +427,26 @@ func (p *Publisher) inputTags(contentElem *rod.Element, tags []string) {
 
 	time.Sleep(1 * time.Second)
 
+	results := make([]TagResult, 0, len(tags))
+	failed := false
 	for _, tag := range tags {
 		tag = strings.TrimLeft(tag, "#")
-		p.inputTag(contentElem, tag)
+		result := p.inputTag(contentElem, tag, unmatched == UnmatchedTagsDrop)
+		failed = failed || !result.Matched
+		results = append(results, result)
+	}
+
+	if failed && unmatched == UnmatchedTagsFail {
+		return nil, &UnmatchedTagsError{Tags: results}
 	}
+	return results, nil
 }
 
-func (p *Publisher) inputTag(contentElem *rod.Element, tag string) {
+// inputTag 输入 #标签 后从话题联想中选择与标签同名的话题。
+// 没有同名话题时不选择其他话题，输入空格保留为普通文字，drop 为 true 时删除已输入的 #标签
+func (p *Publisher) inputTag(contentElem *rod.Element, tag string, drop bool) TagResult {
+	result := TagResult{Tag: tag}
+
 	contentElem.MustInput("#")
 	time.Sleep(200 * time.Millisecond)
 
@@ -299,21 +460,82 @@ func (p *Publisher) inputTag(contentElem *rod.Element, tag string) {
 	page := contentElem.Page()
 	topicContainer, err := page.Element("#creator-editor-topic-container")
 	if err == nil && topicContainer != nil {
-		firstItem, err := topicContainer.Element(".item")
-		if err == nil && firstItem != nil {
-			firstItem.MustClick()
-			logrus.Info("成功点击标签联想选项", "tag", tag)
+		item, topic, suggestion := selectTopicItem(topicContainer, tag)
+		if item != nil {
+			item.MustClick()
+			result.Matched, result.Topic = true, topic
+			logrus.Info("成功点击标签联想选项", "tag", tag, "topic", topic)
 			time.Sleep(200 * time.Millisecond)
 		} else {
-			logrus.Warn("未找到标签联想选项，直接输入空格", "tag", tag)
-			contentElem.MustInput(" ")
+			result.Suggestion = suggestion
+			logrus.Warn("标签联想中没有同名话题", "tag", tag, "suggestion", suggestion)
 		}
 	} else {
-		logrus.Warn("未找到标签联想下拉框，直接输入空格", "tag", tag)
-		contentElem.MustInput(" ")
+		logrus.Warn("未找到标签联想下拉框", "tag", tag)
+	}
+
+	if !result.Matched {
+		if drop {
+			// 删除已输入的 #标签
+			keys := contentElem.MustKeyActions()
+			for range []rune("#" + tag) {
+				keys.Type(input.Backspace)
+			}
+			keys.MustDo()
+			result.Dropped = true
+		} else {
+			contentElem.MustInput(" ")
+		}
 	}
 
 	time.Sleep(500 * time.Millisecond)
+	return result
+}
+
+// selectTopicItem 在话题联想中选择与标签名称一致（不区分大小写）的话题，没有时 item 为 nil，
+// suggestion 为联想中的第一个话题（没有选项时为空）
+func selectTopicItem(container *rod.Element, tag string) (item *rod.Element, topic, suggestion string) {
+	items, err := container.Elements(".item")
+	if err != nil || len(items) == 0 {
+		return nil, "", ""
+	}
+
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = topicItemName(item)
+	}
+	i, suggestion := matchTopic(names, tag)
+	if i < 0 {
+		return nil, "", suggestion
+	}
+	return items[i], names[i], ""
+}
+
+// matchTopic 返回与标签同名（不区分大小写）的话题的下标，没有时返回 -1 和第一个话题名称
+func matchTopic(names []string, tag string) (int, string) {
+	first := ""
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		if tagKey(name) == tagKey(tag) {
+			return i, ""
+		}
+		if first == "" {
+			first = name
+		}
+	}
+	return -1, first
+}
+
+// topicItemName 话题联想选项中的话题名称（选项文字的第一行，如 "#手办" 下一行是浏览量）
+func topicItemName(item *rod.Element) string {
+	text, err := item.Text()
+	if err != nil {
+		return ""
+	}
+	name := strings.SplitN(strings.TrimSpace(text), "\n", 2)[0]
+	return cleanTag(name)
 }
 
 func (p *Publisher) waitPublishComplete(page *rod.Page) error {
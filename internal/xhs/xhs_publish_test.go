@@ -0,0 +1,41 @@
+package xhs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNoteID(t *testing.T) {
+	assert.Equal(t, "abc", parseNoteID(`{"success":true,"data":{"id":"abc"}}`))
+	assert.Equal(t, "def", parseNoteID(`{"success":true,"data":{"note_id":"def"}}`))
+	assert.Equal(t, "", parseNoteID(`{"success":false,"msg":"error"}`))
+	assert.Equal(t, "", parseNoteID(`not json`))
+}
+
+func TestUnmatchedTagsError(t *testing.T) {
+	err := &UnmatchedTagsError{Tags: []TagResult{
+		{Tag: "手办", Matched: true, Topic: "手办"},
+		{Tag: "冷门A"},
+		{Tag: "冷门B"},
+	}}
+	assert.Equal(t, "标签没有匹配到话题: 冷门A, 冷门B", err.Error())
+}
+
+func TestMatchTopic(t *testing.T) {
+	i, suggestion := matchTopic([]string{"手办模型", "", "手办"}, "手办")
+	assert.Equal(t, 2, i)
+	assert.Empty(t, suggestion)
+
+	i, _ = matchTopic([]string{"figure", "Figma"}, "Figma")
+	assert.Equal(t, 1, i)
+
+	// 联想中没有同名话题时不选择其他话题
+	i, suggestion = matchTopic([]string{"", "手办模型", "手办收藏"}, "冷门手办")
+	assert.Equal(t, -1, i)
+	assert.Equal(t, "手办模型", suggestion)
+
+	i, suggestion = matchTopic(nil, "手办")
+	assert.Equal(t, -1, i)
+	assert.Empty(t, suggestion)
+}
@@ -0,0 +1,213 @@
+package xhs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// 远程处理会话有效期，超时自动关闭页面
+	remoteViewTTL = 10 * time.Minute
+	// 拖动滑块时的移动步数，步数越多越接近人工操作
+	remoteViewDragSteps = 30
+)
+
+// ErrRemoteViewNotFound 远程处理会话不存在或已过期
+var ErrRemoteViewNotFound = errors.New("远程处理会话不存在或已过期")
+
+// RemoteViewSession 把遇到验证码的页面保留下来，交给人工远程处理
+// 人工通过截图查看页面，通过点击/拖动/输入操作页面，处理完后关闭会话
+type RemoteViewSession struct {
+	ID        string    `json:"id"`
+	AccountID string    `json:"account_id"`
+	Kind      string    `json:"kind"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	page  *rod.Page
+	timer *time.Timer
+	mu    sync.Mutex // 同一会话的页面操作串行执行
+}
+
+// RemoteViewAction 人工远程操作
+type RemoteViewAction struct {
+	Type string  `json:"type" binding:"required,oneof=click drag type"` // click: 点击, drag: 拖动(滑块), type: 输入文字
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	ToX  float64 `json:"to_x,omitempty"` // drag 终点
+	ToY  float64 `json:"to_y,omitempty"`
+	Text string  `json:"text,omitempty"` // type 输入内容
+}
+
+// openRemoteView 接管页面，创建远程处理会话
+func (s *Service) openRemoteView(accountID string, page *rod.Page, challenge *ChallengeError) *RemoteViewSession {
+	now := time.Now()
+	session := &RemoteViewSession{
+		ID:        newSessionID(),
+		AccountID: accountID,
+		Kind:      challenge.Kind,
+		URL:       challenge.URL,
+		CreatedAt: now,
+		ExpiresAt: now.Add(remoteViewTTL),
+		page:      page,
+	}
+
+	s.remoteViewMux.Lock()
+	s.remoteViews[session.ID] = session
+	s.remoteViewMux.Unlock()
+
+	// 超时自动关闭页面；正在执行截图或操作时等待其结束
+	session.timer = time.AfterFunc(remoteViewTTL, func() {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		if expired := s.takeRemoteView(session.ID); expired != nil {
+			logrus.Warnf("远程处理会话已过期: %s (accountID: %s)", expired.ID, expired.AccountID)
+			expired.page.Close()
+		}
+	})
+
+	logrus.Infof("已创建远程处理会话: %s (accountID: %s, 类型: %s)", session.ID, accountID, challenge.Kind)
+	return session
+}
+
+// GetRemoteView 获取远程处理会话
+func (s *Service) GetRemoteView(id string) (*RemoteViewSession, error) {
+	s.remoteViewMux.Lock()
+	defer s.remoteViewMux.Unlock()
+
+	session, ok := s.remoteViews[id]
+	if !ok {
+		return nil, ErrRemoteViewNotFound
+	}
+	return session, nil
+}
+
+// lockRemoteView 获取远程处理会话并加锁，调用方负责解锁；等待锁期间会话已过期关闭时返回 ErrRemoteViewNotFound
+func (s *Service) lockRemoteView(id string) (*RemoteViewSession, error) {
+	session, err := s.GetRemoteView(id)
+	if err != nil {
+		return nil, err
+	}
+
+	session.mu.Lock()
+	if _, err := s.GetRemoteView(id); err != nil {
+		session.mu.Unlock()
+		return nil, err
+	}
+	return session, nil
+}
+
+// RemoteViewScreenshot 截取远程处理页面当前画面（PNG）
+func (s *Service) RemoteViewScreenshot(ctx context.Context, id string) ([]byte, error) {
+	session, err := s.lockRemoteView(id)
+	if err != nil {
+		return nil, err
+	}
+	defer session.mu.Unlock()
+
+	return session.page.Context(ctx).Screenshot(false, &proto.PageCaptureScreenshot{})
+}
+
+// RemoteViewDo 在远程处理页面上执行人工操作
+func (s *Service) RemoteViewDo(ctx context.Context, id string, action RemoteViewAction) error {
+	session, err := s.lockRemoteView(id)
+	if err != nil {
+		return err
+	}
+	defer session.mu.Unlock()
+
+	page := session.page.Context(ctx)
+	mouse := page.Mouse
+
+	switch action.Type {
+	case "click":
+		if err := mouse.MoveTo(proto.Point{X: action.X, Y: action.Y}); err != nil {
+			return errors.Wrap(err, "移动鼠标失败")
+		}
+		return mouse.Click(proto.InputMouseButtonLeft, 1)
+	case "drag":
+		if err := mouse.MoveTo(proto.Point{X: action.X, Y: action.Y}); err != nil {
+			return errors.Wrap(err, "移动鼠标失败")
+		}
+		if err := mouse.Down(proto.InputMouseButtonLeft, 1); err != nil {
+			return errors.Wrap(err, "按下鼠标失败")
+		}
+		if err := mouse.MoveLinear(proto.Point{X: action.ToX, Y: action.ToY}, remoteViewDragSteps); err != nil {
+			return errors.Wrap(err, "拖动失败")
+		}
+		return mouse.Up(proto.InputMouseButtonLeft, 1)
+	case "type":
+		return page.InsertText(action.Text)
+	default:
+		return errors.Errorf("不支持的操作类型: %s", action.Type)
+	}
+}
+
+// CloseRemoteView 结束远程处理：验证码已通过时保存 cookies 并清除账号的待处理标记
+// 返回验证码是否已通过
+func (s *Service) CloseRemoteView(ctx context.Context, id string) (bool, error) {
+	session := s.takeRemoteView(id)
+	if session == nil {
+		return false, ErrRemoteViewNotFound
+	}
+	session.timer.Stop()
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	defer session.page.Close()
+
+	page := session.page.Context(ctx)
+	if challenge := detectChallenge(page); challenge != nil {
+		logrus.Warnf("远程处理会话 %s 关闭时验证码仍未通过", id)
+		return false, nil
+	}
+
+	// 已登录时校验用户属于该账号，不一致时不保存
+	loginAction := NewLogin(page).ExpectUserID(s.expectedUserID(session.AccountID))
+	if err := loginAction.saveAccountCookies(page, session.AccountID); err != nil {
+		if isAccountMismatch(err) {
+			s.markAttention(session.AccountID, ErrCodeAccountMismatch, err.Error())
+			return true, err
+		}
+		if !errors.Is(err, ErrNotLoggedIn) {
+			logrus.Warnf("远程处理后保存 cookies 失败: %v", err)
+		}
+	}
+	s.ClearAttention(session.AccountID)
+
+	logrus.Infof("远程处理会话 %s 已完成，验证码已通过 (accountID: %s)", id, session.AccountID)
+	return true, nil
+}
+
+// takeRemoteView 取出并移除远程处理会话，不存在返回 nil
+func (s *Service) takeRemoteView(id string) *RemoteViewSession {
+	s.remoteViewMux.Lock()
+	defer s.remoteViewMux.Unlock()
+
+	session, ok := s.remoteViews[id]
+	if !ok {
+		return nil
+	}
+	delete(s.remoteViews, id)
+	return session
+}
+
+// holdsPage 页面是否已被远程处理会话接管
+func (s *Service) holdsPage(page *rod.Page) bool {
+	s.remoteViewMux.Lock()
+	defer s.remoteViewMux.Unlock()
+
+	for _, session := range s.remoteViews {
+		if session.page == page {
+			return true
+		}
+	}
+	return false
+}
@@ -3,39 +3,54 @@ package xhs
 import (
 	"context"
 	"fmt"
-	"regexp"
 	"strings"
 	"sync"
+	"time"
 
+	"sns-poster/internal/account"
 	"sns-poster/internal/config"
+	"sns-poster/internal/sensitive"
 	"sns-poster/internal/utils"
 
-	"github.com/mattn/go-runewidth"
+	"github.com/go-rod/rod"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 // Service 小红书服务
 type Service struct {
-	config     *config.Config
+	accounts   *account.Registry
+	words      *sensitive.Filter
 	browser    *utils.Browser
 	browserMux sync.Mutex
-}
 
-const (
-	// 标题最大长度（中文2字符，英文1字符, \n 算2个字符）
-	MaxTitleRuneWidth = 38
-	// 内容最大长度（中文2字符，英文1字符, \n 算2个字符）
-	MaxContentRuneWidth = 1200
-)
+	// 短信登录会话：sessionID -> 会话（保持登录页面直到提交验证码）
+	smsSessions   map[string]*SMSLoginSession
+	smsSessionMux sync.Mutex
+
+	// 验证码远程处理会话：sessionID -> 会话（接管遇到验证码的页面）
+	remoteViews   map[string]*RemoteViewSession
+	remoteViewMux sync.Mutex
 
-// NewService 创建小红书服务
-func NewService(cfg *config.Config) *Service {
+	// 后台账号登录健康检查
+	health *HealthMonitor
+	// 后台登录 session 过期前自动刷新
+	sessions *SessionRefresher
+}
+
+// NewService 创建小红书服务。cfg 作为全局配置，之后通过 config.GetConfig 读取，支持热更新
+func NewService(cfg *config.Config, accounts *account.Registry, words *sensitive.Filter) *Service {
 	config.InitConfig(cfg)
-	return &Service{
-		config: cfg,
+	s := &Service{
+		accounts: accounts,
+		words:    words,
 		// 不在这里创建浏览器，延迟到首次使用
+		smsSessions: make(map[string]*SMSLoginSession),
+		remoteViews: make(map[string]*RemoteViewSession),
 	}
+	s.health = newHealthMonitor(s)
+	s.sessions = newSessionRefresher(s)
+	return s
 }
 
 // getBrowser 获取或创建浏览器实例（懒加载 + 自动重连）
@@ -46,7 +61,7 @@ func (s *Service) getBrowser() *utils.Browser {
 	// 首次创建或重新连接
 	if s.browser == nil {
 		logrus.Info("创建新的浏览器连接...")
-		s.browser = utils.NewBrowser(s.config)
+		s.browser = utils.NewBrowser(config.GetConfig())
 		return s.browser
 	}
 
@@ -54,12 +69,36 @@ func (s *Service) getBrowser() *utils.Browser {
 	if !s.isBrowserConnected() {
 		logrus.Warn("浏览器连接已断开，正在重新连接...")
 		s.browser.Close() // 清理旧连接
-		s.browser = utils.NewBrowser(s.config)
+		s.browser = utils.NewBrowser(config.GetConfig())
 	}
 
 	return s.browser
 }
 
+// newPage 为账号创建页面，使用账号注册表中配置的代理
+func (s *Service) newPage(accountID string) *rod.Page {
+	proxy := ""
+	if a, err := s.accounts.Get(accountID); err == nil {
+		proxy = a.Proxy
+	}
+	return s.getBrowser().NewPageWithProxy(accountID, proxy)
+}
+
+// Accounts 返回账号注册表
+func (s *Service) Accounts() *account.Registry {
+	return s.accounts
+}
+
+// HealthMonitor 返回账号登录健康监控
+func (s *Service) HealthMonitor() *HealthMonitor {
+	return s.health
+}
+
+// SessionRefresher 返回登录 session 自动刷新器
+func (s *Service) SessionRefresher() *SessionRefresher {
+	return s.sessions
+}
+
 // isBrowserConnected 检查浏览器连接是否有效
 func (s *Service) isBrowserConnected() bool {
 	if s.browser == nil || s.browser.Browser == nil {
@@ -78,6 +117,9 @@ func (s *Service) isBrowserConnected() bool {
 	return true
 }
 
+// ErrSMSSessionNotFound 短信登录会话不存在或已过期
+var ErrSMSSessionNotFound = errors.New("短信登录会话不存在或已过期")
+
 // LoginStatusResponse 登录状态响应
 type LoginStatusResponse struct {
 	IsLoggedIn bool   `json:"is_logged_in"`
@@ -91,24 +133,39 @@ type LoginResponse struct {
 	Message string `json:"message"`
 }
 
+// 发布任务状态
+const (
+	PublishStatusAccepted  = "accepted"  // 已接受，后台发布中（请求指定了回调地址）
+	PublishStatusPublished = "published" // 发布成功
+)
+
 // PublishResponse 发布响应
 type PublishResponse struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
-	Images  int    `json:"images"`
-	Status  string `json:"status"`
+	JobID     string `json:"job_id,omitempty"`
+	AccountID string `json:"account_id"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	Images    int    `json:"images"`
+	NoteID    string `json:"note_id,omitempty"` // 未能从发布接口获取时为空
+	Status    string `json:"status"`
+	// Tags 每个标签的话题匹配结果
+	Tags []TagResult `json:"tags,omitempty"`
+	// Warnings 发布前处理的警告，如标题被截取
+	Warnings []string `json:"warnings,omitempty"`
+	// SensitiveHits 命中的敏感词及其处理方式
+	SensitiveHits []sensitive.Hit `json:"sensitive_hits,omitempty"`
 }
 
 // CheckLoginStatus 检查登录状态
 func (s *Service) CheckLoginStatus(ctx context.Context, accountID string) (*LoginStatusResponse, error) {
-	page := s.getBrowser().NewPage(accountID)
-	defer page.Close()
+	page := s.newPage(accountID)
+	defer s.releasePage(page)
 
-	loginAction := NewLogin(page)
+	loginAction := NewLogin(page).ExpectUserID(s.expectedUserID(accountID))
 
 	accountIdText, err := loginAction.CheckLoginStatus(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to check login status")
+		return nil, errors.Wrap(s.handleIntervention(accountID, page, err), "failed to check login status")
 	}
 
 	response := &LoginStatusResponse{
@@ -120,24 +177,31 @@ func (s *Service) CheckLoginStatus(ctx context.Context, accountID string) (*Logi
 	return response, nil
 }
 
-// Login 登录到小红书，accountID 为空时使用默认单账号
+// Login 登录到小红书
 func (s *Service) Login(ctx context.Context, accountID string) (*LoginResponse, error) {
 	logrus.Infof("登录小红书账号: %s", accountID)
 
-	page := s.getBrowser().NewPage(accountID)
-	defer page.Close()
+	page := s.newPage(accountID)
+	defer s.releasePage(page)
 
-	loginAction := NewLogin(page)
+	loginAction := NewLogin(page).ExpectUserID(s.expectedUserID(accountID))
 
 	err := loginAction.Login(ctx, accountID)
 	if err != nil {
 		logrus.Errorf("登录小红书账号 %s 失败: %v", accountID, err)
+		// 验证码、登录用户不一致需要人工处理，作为错误返回以便调用方区分
+		if err := s.handleIntervention(accountID, page, err); needsIntervention(err) {
+			return nil, err
+		}
 		return &LoginResponse{
 			Success: false,
 			Message: fmt.Sprintf("登录失败: %v", err),
 		}, nil
 	}
 
+	s.ClearAttention(accountID)
+	s.touchLogin(accountID)
+
 	response := &LoginResponse{
 		Success: true,
 		Message: "登录成功",
@@ -146,111 +210,242 @@ func (s *Service) Login(ctx context.Context, accountID string) (*LoginResponse,
 	return response, nil
 }
 
-// Logout 登出小红书：删除该账号的 cookie 文件，accountID 为空时使用默认单账号
-func (s *Service) Logout(ctx context.Context, accountID string) (*LoginResponse, error) {
-	cm := utils.NewCookieManagerForAccount(accountID)
-	if err := cm.ClearCookieFile(); err != nil {
+// StartSMSLogin 短信验证码登录第一步：打开登录页并向手机号发送验证码
+// 返回的会话需在有效期内通过 SubmitSMSCode 提交验证码
+func (s *Service) StartSMSLogin(ctx context.Context, accountID, phone string) (*SMSLoginSession, error) {
+	phone, err := NormalizePhoneNumber(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("短信登录小红书账号: %s, 手机号: %s", accountID, maskPhoneNumber(phone))
+
+	page := s.newPage(accountID)
+
+	loginAction := NewLogin(page)
+	if err := loginAction.SendSMSCode(ctx, phone); err != nil {
+		err = s.handleIntervention(accountID, page, err)
+		s.releasePage(page)
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &SMSLoginSession{
+		ID:        newSessionID(),
+		AccountID: accountID,
+		Phone:     maskPhoneNumber(phone),
+		CreatedAt: now,
+		ExpiresAt: now.Add(smsLoginSessionTTL),
+		page:      page,
+	}
+
+	s.smsSessionMux.Lock()
+	s.smsSessions[session.ID] = session
+	s.smsSessionMux.Unlock()
+
+	// 超时未提交验证码，自动关闭页面；正在提交时等待提交结束，提交成功后会话已被取出，不再处理
+	session.timer = time.AfterFunc(smsLoginSessionTTL, func() {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		if expired := s.takeSMSSession(session.ID); expired != nil {
+			logrus.Warnf("短信登录会话已过期: %s (accountID: %s)", expired.ID, expired.AccountID)
+			expired.page.Close()
+		}
+	})
+
+	return session, nil
+}
+
+// SubmitSMSCode 短信验证码登录第二步：提交验证码完成登录并保存 cookies
+// 验证码错误时会话保留，可在有效期内重新提交
+func (s *Service) SubmitSMSCode(ctx context.Context, sessionID, code string) (*LoginResponse, error) {
+	s.smsSessionMux.Lock()
+	session, ok := s.smsSessions[sessionID]
+	s.smsSessionMux.Unlock()
+	if !ok {
+		return nil, ErrSMSSessionNotFound
+	}
+
+	// 同一会话同时只允许一次提交，也不与过期清理同时进行
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	// 等待期间会话可能已过期关闭
+	s.smsSessionMux.Lock()
+	_, ok = s.smsSessions[sessionID]
+	s.smsSessionMux.Unlock()
+	if !ok {
+		return nil, ErrSMSSessionNotFound
+	}
+
+	loginAction := NewLogin(session.page).ExpectUserID(s.expectedUserID(session.AccountID))
+	if err := loginAction.SubmitSMSCode(ctx, session.AccountID, strings.TrimSpace(code)); err != nil {
+		logrus.Errorf("短信登录小红书账号 %s 失败: %v", session.AccountID, err)
+		if challenge := asChallenge(err); challenge != nil {
+			s.markChallenge(session.AccountID, challenge)
+			return nil, err
+		}
+		if isAccountMismatch(err) {
+			s.markAttention(session.AccountID, ErrCodeAccountMismatch, err.Error())
+			return nil, err
+		}
 		return &LoginResponse{
 			Success: false,
-			Message: fmt.Sprintf("登出失败: %v", err),
+			Message: fmt.Sprintf("登录失败: %v", err),
 		}, nil
 	}
-	return &LoginResponse{Success: true, Message: "登出成功"}, nil
+
+	if s.takeSMSSession(sessionID) != nil {
+		session.timer.Stop()
+		session.page.Close()
+	}
+	s.ClearAttention(session.AccountID)
+	s.touchLogin(session.AccountID)
+
+	return &LoginResponse{Success: true, Message: "登录成功"}, nil
 }
 
-// Close 关闭服务
-func (s *Service) Close() {
-	if s.browser != nil {
-		s.browser.Close()
-		logrus.Info("XHS服务清理完成")
+// SMSSessionAccountID 返回短信登录会话所属的账号ID
+func (s *Service) SMSSessionAccountID(sessionID string) (string, error) {
+	s.smsSessionMux.Lock()
+	defer s.smsSessionMux.Unlock()
+
+	session, ok := s.smsSessions[sessionID]
+	if !ok {
+		return "", ErrSMSSessionNotFound
 	}
+	return session.AccountID, nil
 }
 
-// filterSensitiveWordsByRegex 使用正则表达式过滤内容中的敏感词
-func (s *Service) filterSensitiveWordsByRegex(content string) string {
-	// 过滤内容中的敏感词
-	regexPatterns := []string{
-		// 正则表达式过滤超链接
-		"https?://[^\\s]+",
-		"我的英雄学院",
+// takeSMSSession 取出并移除短信登录会话，不存在返回 nil
+func (s *Service) takeSMSSession(sessionID string) *SMSLoginSession {
+	s.smsSessionMux.Lock()
+	defer s.smsSessionMux.Unlock()
+
+	session, ok := s.smsSessions[sessionID]
+	if !ok {
+		return nil
 	}
+	delete(s.smsSessions, sessionID)
+	return session
+}
 
-	re := regexp.MustCompile(strings.Join(regexPatterns, "|"))
-	return re.ReplaceAllString(content, "***")
+// expectedUserID 返回账号登记的小红书用户ID，未登记返回空字符串
+func (s *Service) expectedUserID(accountID string) string {
+	a, err := s.accounts.Get(accountID)
+	if err != nil {
+		return ""
+	}
+	return a.XHSUserID
 }
 
-// filterOutSensitiveWords 过滤内容中的敏感词
-// 如果标题包含敏感词，取消发布，并返回错误
-func (s *Service) filterOutSensitiveTitle(content string) error {
-	sensitiveWords := []string{
-		"我的英雄学院",
+// verifySession 校验页面当前的登录用户与账号登记的一致
+// 未登记用户ID或未登录时跳过（未登录由发布器自动登录并校验）
+func (s *Service) verifySession(ctx context.Context, page *rod.Page, accountID, expectedUserID string) error {
+	if expectedUserID == "" {
+		logrus.Warnf("账号 %s 未登记小红书用户ID，跳过登录用户校验", accountID)
+		return nil
 	}
 
-	for _, word := range sensitiveWords {
-		if strings.Contains(content, word) {
-			return fmt.Errorf("标题包含敏感词: %s, 取消发布", word)
+	loginAction := NewLogin(page).ExpectUserID(expectedUserID)
+	_, err := loginAction.CheckLoginStatus(ctx)
+	switch {
+	case err == nil, errors.Is(err, ErrNotLoggedIn):
+		return nil
+	case isAccountMismatch(err):
+		logrus.Errorf("账号 %s 保存的 session 属于其他用户，拒绝发布: %v", accountID, err)
+		// 同时删除保存的 cookies，否则之后为该账号创建的页面会重新加载其他用户的 session
+		cm := utils.NewCookieManagerForAccount(accountID)
+		if clearErr := cm.ClearCookies(page); clearErr != nil {
+			logrus.Warnf("清空浏览器 cookies 失败: %v", clearErr)
 		}
+		if clearErr := cm.ClearCookieFile(); clearErr != nil {
+			logrus.Warnf("删除账号 %s 保存的 cookies 失败: %v", accountID, clearErr)
+		}
+		return s.handleIntervention(accountID, page, err)
+	case isChallenge(err):
+		return s.handleIntervention(accountID, page, err)
+	default:
+		// 其他错误不阻止发布，发布器会重新检查登录
+		logrus.Warnf("校验账号 %s 登录用户失败: %v", accountID, err)
+		return nil
 	}
-	return nil
 }
 
-// PublishContent 发布内容
-func (s *Service) PublishContent(ctx context.Context, req *PublishContent) (*PublishResponse, error) {
-	// 自动截取标题长度 - 小红书限制：最大40个字符(中文2字符，英文1字符)
-	// 使用 runewidth 计算显示宽度（中文2字符，英文1字符）
-	originalWidth := runewidth.StringWidth(req.Title)
-	if originalWidth > MaxTitleRuneWidth {
-		logrus.Warnf("标题长度超过限制 (%d > %d)，开始截取", originalWidth, MaxTitleRuneWidth)
-
-		// 截取到指定宽度
-		req.Title = runewidth.Truncate(req.Title, MaxTitleRuneWidth, "")
-
-		logrus.Infof("截取完成: %d字符 -> %d字符", originalWidth, runewidth.StringWidth(req.Title))
-		logrus.Infof("截取后的标题: %s", req.Title)
+// touchLogin 记录账号登录时间
+func (s *Service) touchLogin(accountID string) {
+	if err := s.accounts.TouchLogin(accountID); err != nil {
+		logrus.Warnf("记录账号 %s 登录时间失败: %v", accountID, err)
 	}
+}
 
-	if err := s.filterOutSensitiveTitle(req.Title); err != nil {
-		return nil, err
+// Logout 登出小红书：删除该账号保存的 cookies
+func (s *Service) Logout(ctx context.Context, accountID string) (*LoginResponse, error) {
+	cm := utils.NewCookieManagerForAccount(accountID)
+	if err := cm.ClearCookieFile(); err != nil {
+		return &LoginResponse{
+			Success: false,
+			Message: fmt.Sprintf("登出失败: %v", err),
+		}, nil
 	}
+	return &LoginResponse{Success: true, Message: "登出成功"}, nil
+}
 
-	// 自动截取内容长度 - 小红书限制：最大2000个字符
-	// 使用 runewidth 计算显示宽度（中文2字符，英文1字符）
-	originalContentWidth := runewidth.StringWidth(req.Content)
-	if originalContentWidth > MaxContentRuneWidth {
-		logrus.Warnf("内容长度超过限制 (%d > %d)，开始截取", originalContentWidth, MaxContentRuneWidth)
-		req.Content = runewidth.Truncate(req.Content, MaxContentRuneWidth, "")
+// Close 关闭服务
+func (s *Service) Close() {
+	s.health.Stop()
+	s.sessions.Stop()
 
-		logrus.Infof("截取完成: %d字符 -> %d字符", originalContentWidth, runewidth.StringWidth(req.Content))
+	if s.browser != nil {
+		s.browser.Close()
+		logrus.Info("XHS服务清理完成")
 	}
+}
 
-	// 过滤内容中的敏感词
-	req.Content = s.filterSensitiveWordsByRegex(req.Content)
+// PublishContent 发布内容
+func (s *Service) PublishContent(ctx context.Context, req *PublishContent) (*PublishResponse, error) {
+	// 整个发布过程使用同一份配置，期间热更新不影响本次发布
+	cfg := config.GetConfig()
 
-	logrus.Infof("处理图片: %v", req.URL)
-	// 处理图片：下载URL图片或使用本地路径
-	imagePaths, err := s.processImages(req.Images, req.URL)
+	// 敏感词、标签、截取、图片下载和检查
+	prepared, err := s.prepare(ctx, cfg, req, false)
 	if err != nil {
 		return nil, err
 	}
 
-	// 设置处理后的图片路径
-	req.ImagePaths = imagePaths
-
 	accountID := req.AccountID
-	page := s.getBrowser().NewPage(accountID)
-	defer page.Close()
+	page := s.newPage(accountID)
+	defer s.releasePage(page)
+
+	// 确认已保存的 session 属于该账号，避免发到别的账号上
+	expectedUserID := s.expectedUserID(accountID)
+	if err := s.verifySession(ctx, page, accountID, expectedUserID); err != nil {
+		return nil, err
+	}
 
-	publisher, err := NewPublisher(page, accountID)
+	publisher, err := NewPublisher(page, &cfg.XHS, accountID, expectedUserID)
 	if err != nil {
-		return nil, fmt.Errorf("创建发布器失败: %w", err)
+		return nil, fmt.Errorf("创建发布器失败: %w", s.handleIntervention(accountID, page, err))
 	}
 
 	// 执行发布
-	return nil, publisher.Publish(ctx, *req)
-}
+	result, err := publisher.Publish(ctx, *req)
+	if err != nil {
+		return nil, err
+	}
 
-// processImages 处理图片列表，支持URL下载和本地路径
-func (s *Service) processImages(images []string, url string) ([]string, error) {
-	processor := utils.NewImageProcessor(url)
-	return processor.ProcessImages(images)
+	if err := s.accounts.TouchPublish(accountID); err != nil {
+		logrus.Warnf("记录账号 %s 发布时间失败: %v", accountID, err)
+	}
+	return &PublishResponse{
+		AccountID:     accountID,
+		Title:         req.Title,
+		Content:       req.Content,
+		Images:        len(req.ImagePaths),
+		NoteID:        result.NoteID,
+		Status:        PublishStatusPublished,
+		Tags:          result.Tags,
+		Warnings:      prepared.Warnings,
+		SensitiveHits: prepared.SensitiveHits,
+	}, nil
 }
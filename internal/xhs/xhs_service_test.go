@@ -163,3 +163,12 @@ func TestRuneWidthTruncate(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	phone, err := NormalizePhoneNumber(" +86 138-0013-8000 ")
+	assert.NoError(t, err)
+	assert.Equal(t, "13800138000", phone)
+
+	_, err = NormalizePhoneNumber("12345")
+	assert.ErrorIs(t, err, ErrInvalidPhoneNumber)
+}
@@ -0,0 +1,282 @@
+package xhs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"sns-poster/internal/notify"
+	"sns-poster/internal/utils"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// authCookieNames 决定登录 session 是否有效的 cookie，其中最早的过期时间即 session 的过期时间
+var authCookieNames = map[string]bool{
+	"web_session": true,
+	"a1":          true,
+}
+
+const (
+	// 单个账号刷新超时时间
+	sessionRefreshTimeout = 2 * time.Minute
+	// 两次刷新之间的间隔，避免集中打开页面
+	sessionRefreshSpacing = 30 * time.Second
+)
+
+// SessionInfo 账号登录 session 的过期信息
+type SessionInfo struct {
+	AccountID string     `json:"account_id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // 登录 cookie 最早的过期时间，nil 表示没有保存登录 cookie
+	// 最近一次刷新的时间和结果
+	RefreshedAt *time.Time `json:"refreshed_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	// 是否已进入告警窗口（需要尽快重新登录）
+	ExpiringSoon bool `json:"expiring_soon"`
+}
+
+// SessionRefresherConfig session 刷新配置
+type SessionRefresherConfig struct {
+	// Interval 检查周期，<=0 表示不启用
+	Interval time.Duration
+	// RefreshBefore 距离过期小于该时长时访问小红书刷新 session
+	RefreshBefore time.Duration
+	// WarnBefore 刷新后距离过期仍小于该时长时告警，需要人工重新登录
+	WarnBefore time.Duration
+}
+
+// SessionRefresher 后台定期检查账号登录 cookie 的过期时间，临近过期时用该 session
+// 访问小红书让网站刷新 token，并重新保存 cookies；无法延期时提前告警
+type SessionRefresher struct {
+	service *Service
+	config  SessionRefresherConfig
+
+	sessions map[string]*SessionInfo
+	// 已告警的过期时间，同一过期时间只告警一次
+	warned map[string]time.Time
+	mu     sync.RWMutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newSessionRefresher 创建 session 刷新器
+func newSessionRefresher(service *Service) *SessionRefresher {
+	return &SessionRefresher{
+		service:  service,
+		sessions: make(map[string]*SessionInfo),
+		warned:   make(map[string]time.Time),
+	}
+}
+
+// sessionExpiry 返回登录 cookie 中最早的过期时间，没有带过期时间的登录 cookie 时返回 false
+func sessionExpiry(cookies []*proto.NetworkCookie) (time.Time, bool) {
+	var earliest time.Time
+	for _, cookie := range cookies {
+		if !authCookieNames[cookie.Name] || cookie.Session || cookie.Expires <= 0 {
+			continue
+		}
+		expires := cookie.Expires.Time()
+		if earliest.IsZero() || expires.Before(earliest) {
+			earliest = expires
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// Start 启动后台刷新
+func (r *SessionRefresher) Start(cfg SessionRefresherConfig) {
+	if cfg.Interval <= 0 {
+		logrus.Info("登录 session 自动刷新未启用")
+		return
+	}
+	r.config = cfg
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	logrus.Infof("启动登录 session 自动刷新，周期: %s，过期前 %s 刷新，过期前 %s 告警",
+		cfg.Interval, cfg.RefreshBefore, cfg.WarnBefore)
+
+	go func() {
+		defer close(r.done)
+		for {
+			r.runRound(ctx)
+			if !sleepContext(ctx, cfg.Interval) {
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台刷新，等待正在进行的刷新结束
+func (r *SessionRefresher) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+	logrus.Info("登录 session 自动刷新已停止")
+}
+
+// Sessions 返回所有已注册账号的 session 过期信息（实时读取保存的 cookies）
+func (r *SessionRefresher) Sessions() []*SessionInfo {
+	accounts := r.service.accounts.List()
+	list := make([]*SessionInfo, 0, len(accounts))
+
+	// 只在复制最近一次刷新结果时持锁，读取 cookies（可能访问 Redis）不阻塞刷新
+	r.mu.RLock()
+	for _, a := range accounts {
+		info := &SessionInfo{AccountID: a.ID}
+		if last, ok := r.sessions[a.ID]; ok {
+			copied := *last
+			info = &copied
+		}
+		list = append(list, info)
+	}
+	r.mu.RUnlock()
+
+	for _, info := range list {
+		cookies, err := utils.NewCookieManagerForAccount(info.AccountID).LoadCookies()
+		if err != nil {
+			info.Error = err.Error()
+		} else if expires, ok := sessionExpiry(cookies); ok {
+			info.ExpiresAt = &expires
+			info.ExpiringSoon = r.config.WarnBefore > 0 && time.Until(expires) < r.config.WarnBefore
+		} else {
+			info.ExpiresAt = nil
+		}
+	}
+	return list
+}
+
+// runRound 检查所有启用账号，临近过期的逐个刷新
+func (r *SessionRefresher) runRound(ctx context.Context) {
+	first := true
+	for _, a := range r.service.accounts.List() {
+		if !a.Enabled {
+			continue
+		}
+
+		cookies, err := utils.NewCookieManagerForAccount(a.ID).LoadCookies()
+		if err != nil {
+			logrus.Warnf("[SessionRefresher] 读取账号 %s 的 cookies 失败: %v", a.ID, err)
+			continue
+		}
+		expires, ok := sessionExpiry(cookies)
+		if !ok || time.Until(expires) > r.config.RefreshBefore {
+			continue
+		}
+
+		if !first && !sleepContext(ctx, sessionRefreshSpacing) {
+			return
+		}
+		first = false
+
+		r.refreshAccount(ctx, a.ID, expires)
+	}
+}
+
+// refreshAccount 刷新单个账号的 session 并记录结果
+func (r *SessionRefresher) refreshAccount(ctx context.Context, accountID string, before time.Time) {
+	refreshCtx, cancel := context.WithTimeout(ctx, sessionRefreshTimeout)
+	defer cancel()
+
+	logrus.Infof("[SessionRefresher] 账号 %s 的登录 session 将于 %s 过期，开始刷新",
+		accountID, before.Format(time.RFC3339))
+
+	expires, err := r.refresh(refreshCtx, accountID)
+
+	now := time.Now()
+	info := &SessionInfo{AccountID: accountID, RefreshedAt: &now}
+	if err != nil {
+		info.Error = err.Error()
+		expires = before
+		logrus.Warnf("[SessionRefresher] 刷新账号 %s 的登录 session 失败: %v", accountID, err)
+	} else if expires.After(before) {
+		logrus.Infof("[SessionRefresher] 账号 %s 的登录 session 已延期至 %s", accountID, expires.Format(time.RFC3339))
+	} else {
+		logrus.Warnf("[SessionRefresher] 账号 %s 的登录 session 未能延期（%s）", accountID, expires.Format(time.RFC3339))
+	}
+	if !expires.IsZero() {
+		info.ExpiresAt = &expires
+		info.ExpiringSoon = time.Until(expires) < r.config.WarnBefore
+	}
+
+	r.mu.Lock()
+	r.sessions[accountID] = info
+	r.mu.Unlock()
+
+	if info.ExpiringSoon {
+		r.warnExpiring(accountID, expires)
+	}
+}
+
+// refresh 用保存的 session 访问小红书并重新保存 cookies，返回新的过期时间
+// 检查浏览器异常（panic）时转为错误，不影响后续账号
+func (r *SessionRefresher) refresh(ctx context.Context, accountID string) (expires time.Time, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = errors.Errorf("刷新登录 session 异常: %v", rec)
+		}
+	}()
+
+	s := r.service
+	// 先记录版本号，刷新期间其他实例重新登录保存的 cookies 不会被覆盖
+	cm := utils.NewCookieManagerForAccount(accountID)
+	if _, err := cm.LoadCookies(); err != nil {
+		return time.Time{}, err
+	}
+
+	page := s.newPage(accountID)
+	defer s.releasePage(page)
+
+	loginAction := NewLogin(page).ExpectUserID(s.expectedUserID(accountID))
+	if _, err := loginAction.CheckLoginStatus(ctx); err != nil {
+		return time.Time{}, s.handleIntervention(accountID, page, err)
+	}
+
+	if err := cm.SaveCookies(page); err != nil {
+		if !errors.Is(err, utils.ErrCookieVersionConflict) {
+			return time.Time{}, err
+		}
+		logrus.Infof("[SessionRefresher] 账号 %s 的 cookies 已被更新，不保存刷新结果", accountID)
+	}
+
+	cookies, err := cm.LoadCookies()
+	if err != nil {
+		return time.Time{}, err
+	}
+	expires, _ = sessionExpiry(cookies)
+	return expires, nil
+}
+
+// warnExpiring 发送 session 即将过期告警，同一过期时间只发送一次
+func (r *SessionRefresher) warnExpiring(accountID string, expires time.Time) {
+	r.mu.Lock()
+	if warned, ok := r.warned[accountID]; ok && warned.Equal(expires) {
+		r.mu.Unlock()
+		return
+	}
+	r.warned[accountID] = expires
+	r.mu.Unlock()
+
+	name := accountID
+	if a, err := r.service.accounts.Get(accountID); err == nil && a.DisplayName != "" {
+		name = fmt.Sprintf("%s (%s)", a.DisplayName, accountID)
+	}
+
+	content := fmt.Sprintf("小红书账号登录即将过期，请在 %s 前重新登录: %s",
+		expires.Local().Format("2006-01-02 15:04"), name)
+	logrus.Warn(content)
+	notify.Notify(&notify.Message{
+		Event:     notify.EventSessionExpiring,
+		AccountID: accountID,
+		Title:     "小红书账号登录即将过期",
+		Text:      content,
+	})
+}
@@ -0,0 +1,58 @@
+package xhs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		cookies  []*proto.NetworkCookie
+		expected time.Time
+		ok       bool
+	}{
+		{
+			name:    "No cookies",
+			cookies: nil,
+			ok:      false,
+		},
+		{
+			name: "Only non-auth cookies",
+			cookies: []*proto.NetworkCookie{
+				{Name: "xsecappid", Expires: 1700000000},
+			},
+			ok: false,
+		},
+		{
+			name: "Session auth cookie has no expiry",
+			cookies: []*proto.NetworkCookie{
+				{Name: "web_session", Expires: -1, Session: true},
+			},
+			ok: false,
+		},
+		{
+			name: "Earliest auth cookie wins",
+			cookies: []*proto.NetworkCookie{
+				{Name: "a1", Expires: 1800000000},
+				{Name: "web_session", Expires: 1700000000},
+				{Name: "xsecappid", Expires: 1600000000},
+			},
+			expected: time.Unix(1700000000, 0),
+			ok:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expires, ok := sessionExpiry(tt.cookies)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.True(t, tt.expected.Equal(expires), "expected %s, got %s", tt.expected, expires)
+			}
+		})
+	}
+}
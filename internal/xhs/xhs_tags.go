@@ -0,0 +1,154 @@
+package xhs
+
+import (
+	"strings"
+	"unicode"
+
+	"sns-poster/internal/config"
+
+	"golang.org/x/text/width"
+)
+
+// topicSuffix 从小红书复制的正文中话题的写法为 #话题[话题]#
+const topicSuffix = "[话题]"
+
+// extractHashtags 提取正文中的 #话题（支持全角＃、#话题# 和 #话题[话题]# 写法）并从正文中删除。
+// # 前面是英文字母、数字或 ASCII 符号时不算话题（C#、example.com/#top），# 后没有内容时保留原文（Markdown 标题）
+func extractHashtags(content string) (string, []string) {
+	var (
+		tags  []string
+		lines = strings.Split(content, "\n")
+		kept  = lines[:0]
+	)
+	for _, line := range lines {
+		cleaned, found := extractLineHashtags(line)
+		tags = append(tags, found...)
+		if len(found) == 0 {
+			kept = append(kept, line)
+			continue
+		}
+		// 删除话题后只剩空白的行整行删除，其余行合并删除话题留下的多余空格
+		if cleaned = strings.Join(strings.Fields(cleaned), " "); cleaned != "" {
+			kept = append(kept, cleaned)
+		}
+	}
+	return strings.TrimRightFunc(strings.Join(kept, "\n"), unicode.IsSpace), tags
+}
+
+// extractLineHashtags 提取一行中的话题，返回删除话题后的行
+func extractLineHashtags(line string) (string, []string) {
+	var (
+		tags []string
+		out  strings.Builder
+		rs   = []rune(line)
+		// afterTag 上一个字符是刚删除的话题，#a#b 中的 #b 也是话题
+		afterTag bool
+	)
+	for i := 0; i < len(rs); i++ {
+		if !isHash(rs[i]) || (i > 0 && !afterTag && !hashtagCanFollow(rs[i-1])) {
+			out.WriteRune(rs[i])
+			afterTag = false
+			continue
+		}
+
+		end := i + 1
+		for end < len(rs) && isTagRune(rs[end]) {
+			end++
+		}
+		if end == i+1 {
+			out.WriteRune(rs[i])
+			afterTag = false
+			continue
+		}
+		tags = append(tags, string(rs[i+1:end]))
+
+		// #话题[话题]# 和 #话题# 的结尾；#a#b 中第二个 # 是下一个话题的开始
+		if strings.HasPrefix(string(rs[end:]), topicSuffix) {
+			end += len([]rune(topicSuffix))
+		}
+		if end < len(rs) && isHash(rs[end]) && (end+1 == len(rs) || !isTagRune(rs[end+1])) {
+			end++
+		}
+		afterTag = true
+		i = end - 1
+	}
+	return out.String(), tags
+}
+
+func isHash(r rune) bool {
+	return r == '#' || r == '＃'
+}
+
+// hashtagCanFollow # 前面的字符：空白、中文和全角标点可以，英文字母、数字和 ASCII 符号不可以
+func hashtagCanFollow(r rune) bool {
+	return unicode.IsSpace(r) || r > unicode.MaxASCII
+}
+
+// isTagRune 话题中的字符：文字、数字、下划线、连字符和间隔号，遇到空白、标点、符号（emoji）时结束
+func isTagRune(r rune) bool {
+	if isHash(r) || unicode.IsSpace(r) {
+		return false
+	}
+	if r == '_' || r == '-' || r == '·' {
+		return true
+	}
+	return !unicode.IsPunct(r) && !unicode.IsSymbol(r)
+}
+
+// tagRules 标签整理规则
+type tagRules struct {
+	maxTags int
+	// synonyms 别名和首选话题名（tagKey 后）-> 首选话题名
+	synonyms map[string]string
+}
+
+// newTagRules 按配置创建标签整理规则
+func newTagRules(cfg *config.XHSConfig) *tagRules {
+	r := &tagRules{maxTags: cfg.MaxTags, synonyms: make(map[string]string)}
+	for preferred, aliases := range cfg.TagSynonyms {
+		preferred = cleanTag(preferred)
+		r.synonyms[tagKey(preferred)] = preferred
+		for _, alias := range aliases {
+			r.synonyms[tagKey(cleanTag(alias))] = preferred
+		}
+	}
+	return r
+}
+
+// normalize 整理标签：统一全半角、去掉 # 前缀和 [话题] 后缀、删除空白，按同义词替换为首选话题名，
+// 不区分大小写去重（保留第一次出现的写法），超过数量上限的标签放在 dropped 中返回
+func (r *tagRules) normalize(tags []string) (result, dropped []string) {
+	seen := make(map[string]bool)
+	result = []string{}
+	for _, tag := range tags {
+		tag = cleanTag(tag)
+		if preferred, ok := r.synonyms[tagKey(tag)]; ok {
+			tag = preferred
+		}
+		key := tagKey(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if r.maxTags > 0 && len(result) >= r.maxTags {
+			dropped = append(dropped, tag)
+			continue
+		}
+		result = append(result, tag)
+	}
+	return result, dropped
+}
+
+// cleanTag 统一全半角（全角字母数字和＃转为半角），去掉 # 前缀、[话题] 后缀和所有空白（小红书输入话题时空格会结束话题）
+func cleanTag(tag string) string {
+	tag = strings.Join(strings.Fields(width.Fold.String(tag)), "")
+	tag = strings.TrimLeft(tag, "#")
+	tag = strings.TrimSuffix(strings.TrimRight(tag, "#"), topicSuffix)
+	return strings.TrimRight(tag, "#")
+}
+
+// tagKey 去重和匹配同义词时使用的键，不区分大小写
+func tagKey(tag string) string {
+	return strings.ToLower(tag)
+}
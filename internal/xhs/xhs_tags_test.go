@@ -0,0 +1,62 @@
+package xhs
+
+import (
+	"testing"
+
+	"sns-poster/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		expectedContent string
+		expectedTags    []string
+	}{
+		{
+			name:            "Trailing tag line",
+			content:         "新品发售！\n#手办 #一番赏",
+			expectedContent: "新品发售！",
+			expectedTags:    []string{"手办", "一番赏"},
+		},
+		{
+			name:            "Inline full-width hash",
+			content:         "今天入手了＃手办，很开心",
+			expectedContent: "今天入手了，很开心",
+			expectedTags:    []string{"手办"},
+		},
+		{
+			name:            "Closed and XHS topic forms",
+			content:         "正文\n#王者天下# #手办[话题]# #a#b",
+			expectedContent: "正文",
+			expectedTags:    []string{"王者天下", "手办", "a", "b"},
+		},
+		{
+			name:            "Not a hashtag",
+			content:         "# 标题\nC# 和 https://example.com/#top",
+			expectedContent: "# 标题\nC# 和 https://example.com/#top",
+			expectedTags:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, tags := extractHashtags(tt.content)
+			assert.Equal(t, tt.expectedContent, content)
+			assert.Equal(t, tt.expectedTags, tags)
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	rules := newTagRules(&config.XHSConfig{
+		MaxTags:     3,
+		TagSynonyms: map[string][]string{"手办": {"figure", "手辦"}},
+	})
+
+	tags, dropped := rules.normalize([]string{"#Figure", " 手 办 ", "＃ＰＶＣ", "pvc", "#一番赏[话题]#", "", "模型", "周边"})
+	assert.Equal(t, []string{"手办", "PVC", "一番赏"}, tags)
+	assert.Equal(t, []string{"模型", "周边"}, dropped)
+}
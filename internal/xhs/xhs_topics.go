@@ -0,0 +1,175 @@
+package xhs
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"sns-poster/internal/config"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// topicPlaceholderName 查询话题时上传的空白图片，发布编辑器在上传图片后才出现
+const topicPlaceholderName = "topic-placeholder.png"
+
+// Topic 话题联想中的话题
+type Topic struct {
+	Name      string `json:"name"`
+	Views     string `json:"views,omitempty"`      // 页面显示的浏览量，如 "12.3亿次浏览"
+	ViewCount int64  `json:"view_count,omitempty"` // 解析后的浏览量
+}
+
+// SearchTopics 在账号的发布编辑器中输入 #query，返回话题联想中的话题（没有联想时为空）。
+// 编辑器在上传图片后才出现，使用一张生成的空白图片；不会提交发布，也不会自动登录
+func (s *Service) SearchTopics(ctx context.Context, accountID, query string) ([]Topic, error) {
+	cfg := config.GetConfig()
+
+	page := s.newPage(accountID)
+	defer s.releasePage(page)
+
+	if err := s.verifySession(ctx, page, accountID, s.expectedUserID(accountID)); err != nil {
+		return nil, err
+	}
+
+	pp := page.Context(ctx).Timeout(cfg.XHS.PublishTimeout)
+	if err := pp.Navigate(cfg.XHS.PublishURL); err != nil {
+		return nil, errors.Wrap(err, "导航到发布页面失败")
+	}
+	time.Sleep(3 * time.Second)
+
+	if err := checkChallenge(pp); err != nil {
+		return nil, s.handleIntervention(accountID, page, err)
+	}
+	info, err := pp.Info()
+	if err != nil {
+		return nil, errors.Wrap(err, "获取页面信息失败")
+	}
+	if strings.Contains(info.URL, "login") {
+		return nil, ErrNotLoggedIn
+	}
+
+	placeholder, err := topicPlaceholder(cfg.Images.DownloadDir)
+	if err != nil {
+		return nil, err
+	}
+	publisher := &Publisher{page: pp, config: &cfg.XHS, accountID: accountID}
+	if err := publisher.uploadImages(pp, []string{placeholder}); err != nil {
+		return nil, errors.Wrap(err, "打开发布编辑器失败")
+	}
+
+	contentElem, err := pp.Element("div.edit-container div[contenteditable='true']")
+	if err != nil {
+		return nil, errors.Wrap(err, "查找内容输入框失败")
+	}
+	if err := contentElem.Input("#"); err != nil {
+		return nil, errors.Wrap(err, "输入话题失败")
+	}
+	time.Sleep(200 * time.Millisecond)
+	for _, char := range query {
+		if err := contentElem.Input(string(char)); err != nil {
+			return nil, errors.Wrap(err, "输入话题失败")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(1 * time.Second)
+
+	topics := []Topic{}
+	container, err := pp.Timeout(5 * time.Second).Element("#creator-editor-topic-container")
+	if err != nil {
+		logrus.Infof("话题 %q 没有联想选项", query)
+		return topics, nil
+	}
+	items, err := container.Elements(".item")
+	if err != nil {
+		return nil, errors.Wrap(err, "读取话题联想失败")
+	}
+	for _, item := range items {
+		text, err := item.Text()
+		if err != nil {
+			continue
+		}
+		if topic := parseTopicItem(text); topic.Name != "" {
+			topics = append(topics, topic)
+		}
+	}
+
+	logrus.Infof("话题 %q 的联想结果: %d 个", query, len(topics))
+	return topics, nil
+}
+
+// parseTopicItem 解析话题联想选项的文字：第一行为话题名称，其后为浏览量
+func parseTopicItem(text string) Topic {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	topic := Topic{Name: cleanTag(lines[0])}
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			topic.Views = line
+			topic.ViewCount = parseViewCount(line)
+			break
+		}
+	}
+	return topic
+}
+
+// viewCountPattern 浏览量，如 "12.3亿次浏览"、"5.6万"、"1234次浏览"
+var viewCountPattern = regexp.MustCompile(`([\d.]+)\s*([万亿wW]?)`)
+
+// parseViewCount 解析浏览量，无法解析时返回 0
+func parseViewCount(s string) int64 {
+	m := viewCountPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0
+	}
+	switch m[2] {
+	case "万", "w", "W":
+		n *= 1e4
+	case "亿":
+		n *= 1e8
+	}
+	return int64(n + 0.5)
+}
+
+// topicPlaceholder 返回查询话题时上传的空白图片路径，不存在时生成
+func topicPlaceholder(dir string) (string, error) {
+	path := filepath.Join(dir, topicPlaceholderName)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, "创建图片目录失败")
+	}
+	img := image.NewGray(image.Rect(0, 0, 400, 400))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	tmp := fmt.Sprintf("%s.%d", path, time.Now().UnixNano())
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", errors.Wrap(err, "生成空白图片失败")
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", errors.Wrap(err, "生成空白图片失败")
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", errors.Wrap(err, "生成空白图片失败")
+	}
+	return path, errors.Wrap(os.Rename(tmp, path), "生成空白图片失败")
+}
@@ -0,0 +1,19 @@
+package xhs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTopicItem(t *testing.T) {
+	assert.Equal(t, Topic{Name: "手办", Views: "12.3亿次浏览", ViewCount: 1230000000}, parseTopicItem("#手办\n12.3亿次浏览"))
+	assert.Equal(t, Topic{Name: "手办模型", Views: "5.6万次浏览", ViewCount: 56000}, parseTopicItem(" #手办模型[话题]# \n\n 5.6万次浏览 "))
+	assert.Equal(t, Topic{Name: "新话题"}, parseTopicItem("#新话题"))
+}
+
+func TestParseViewCount(t *testing.T) {
+	assert.Equal(t, int64(1234), parseViewCount("1234次浏览"))
+	assert.Equal(t, int64(15000), parseViewCount("1.5w"))
+	assert.Equal(t, int64(0), parseViewCount("暂无浏览"))
+}
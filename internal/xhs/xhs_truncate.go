@@ -0,0 +1,137 @@
+package xhs
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/clipperhouse/uax29/v2/graphemes"
+)
+
+// minKeepRatio 在句子或分句边界截取时至少保留的宽度比例，
+// 达不到时退回到词语边界，避免为了停在句末丢掉大半内容
+const minKeepRatio = 0.6
+
+// boundary 截取位置的边界类型，数值越大越优先
+type boundary int
+
+const (
+	boundaryGrapheme boundary = iota // 字素簇之间（不拆分 emoji 组合序列、国旗等）
+	boundaryWord                     // 空白处，或中文、emoji 等宽字符前后
+	boundaryClause                   // 逗号、顿号、冒号等分句标点之前
+	boundarySentence                 // 句末标点之后，或换行之前
+)
+
+// grapheme 一个字素簇及其在原文中的起始字节位置
+type grapheme struct {
+	text  string
+	start int
+	width int
+}
+
+// truncateText 把文本截取到 maxWidth 长度（按 xhsLength 计算）以内，优先在句子、分句、词语边界截取，
+// 不拆分字素簇。截取时在末尾追加 ellipsis（计入宽度），停在句末时不追加
+func truncateText(s string, maxWidth int, ellipsis string) string {
+	if xhsLength(s) <= maxWidth {
+		return s
+	}
+
+	budget := maxWidth - xhsLength(ellipsis)
+	if budget <= 0 {
+		budget, ellipsis = maxWidth, ""
+	}
+
+	var gs []grapheme
+	iter := graphemes.FromString(s)
+	for iter.Next() {
+		gs = append(gs, grapheme{text: iter.Value(), start: iter.Start(), width: graphemeLength(iter.Value())})
+	}
+
+	// 选择放得下的位置中边界类型最优的，同类取最靠后的；
+	// 没有词语边界（如一整段 URL）时才在字素簇之间截取
+	var (
+		width   int
+		cut     int
+		cutType = boundaryGrapheme
+		minKeep = int(float64(budget) * minKeepRatio)
+	)
+	for i := 1; i < len(gs); i++ {
+		width += gs[i-1].width
+		if width > budget {
+			break
+		}
+		b := boundaryAt(gs, i)
+		if b >= cutType && (width >= minKeep || b <= boundaryWord) {
+			cut, cutType = i, b
+		}
+	}
+
+	result := strings.TrimRightFunc(s[:gs[cut].start], unicode.IsSpace)
+	if cutType == boundarySentence {
+		return result
+	}
+	result = strings.TrimRightFunc(result, isClausePunct)
+	return result + ellipsis
+}
+
+// boundaryAt 判断第 i 个字素簇之前（即 gs[i-1] 与 gs[i] 之间）的边界类型
+func boundaryAt(gs []grapheme, i int) boundary {
+	prev, next := gs[i-1].text, gs[i].text
+	switch {
+	case next == "\n" || next == "\r\n":
+		return boundarySentence
+	case isSentenceEnd(prev) && (isFullWidth(prev) || followedBySpace(gs, i-1)):
+		return boundarySentence
+	case isClosingQuote(prev) && i >= 2 && isSentenceEnd(gs[i-2].text):
+		// 句末标点后的右引号、右括号：「好！」
+		return boundarySentence
+	case isClause(next) && (isFullWidth(next) || followedBySpace(gs, i)):
+		return boundaryClause
+	case isSpace(prev) || isSpace(next):
+		return boundaryWord
+	case gs[i-1].width >= 2 || gs[i].width >= 2:
+		return boundaryWord
+	}
+	return boundaryGrapheme
+}
+
+// isSentenceEnd 句末标点
+func isSentenceEnd(s string) bool {
+	return isOneOf(s, "。！？；…!?;.")
+}
+
+// isClause 分句标点
+func isClause(s string) bool {
+	return isOneOf(s, "，、：,:")
+}
+
+// isClausePunct 用于去掉截取结果末尾的分句标点
+func isClausePunct(r rune) bool {
+	return strings.ContainsRune("，、：,:", r)
+}
+
+// isClosingQuote 右引号、右括号
+func isClosingQuote(s string) bool {
+	return isOneOf(s, "”’」』）)】》\"'")
+}
+
+// isFullWidth 全角标点后不需要空格即可断句；半角标点要求后面是空白或文本结尾，避免拆开 3.14、example.com 等
+func isFullWidth(s string) bool {
+	return xhsLength(s) >= 2
+}
+
+// followedBySpace gs[i] 之后是空白或文本结尾
+func followedBySpace(gs []grapheme, i int) bool {
+	return i+1 >= len(gs) || isSpace(gs[i+1].text)
+}
+
+// isSpace 字素簇是否是空白
+func isSpace(s string) bool {
+	return strings.TrimSpace(s) == ""
+}
+
+// isOneOf 字素簇是否是 chars 中的某一个字符
+func isOneOf(s string, chars string) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	return size > 0 && size == len(s) && strings.ContainsRune(chars, r)
+}
@@ -0,0 +1,136 @@
+package xhs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name           string
+		text           string
+		maxWidth       int
+		ellipsis       string
+		expectedResult string
+		description    string
+	}{
+		{
+			name:           "No truncation needed",
+			text:           "Hello",
+			maxWidth:       10,
+			ellipsis:       "…",
+			expectedResult: "Hello",
+			description:    "Text shorter than max is returned as is",
+		},
+		{
+			name:           "CJK sentence boundary",
+			text:           "今天天气很好。我们去公园散步吧",
+			maxWidth:       20,
+			ellipsis:       "…",
+			expectedResult: "今天天气很好。",
+			description:    "Cut after 。 without ellipsis: 今天天气很好。(14)",
+		},
+		{
+			name:           "CJK clause boundary",
+			text:           "限定版手办，全球限量发售，先到先得",
+			maxWidth:       26,
+			ellipsis:       "…",
+			expectedResult: "限定版手办，全球限量发售…",
+			description:    "Cut before ， and append ellipsis: 限定版手办，全球限量发售(24) + …(2)",
+		},
+		{
+			name:           "Sentence too short falls back to clause",
+			text:           "好。这是一段很长的内容，后面还有很多文字",
+			maxWidth:       30,
+			ellipsis:       "",
+			expectedResult: "好。这是一段很长的内容",
+			description:    "好。 keeps less than 60% of budget, so cut before ，",
+		},
+		{
+			name:           "Latin sentence boundary",
+			text:           "New figure announced. Pre-orders open next week",
+			maxWidth:       30,
+			ellipsis:       "...",
+			expectedResult: "New figure announced.",
+			description:    "Cut after '. ' without ellipsis",
+		},
+		{
+			name:           "Latin word boundary",
+			text:           "Limited edition collectible figure",
+			maxWidth:       25,
+			ellipsis:       "...",
+			expectedResult: "Limited edition...",
+			description:    "Never cut in the middle of a word",
+		},
+		{
+			name:           "Decimal point is not a sentence end",
+			text:           "Scale 1.7 figure with base",
+			maxWidth:       16,
+			ellipsis:       "",
+			expectedResult: "Scale 1.7 figure",
+			description:    "'1.' followed by a digit is not a boundary",
+		},
+		{
+			name:           "Do not split URL",
+			text:           "详情见官网 https://example.com/products/12345",
+			maxWidth:       30,
+			ellipsis:       "…",
+			expectedResult: "详情见官网…",
+			description:    "Cut at the space before the URL",
+		},
+		{
+			name:           "Newline is a paragraph boundary",
+			text:           "第一段内容写在这里\n第二段内容",
+			maxWidth:       22,
+			ellipsis:       "…",
+			expectedResult: "第一段内容写在这里",
+			description:    "Cut before the newline",
+		},
+		{
+			name:           "Closing quote after sentence end",
+			text:           "他说：「一定会成功！」然后离开了会场",
+			maxWidth:       26,
+			ellipsis:       "…",
+			expectedResult: "他说：「一定会成功！」",
+			description:    "Keep the closing quote with the sentence",
+		},
+		{
+			name:           "Do not split ZWJ emoji",
+			text:           "家庭👨‍👩‍👧‍👦聚会",
+			maxWidth:       5,
+			ellipsis:       "",
+			expectedResult: "家庭",
+			description:    "👨‍👩‍👧‍👦 is one grapheme of width 2 and is kept or dropped as a whole",
+		},
+		{
+			name:           "Do not split flag",
+			text:           "日本🇯🇵旅行",
+			maxWidth:       6,
+			ellipsis:       "",
+			expectedResult: "日本🇯🇵",
+			description:    "Regional indicator pair is one grapheme of length 2",
+		},
+		{
+			name:           "Ellipsis wider than budget",
+			text:           "一二三四",
+			maxWidth:       3,
+			ellipsis:       "...",
+			expectedResult: "一",
+			description:    "Ellipsis is dropped when it leaves no room for text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := truncateText(tt.text, tt.maxWidth, tt.ellipsis)
+			assert.Equal(t, tt.expectedResult, result,
+				"Truncation mismatch for '%s': %s", tt.text, tt.description)
+
+			actualWidth := xhsLength(result)
+			assert.LessOrEqual(t, actualWidth, tt.maxWidth,
+				"Truncated text width (%d) should not exceed max (%d): %s",
+				actualWidth, tt.maxWidth, tt.description)
+		})
+	}
+}
@@ -5,6 +5,9 @@
 
 # Configuration
 API_BASE="http://localhost:6170"
+# 需要 publish 权限的 API 密钥和发布账号
+API_KEY="${SNS_POSTER_API_KEY:?请设置 SNS_POSTER_API_KEY}"
+ACCOUNT_ID="${SNS_POSTER_ACCOUNT_ID:?请设置 SNS_POSTER_ACCOUNT_ID}"
 
 echo "🚀 Quick XHS Post Test"
 echo "========================"
@@ -34,6 +37,8 @@ echo "   ⏳ This may take time if login is required (check server logs for QR c
 
 RESPONSE=$(curl -s -X POST "$API_BASE/api/v1/xhs/publish" \
     -H "Content-Type: application/json" \
+    -H "Authorization: Bearer $API_KEY" \
+    -H "X-Account-ID: $ACCOUNT_ID" \
     -d "$TEST_DATA")
 
 echo ""